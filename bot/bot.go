@@ -0,0 +1,176 @@
+// Package bot provides a chat bot executing the commands of a runner.Runner, e.g., the dice commands of the
+// REPL. The bot reads messages from a chat platform through a Transport. Messages starting with the command
+// prefix, e.g., /roll 2d6+3, are executed as command line and the output is sent as reply to the channel of
+// the message. Each user holds its own session state. The package provides the Transport IRC for the IRC protocol.
+//
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package bot
+
+// Import standard library packages as well as runner and tserr
+import (
+	"context" // context
+	"fmt"     // fmt
+	"strings" // strings
+
+	"github.com/thorstenrie/lpdice/runner" // runner
+	"github.com/thorstenrie/tserr"         // tserr
+)
+
+// defaultPrefix defines the default command prefix, maxLines the maximum number of lines of a reply
+// and maxUsers the maximum number of users holding a session state.
+const (
+	defaultPrefix string = "/"
+	maxLines      int    = 10
+	maxUsers      int    = 1000
+)
+
+// A Message is a chat message of User in Channel. Replies to the message are sent to Channel.
+type Message struct {
+	Channel string // channel or user to reply to
+	User    string // sender of the message
+	Text    string // text of the message
+}
+
+// A Transport connects the bot to a chat platform. Receive blocks until the next message is received or
+// ctx is cancelled. Send sends text to channel. Close disconnects from the chat platform.
+type Transport interface {
+	Receive(ctx context.Context) (Message, error)
+	Send(ctx context.Context, channel, text string) error
+	Close() error
+}
+
+// A Bot executes commands of a runner.Runner received as chat messages. It holds the command prefix
+// and the session state of each user. A Bot is not safe for concurrent use by multiple goroutines.
+type Bot struct {
+	r        *runner.Runner     // runner providing the commands
+	prefix   string             // command prefix
+	session  runner.SessionFunc // function returning the state of a new session
+	sessions map[string]any     // session state by user
+	users    []string           // users in order of their first command
+}
+
+// New returns a pointer to a new Bot executing the commands of Runner r. If session is not nil, each user
+// holds its own session state retrieved from session. It returns nil and an error, if r is nil.
+func New(r *runner.Runner, session runner.SessionFunc) (*Bot, error) {
+	// Return an error if r is nil
+	if r == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Return the new Bot
+	return &Bot{r: r, prefix: defaultPrefix, session: session, sessions: make(map[string]any)}, nil
+}
+
+// SetPrefix sets the command prefix of Bot b, e.g., / or !. It returns an error, if p is empty or contains whitespace.
+func (b *Bot) SetPrefix(p string) error {
+	// Return an error if b is nil
+	if b == nil {
+		return tserr.NilPtr()
+	}
+	// Return an error if p is empty or contains whitespace
+	if p == "" {
+		return tserr.Empty("prefix")
+	}
+	if len(strings.Fields(p)) != 1 || strings.TrimSpace(p) != p {
+		return tserr.Forbidden("prefix " + p)
+	}
+	// Set the prefix
+	b.prefix = p
+	return nil
+}
+
+// Handle executes the command line of message m, if the text starts with the command prefix, e.g., /roll 2d6+3.
+// It returns the reply lines, which are prefixed with the user and limited to maxLines. It returns no lines, if
+// the message is not a command. Errors of the command are returned as reply.
+func (b *Bot) Handle(ctx context.Context, m Message) ([]string, error) {
+	// Return an error if b is nil
+	if b == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Return no lines if the message is not a command
+	l, ok := strings.CutPrefix(strings.TrimSpace(m.Text), b.prefix)
+	if !ok || strings.TrimSpace(l) == "" {
+		return nil, nil
+	}
+	// Execute the command with the session state of the user and capture its output
+	var out strings.Builder
+	r, e := b.r.Clone(strings.NewReader(""), &out)
+	if e != nil {
+		return nil, e
+	}
+	if s := b.state(m.User); s != nil {
+		ctx = runner.WithSession(ctx, s)
+	}
+	if _, err := r.Exec(ctx, l); err != nil {
+		fmt.Fprintf(&out, "Error: %s\n", err)
+	}
+	// Return the output lines prefixed with the user
+	var lines []string
+	for _, s := range strings.Split(out.String(), "\n") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		if len(lines) == maxLines {
+			lines = append(lines, m.User+": ...")
+			break
+		}
+		lines = append(lines, m.User+": "+s)
+	}
+	return lines, nil
+}
+
+// state returns the session state of user u. It creates the session state, if it does not exist. If the
+// maximum number of users is reached, the state of the user with the oldest first command is removed.
+func (b *Bot) state(u string) any {
+	// Return nil if users do not hold a session state
+	if b.session == nil {
+		return nil
+	}
+	// Return the session state, if it exists
+	if s, ok := b.sessions[u]; ok {
+		return s
+	}
+	// Remove the oldest session state, if the maximum is reached
+	if len(b.users) >= maxUsers {
+		delete(b.sessions, b.users[0])
+		b.users = b.users[1:]
+	}
+	// Create the session state
+	s := b.session()
+	b.sessions[u] = s
+	b.users = append(b.users, u)
+	return s
+}
+
+// Run receives messages from Transport t and sends the replies to commands until ctx is cancelled or
+// receiving fails. It closes t when it returns. It returns nil, if ctx is cancelled, and an error otherwise.
+func (b *Bot) Run(ctx context.Context, t Transport) error {
+	// Return an error if b or t is nil
+	if b == nil || t == nil {
+		return tserr.NilPtr()
+	}
+	defer t.Close()
+	for {
+		// Receive the next message
+		m, e := t.Receive(ctx)
+		if e != nil {
+			// Return nil if ctx is cancelled
+			if ctx.Err() != nil {
+				return nil
+			}
+			return tserr.Op(&tserr.OpArgs{Op: "receive", Fn: "message", Err: e})
+		}
+		// Execute the command
+		lines, e := b.Handle(ctx, m)
+		if e != nil {
+			return e
+		}
+		// Send the reply
+		for _, l := range lines {
+			if e = t.Send(ctx, m.Channel, l); e != nil {
+				return tserr.Op(&tserr.OpArgs{Op: "send", Fn: m.Channel, Err: e})
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package bot
+
+// Import standard library packages as well as tserr
+import (
+	"bufio"   // bufio
+	"context" // context
+	"fmt"     // fmt
+	"net"     // net
+	"strings" // strings
+	"sync"    // sync
+	"time"    // time
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// maxMessage defines the maximum length of an IRC message including CR LF as defined in RFC 1459
+const (
+	maxMessage int = 512
+)
+
+// IRC is a Transport for the IRC protocol. It is connected with DialIRC and receives messages sent to its
+// nick or to joined channels. Replies to direct messages are sent to the sender.
+type IRC struct {
+	c    net.Conn      // connection to the server
+	r    *bufio.Reader // buffered reader of the connection
+	mu   sync.Mutex    // mutex to serialize writes
+	nick string        // nick of the bot
+}
+
+// ircLine is a parsed IRC message
+type ircLine struct {
+	prefix  string   // prefix of the message, e.g., nick!user@host
+	command string   // command or numeric reply
+	params  []string // parameters including the trailing parameter
+}
+
+// parseLine parses IRC message l.
+func parseLine(l string) ircLine {
+	// m holds the parsed message
+	var m ircLine
+	l = strings.TrimRight(l, "\r\n")
+	// Parse the prefix
+	if strings.HasPrefix(l, ":") {
+		m.prefix, l, _ = strings.Cut(l[1:], " ")
+	}
+	// Parse the trailing parameter
+	l, trailing, ok := strings.Cut(l, " :")
+	// Parse command and parameters
+	f := strings.Fields(l)
+	if len(f) > 0 {
+		m.command, m.params = strings.ToUpper(f[0]), f[1:]
+	}
+	if ok {
+		m.params = append(m.params, trailing)
+	}
+	// Return the parsed message
+	return m
+}
+
+// DialIRC connects to the IRC server at addr with nick and joins channels, e.g., #dice. It waits for the
+// welcome reply of the server until ctx is cancelled. It returns nil and an error, if any.
+func DialIRC(ctx context.Context, addr, nick string, channels ...string) (*IRC, error) {
+	// Return an error if the nick is empty or contains whitespace
+	if nick == "" || len(strings.Fields(nick)) != 1 || strings.TrimSpace(nick) != nick {
+		return nil, tserr.Forbidden("nick " + nick)
+	}
+	// Connect to the server
+	var d net.Dialer
+	c, e := d.DialContext(ctx, "tcp", addr)
+	if e != nil {
+		return nil, tserr.Op(&tserr.OpArgs{Op: "dial", Fn: addr, Err: e})
+	}
+	i := &IRC{c: c, r: bufio.NewReader(c), nick: nick}
+	// Register and wait for the welcome reply
+	if e = i.register(ctx, channels); e != nil {
+		c.Close()
+		return nil, e
+	}
+	// Return the connected IRC transport
+	return i, nil
+}
+
+// register registers the nick, waits for the welcome reply and joins channels.
+func (i *IRC) register(ctx context.Context, channels []string) error {
+	// Register the nick
+	if e := i.write("NICK " + i.nick); e != nil {
+		return e
+	}
+	if e := i.write("USER " + i.nick + " 0 * :" + i.nick); e != nil {
+		return e
+	}
+	// Wait for the welcome reply
+	for {
+		m, e := i.read(ctx)
+		if e != nil {
+			return e
+		}
+		if m.command == "001" {
+			break
+		}
+		if strings.HasPrefix(m.command, "4") || strings.HasPrefix(m.command, "5") || m.command == "ERROR" {
+			return tserr.Op(&tserr.OpArgs{Op: "register", Fn: i.nick, Err: fmt.Errorf("%s %s", m.command, strings.Join(m.params, " "))})
+		}
+	}
+	// Join the channels
+	for _, ch := range channels {
+		if e := i.write("JOIN " + ch); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// read reads the next message from the server until ctx is cancelled. It answers pings of the server.
+func (i *IRC) read(ctx context.Context) (ircLine, error) {
+	// Unblock the pending read when ctx is cancelled
+	i.c.SetReadDeadline(time.Time{})
+	stop := context.AfterFunc(ctx, func() { i.c.SetReadDeadline(time.Now()) })
+	defer stop()
+	for {
+		l, e := i.r.ReadString('\n')
+		if e != nil {
+			if ctx.Err() != nil {
+				return ircLine{}, ctx.Err()
+			}
+			return ircLine{}, tserr.Op(&tserr.OpArgs{Op: "read", Fn: "IRC", Err: e})
+		}
+		m := parseLine(l)
+		// Answer pings of the server
+		if m.command == "PING" {
+			if e = i.write("PONG :" + strings.Join(m.params, " ")); e != nil {
+				return ircLine{}, e
+			}
+			continue
+		}
+		return m, nil
+	}
+}
+
+// write writes IRC message l to the server. Line breaks are removed and the message is truncated to maxMessage.
+func (i *IRC) write(l string) error {
+	// Remove line breaks and truncate the message
+	l = strings.NewReplacer("\r", " ", "\n", " ").Replace(l)
+	if len(l) > maxMessage-2 {
+		l = l[:maxMessage-2]
+	}
+	// Write the message
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, e := i.c.Write([]byte(l + "\r\n")); e != nil {
+		return tserr.Op(&tserr.OpArgs{Op: "write", Fn: "IRC", Err: e})
+	}
+	return nil
+}
+
+// Receive returns the next message sent to a joined channel or to the nick of the bot. It returns an
+// empty Message and an error, if reading fails or ctx is cancelled.
+func (i *IRC) Receive(ctx context.Context) (Message, error) {
+	// Return an error if i is nil
+	if i == nil {
+		return Message{}, tserr.NilPtr()
+	}
+	for {
+		// Read the next message
+		m, e := i.read(ctx)
+		if e != nil {
+			return Message{}, e
+		}
+		// Return an error if the server closes the connection
+		if m.command == "ERROR" {
+			return Message{}, tserr.NotAvailable(&tserr.NotAvailableArgs{S: "IRC", Err: fmt.Errorf("%s", strings.Join(m.params, " "))})
+		}
+		// Skip messages other than PRIVMSG
+		if m.command != "PRIVMSG" || len(m.params) < 2 {
+			continue
+		}
+		// Reply to the sender of direct messages
+		user, _, _ := strings.Cut(m.prefix, "!")
+		ch := m.params[0]
+		if ch == i.nick {
+			ch = user
+		}
+		return Message{Channel: ch, User: user, Text: m.params[1]}, nil
+	}
+}
+
+// Send sends text to channel or user ch.
+func (i *IRC) Send(ctx context.Context, ch, text string) error {
+	// Return an error if i is nil
+	if i == nil {
+		return tserr.NilPtr()
+	}
+	// Return an error if ctx is cancelled
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+	// Send the message
+	return i.write("PRIVMSG " + ch + " :" + text)
+}
+
+// Close quits the IRC session and closes the connection.
+func (i *IRC) Close() error {
+	// Return an error if i is nil
+	if i == nil {
+		return tserr.NilPtr()
+	}
+	i.write("QUIT :bye")
+	return i.c.Close()
+}
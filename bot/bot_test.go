@@ -0,0 +1,213 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package bot
+
+// Import standard library packages as well as runner and tserr
+import (
+	"bufio"   // bufio
+	"context" // context
+	"fmt"     // fmt
+	"io"      // io
+	"net"     // net
+	"reflect" // reflect
+	"strings" // strings
+	"testing" // testing
+	"time"    // time
+
+	"github.com/thorstenrie/lpdice/runner" // runner
+	"github.com/thorstenrie/tserr"         // tserr
+)
+
+// testBot returns a Bot with command count incrementing a counter held in the session state of the user
+// and command echo printing its arguments. The test fails if the Bot cannot be created.
+func testBot(t *testing.T) *Bot {
+	// Create the Runner
+	r, e := runner.New(strings.NewReader(""), io.Discard)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "New", Fn: "Runner", Err: e}))
+	}
+	// Register the commands
+	for _, c := range []*runner.Command{
+		{Key: "count", Function: func(ctx context.Context, args []string) error {
+			c := runner.Session(ctx).(*int)
+			*c++
+			fmt.Fprintln(runner.Output(ctx), *c)
+			return nil
+		}},
+		{Key: "echo", Function: func(ctx context.Context, args []string) error {
+			fmt.Fprintln(runner.Output(ctx), strings.Join(args, " "))
+			return nil
+		}},
+	} {
+		if e = r.Add(c); e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Add", Fn: c.Key, Err: e}))
+		}
+	}
+	// Create the Bot
+	b, e := New(r, func() any { return new(int) })
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "New", Fn: "Bot", Err: e}))
+	}
+	return b
+}
+
+// TestParseLine parses IRC messages. The test fails if the parsed message does not match.
+func TestParseLine(t *testing.T) {
+	// tc holds the messages and the expected parsed messages
+	tc := []struct {
+		l    string
+		want ircLine
+	}{
+		{"PING :server\r\n", ircLine{"", "PING", []string{"server"}}},
+		{":alice!a@host PRIVMSG #dice :/roll 2d6 + 3\r\n", ircLine{"alice!a@host", "PRIVMSG", []string{"#dice", "/roll 2d6 + 3"}}},
+		{":server 001 dicebot :Welcome\r\n", ircLine{"server", "001", []string{"dicebot", "Welcome"}}},
+		{"join #dice", ircLine{"", "JOIN", []string{"#dice"}}},
+	}
+	// Iterate all messages
+	for _, c := range tc {
+		if m := parseLine(c.l); !reflect.DeepEqual(m, c.want) {
+			t.Errorf("parseLine(%q) = %+v, want %+v", c.l, m, c.want)
+		}
+	}
+}
+
+// TestHandle handles messages of two users. The test fails if the users share the session state,
+// messages without prefix are answered or the reply does not match.
+func TestHandle(t *testing.T) {
+	b := testBot(t)
+	b.SetPrefix("!")
+	// tc holds the messages and the expected replies
+	tc := []struct {
+		user, text string
+		want       []string
+	}{
+		{"alice", "!count", []string{"alice: 1"}},
+		{"alice", "count", nil},
+		{"bob", " !count", []string{"bob: 1"}},
+		{"alice", "!count", []string{"alice: 2"}},
+		{"bob", "!echo 'a  b' c", []string{"bob: a  b c"}},
+		{"bob", "!", nil},
+	}
+	// Iterate all messages
+	for _, c := range tc {
+		l, e := b.Handle(context.Background(), Message{Channel: "#dice", User: c.user, Text: c.text})
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Handle", Fn: c.text, Err: e}))
+		}
+		if !reflect.DeepEqual(l, c.want) {
+			t.Errorf("Handle(%q) = %q, want %q", c.text, l, c.want)
+		}
+	}
+	// Errors are returned as reply
+	if l, _ := b.Handle(context.Background(), Message{Channel: "#dice", User: "bob", Text: "!unknown"}); len(l) != 1 || !strings.HasPrefix(l[0], "bob: Error: ") {
+		t.Errorf("invalid error reply %q", l)
+	}
+}
+
+// ircServer is an in-process IRC stand-in accepting a single client
+type ircServer struct {
+	t *testing.T     // test
+	l net.Listener   // listener
+	c net.Conn       // connection of the client
+	s *bufio.Scanner // scanner of the connection
+}
+
+// newIRCServer starts an IRC stand-in listening on a local TCP port.
+func newIRCServer(t *testing.T) *ircServer {
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Listen", Fn: "IRC", Err: e}))
+	}
+	t.Cleanup(func() { l.Close() })
+	return &ircServer{t: t, l: l}
+}
+
+// accept accepts the client, answers the registration with a ping and a welcome reply and expects
+// the client to join channel #dice. It reports test failures to the channel errc.
+func (s *ircServer) accept(errc chan<- error) {
+	var e error
+	if s.c, e = s.l.Accept(); e != nil {
+		errc <- e
+		return
+	}
+	s.c.SetDeadline(time.Now().Add(5 * time.Second))
+	s.s = bufio.NewScanner(s.c)
+	for _, want := range []string{"NICK dicebot", "USER dicebot 0 * :dicebot"} {
+		if e = s.expect(want); e != nil {
+			errc <- e
+			return
+		}
+	}
+	s.send("PING :standin")
+	if e = s.expect("PONG :standin"); e != nil {
+		errc <- e
+		return
+	}
+	s.send(":standin 001 dicebot :Welcome")
+	errc <- s.expect("JOIN #dice")
+}
+
+// send sends IRC message l to the client.
+func (s *ircServer) send(l string) {
+	fmt.Fprintf(s.c, "%s\r\n", l)
+}
+
+// expect reads the next message of the client. It returns an error, if the message does not equal want.
+func (s *ircServer) expect(want string) error {
+	if !s.s.Scan() {
+		return tserr.NotAvailable(&tserr.NotAvailableArgs{S: want, Err: fmt.Errorf("read failed: %v", s.s.Err())})
+	}
+	if s.s.Text() != want {
+		return tserr.EqualStr(&tserr.EqualStrArgs{Var: "IRC message", Actual: s.s.Text(), Want: want})
+	}
+	return nil
+}
+
+// TestIRC runs the Bot connected to an in-process IRC stand-in. The test fails if the replies to channel
+// and direct messages do not match or the Bot does not quit when the context is cancelled.
+func TestIRC(t *testing.T) {
+	// Start the IRC stand-in and connect the IRC transport
+	s := newIRCServer(t)
+	errc := make(chan error, 1)
+	go s.accept(errc)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	i, e := DialIRC(ctx, s.l.Addr().String(), "dicebot", "#dice")
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "DialIRC", Fn: "stand-in", Err: e}))
+	}
+	if e = <-errc; e != nil {
+		t.Fatal(e)
+	}
+	// Run the Bot
+	rctx, stop := context.WithCancel(ctx)
+	go func() { errc <- testBot(t).Run(rctx, i) }()
+	// tc holds the messages and the expected replies
+	tc := []struct{ msg, want string }{
+		{":alice!a@host PRIVMSG #dice :/count", "PRIVMSG #dice :alice: 1"},
+		{":alice!a@host PRIVMSG #dice :hello", ""},
+		{":standin NOTICE #dice :ignored", ""},
+		{":bob!b@host PRIVMSG #dice :/count", "PRIVMSG #dice :bob: 1"},
+		{":alice!a@host PRIVMSG #dice :/count", "PRIVMSG #dice :alice: 2"},
+		{":bob!b@host PRIVMSG dicebot :/echo direct", "PRIVMSG bob :bob: direct"},
+	}
+	// Iterate all messages
+	for _, c := range tc {
+		s.send(c.msg)
+		if c.want == "" {
+			continue
+		}
+		if e = s.expect(c.want); e != nil {
+			t.Error(e)
+		}
+	}
+	// Cancelling the context ends the Bot, which quits the IRC session
+	stop()
+	if e = <-errc; e != nil {
+		t.Error(tserr.Op(&tserr.OpArgs{Op: "Run", Fn: "Bot", Err: e}))
+	}
+	if e = s.expect("QUIT :bye"); e != nil {
+		t.Error(e)
+	}
+}
@@ -1,27 +1,341 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/thorstenrie/lpdice"
+	"github.com/thorstenrie/lpdice/bot"
+	"github.com/thorstenrie/lpdice/limit"
+	"github.com/thorstenrie/lpdice/metrics"
+	"github.com/thorstenrie/lpdice/runner"
+	"github.com/thorstenrie/lpdice/service"
+	"github.com/thorstenrie/tsfio"
 )
 
 func main() {
 
-	d, _ = lpdice.NewD6()
+	f := flag.String("format", "text", "output format text, json, csv or yaml")
+	flag.Parse()
+	if e := setFormat(*f); e != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+		os.Exit(2)
+	}
 
-	AppName("dice")
-	HelpText("Throw a die")
-	Version("0.1.0")
-	HelpCommand("help")
-	Add(&Command{Key: "roll", Function: roll, Help: "Roll the die"})
-	Add(&Command{Key: "sides", Function: sides, Help: "New die with {4, 6, 8, 10, 12, 20} sides and no seed"})
-	Add(&Command{Key: "seed", Function: seed, Help: "Set seed"})
-	Add(&Command{Key: "stop", Function: stop, Help: "Exit application"})
-	SetExit("stop")
+	r, e := newRunner(os.Stdin, os.Stdout)
+	if e != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+		os.Exit(1)
+	}
 
 	ctx := context.Background()
-	//ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
-	Run(ctx)
-	//cancel()
+	if flag.Arg(0) == "serve" {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		c := serve(ctx, flag.Args()[1:])
+		stop()
+		os.Exit(c)
+	}
+	if flag.Arg(0) == "bot" {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		c := chatBot(ctx, r, flag.Args()[1:])
+		stop()
+		os.Exit(c)
+	}
+	if flag.Arg(0) == "daemon" {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		c := daemon(ctx, r, flag.Args()[1:])
+		stop()
+		os.Exit(c)
+	}
+	if e := addLocal(r); e != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+		os.Exit(1)
+	}
+	ctx = runner.WithSession(ctx, newState())
+	if flag.Arg(0) == "run" {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		c := script(ctx, r, flag.Args()[1:], os.Stderr)
+		stop()
+		os.Exit(c)
+	}
+	if e := r.RunSignals(ctx, os.Interrupt, syscall.SIGTERM); e != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+		os.Exit(1)
+	}
+}
+
+func newRunner(in io.Reader, out io.Writer) (*runner.Runner, error) {
+	r, e := runner.New(in, out)
+	if e != nil {
+		return nil, e
+	}
+	r.AppName("dice")
+	r.HelpText("Throw a die")
+	r.Version("0.1.0")
+	for _, c := range []*runner.Command{
+		{Key: "roll", Handler: roll, Help: "Roll the die or a dice expression, e.g., roll 2d6 + 3",
+			Args: []runner.Arg{{Name: "expression", Optional: true, Rest: true}}},
+		{Key: "sides", Handler: sides, Help: "New die with {4, 6, 8, 10, 12, 20} sides and no seed",
+			Args: []runner.Arg{{Name: "sides", Type: runner.Int, Choices: []string{"4", "6", "8", "10", "12", "20"}}}},
+		{Key: "seed", Handler: seed, Help: "Set integer or string seed, optionally derived for a path from a master seed, e.g., seed 42 player2/attack",
+			Args:  []runner.Arg{{Name: "seed"}, {Name: "path", Optional: true}},
+			Flags: []runner.Flag{{Name: "algorithm", Default: "tsrand", Help: "algorithm tsrand, pcg-v1, chacha8-v1 or xoshiro256-v1"}}},
+		{Key: "fair", Handler: setFair, Help: "Set fair mode off, bag or luck to limit streaks of equal results, e.g., fair bag --strength=2",
+			Args:  []runner.Arg{{Name: "mode", Choices: []string{"off", "bag", "luck"}}},
+			Flags: []runner.Flag{{Name: "strength", Type: runner.Int, Default: "1", Help: "copies of each face in the bag or weight of faces not rolled for a while"}}},
+		{Key: "check", Handler: check, Help: "Roll a check against a difficulty and print its degree of success, e.g., check 15 1d20 --modifier=5",
+			Args:  []runner.Arg{{Name: "dc", Type: runner.Int}, {Name: "expression", Rest: true}},
+			Flags: []runner.Flag{{Name: "modifier", Type: runner.Int, Default: "0", Help: "modifier added to the result"}}},
+		{Key: "chart", Handler: chart, Help: "Chart history or distribution of a dice expression, e.g., chart 2d6 --width=20",
+			Args:  []runner.Arg{{Name: "expression", Optional: true, Rest: true}},
+			Flags: []runner.Flag{{Name: "width", Type: runner.Int, Default: "40", Help: "width of the longest bar, at most 500"}}},
+		{Key: "format", Handler: format, Help: "Set output format",
+			Args: []runner.Arg{{Name: "format", Choices: []string{"text", "json", "csv", "yaml"}}}},
+		{Key: "history", Handler: printHistory, Help: "Print history of the last 10000 results", Sub: []*runner.Command{
+			{Key: "clear", Handler: clearHistory, Help: "Clear history of results"},
+		}},
+		{Key: "stats", Handler: stats, Help: "Print count, mean, variance, minimum and maximum of results"},
+		{Key: "stop", Handler: stop, Help: "Exit application"},
+	} {
+		limitCommand(c)
+		if e := r.Add(c); e != nil {
+			return nil, e
+		}
+	}
+	if e := r.HelpCommand("help"); e != nil {
+		return nil, e
+	}
+	if e := r.SetExit("stop"); e != nil {
+		return nil, e
+	}
+	return r, nil
+}
+
+func limitCommand(c *runner.Command) {
+	if c.Handler != nil && c.Key != "stop" {
+		c.Handler = limited(c.Handler)
+	}
+	for _, sc := range c.Sub {
+		limitCommand(sc)
+	}
+}
+
+func addLocal(r *runner.Runner) error {
+	for _, c := range []*runner.Command{
+		{Key: "record", Handler: record, Help: "Record the random numbers of the die", Sub: []*runner.Command{
+			{Key: "save", Handler: saveRecording, Help: "Save the recorded random numbers to a file",
+				Args: []runner.Arg{{Name: "file"}}},
+		}},
+		{Key: "replay", Handler: replay, Help: "Replay recorded random numbers from a file with the die",
+			Args: []runner.Arg{{Name: "file"}}},
+		{Key: "table", Handler: rollTable, Help: "Roll on a loaded table and its nested tables with the die, e.g., table encounter",
+			Args: []runner.Arg{{Name: "table"}}, Sub: []*runner.Command{
+				{Key: "load", Handler: loadTables, Help: "Load roll tables from a JSON or CSV file",
+					Args: []runner.Arg{{Name: "file"}}},
+			}},
+	} {
+		if e := r.Add(c); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func script(ctx context.Context, r *runner.Runner, args []string, errs io.Writer) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(errs)
+	cont := fs.Bool("continue", false, "continue with the next command on error")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dice run [-continue] script.dice\n")
+		fs.PrintDefaults()
+	}
+	if e := fs.Parse(args); e != nil {
+		return 2
+	}
+	r.SetErrOutput(errs)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	b, e := tsfio.ReadFile(tsfio.Filename(fs.Arg(0)))
+	if e != nil {
+		fmt.Fprintf(errs, "Error: %s\n", e)
+		return 1
+	}
+	if e := r.RunScript(ctx, bytes.NewReader(b), fs.Arg(0), *cont); e != nil {
+		fmt.Fprintf(errs, "Error: %s\n", e)
+		return 1
+	}
+	return 0
+}
+
+func serve(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	rate := fs.Float64("rate", 0, "requests per second per client, 0 disables rate limiting")
+	burst := fs.Int("burst", 10, "maximum burst of requests per client")
+	key := fs.String("key-header", "X-API-Key", "header identifying clients by API key instead of IP address")
+	keys := fs.String("key-file", "", "file with one API key per line, unknown API keys are limited by IP address")
+	dice := fs.Int("max-dice", 0, "maximum number of dice per request, 0 for the default of 100")
+	iter := fs.Int("max-iterations", 0, "maximum number of iterations per simulation, 0 for the default")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dice serve [-addr host:port] [-rate n -burst n [-key-file file]] [-max-dice n] [-max-iterations n]\n")
+		fs.PrintDefaults()
+	}
+	if e := fs.Parse(args); e != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	h := service.NewHandler()
+	if e := h.SetCaps(*dice, *iter); e != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+		return 2
+	}
+	var api http.Handler = h
+	if *rate > 0 {
+		l, e := limit.NewLimiter(*rate, *burst)
+		if e != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+			return 2
+		}
+		var k []string
+		if *keys != "" {
+			b, e := tsfio.ReadFile(tsfio.Filename(*keys))
+			if e != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+				return 2
+			}
+			k = strings.Fields(string(b))
+		}
+		kf := limit.ByAPIKey(*key, k...)
+		h.SetLimiter(l, kf)
+		api = l.Middleware(kf, h)
+	}
+	reg := metrics.NewRegistry()
+	m, e := metrics.NewDice(reg)
+	if e != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+		return 1
+	}
+	lpdice.SetObserver(m)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", reg)
+	mux.Handle("/", api)
+	srv := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	srv.RegisterOnShutdown(h.Close)
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	fmt.Fprintf(os.Stderr, "Serving dice on http://%s\n", *addr)
+	select {
+	case e := <-errc:
+		fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+		return 1
+	case <-ctx.Done():
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if e := srv.Shutdown(sctx); e != nil && !errors.Is(e, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+		return 1
+	}
+	return 0
+}
+
+func daemon(ctx context.Context, r *runner.Runner, args []string) int {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	network := fs.String("network", "tcp", "network tcp or unix")
+	addr := fs.String("addr", "localhost:7000", "address or socket path to listen on")
+	dice, rate, burst := remoteFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dice daemon [-network tcp|unix] [-addr address] [-max-dice n] [-rate n -burst n]\n")
+		fs.PrintDefaults()
+	}
+	if e := fs.Parse(args); e != nil {
+		return 2
+	}
+	if fs.NArg() != 0 || (*network != "tcp" && *network != "unix") {
+		fs.Usage()
+		return 2
+	}
+	ns, e := remoteState(*dice, *rate, *burst)
+	if e != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+		return 2
+	}
+	l, e := net.Listen(*network, *addr)
+	if e != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Serving dice on %s %s\n", *network, l.Addr())
+	r.SetPrompt(true)
+	if e := r.Serve(ctx, l, ns); e != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+		return 1
+	}
+	return 0
+}
+
+func remoteFlags(fs *flag.FlagSet) (*int, *float64, *int) {
+	dice := fs.Int("max-dice", 0, "maximum number of dice per expression, 0 for the default of 100")
+	rate := fs.Float64("rate", 5, "commands per second of each connection or user")
+	burst := fs.Int("burst", 10, "maximum burst of commands of each connection or user")
+	return dice, rate, burst
+}
+
+func chatBot(ctx context.Context, r *runner.Runner, args []string) int {
+	fs := flag.NewFlagSet("bot", flag.ContinueOnError)
+	addr := fs.String("irc", "localhost:6667", "address of the IRC server")
+	nick := fs.String("nick", "dicebot", "nick of the bot")
+	channel := fs.String("channel", "#dice", "channel to join")
+	prefix := fs.String("prefix", "/", "command prefix")
+	dice, rate, burst := remoteFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dice bot [-irc host:port] [-nick nick] [-channel #channel] [-prefix /] [-max-dice n] [-rate n -burst n]\n")
+		fs.PrintDefaults()
+	}
+	if e := fs.Parse(args); e != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	var b *bot.Bot
+	ns, e := remoteState(*dice, *rate, *burst)
+	if e == nil {
+		b, e = bot.New(r, ns)
+	}
+	if e == nil {
+		e = b.SetPrefix(*prefix)
+	}
+	if e != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+		return 2
+	}
+	t, e := bot.DialIRC(ctx, *addr, *nick, *channel)
+	if e != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Bot %s joined %s on %s\n", *nick, *channel, *addr)
+	if e := b.Run(ctx, t); e != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+		return 1
+	}
+	return 0
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thorstenrie/lpdice/runner"
+)
+
+func runScript(t *testing.T, src string, args ...string) (int, []string, string) {
+	t.Helper()
+	fn := filepath.Join(t.TempDir(), "script.dice")
+	if e := os.WriteFile(fn, []byte(src), 0o600); e != nil {
+		t.Fatal(e)
+	}
+	var out, errs bytes.Buffer
+	r, e := newRunner(strings.NewReader(""), &out)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e := addLocal(r); e != nil {
+		t.Fatal(e)
+	}
+	ctx := runner.WithSession(context.Background(), newState())
+	c := script(ctx, r, append(args, fn), &errs)
+	return c, strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"), errs.String()
+}
+
+func TestScript(t *testing.T) {
+	c, out, errs := runScript(t, "# roll a seeded d20\nsides 20\nseed 42\n\nroll\nroll 2d6+1\n")
+	if c != 0 || errs != "" {
+		t.Fatalf("exit code %d, errors %q", c, errs)
+	}
+	if len(out) != 5 || out[1] != "die seeded with 42" || !strings.HasPrefix(out[3], "2d6+1: ") || !strings.HasPrefix(out[4], "average = ") {
+		t.Errorf("unexpected output %q", out)
+	}
+	// The output of a seeded script is deterministic
+	if _, again, _ := runScript(t, "sides 20\nseed 42\nroll\nroll 2d6+1\n"); strings.Join(again, "\n") != strings.Join(out, "\n") {
+		t.Errorf("seeded scripts differ: %q, %q", out, again)
+	}
+}
+
+func TestScriptError(t *testing.T) {
+	// The script stops at the failing line and calls the exit command
+	c, out, errs := runScript(t, "roll\nroll 2x6\nroll\n")
+	if c != 1 || !strings.Contains(errs, "script.dice:2:") {
+		t.Errorf("exit code %d, errors %q", c, errs)
+	}
+	if len(out) != 2 || !strings.HasPrefix(out[1], "average = ") {
+		t.Errorf("unexpected output %q", out)
+	}
+	// With -continue, the script continues with the next line and fails afterwards
+	c, out, errs = runScript(t, "roll\nroll 2x6\nroll\n", "-continue")
+	if c != 1 || !strings.Contains(errs, "script.dice:2:") || !strings.Contains(errs, "1 lines failed") {
+		t.Errorf("exit code %d, errors %q", c, errs)
+	}
+	if len(out) != 3 || !strings.HasPrefix(out[2], "average = ") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestScriptExit(t *testing.T) {
+	// The exit command ends the script and is not called twice
+	c, out, errs := runScript(t, "roll\nstop\nroll\n")
+	if c != 0 || errs != "" {
+		t.Fatalf("exit code %d, errors %q", c, errs)
+	}
+	if len(out) != 2 || !strings.HasPrefix(out[1], "average = ") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestScriptUsage(t *testing.T) {
+	var errs bytes.Buffer
+	r, _ := newRunner(strings.NewReader(""), &bytes.Buffer{})
+	ctx := runner.WithSession(context.Background(), newState())
+	if c := script(ctx, r, nil, &errs); c != 2 || !strings.Contains(errs.String(), "Usage: dice run") {
+		t.Errorf("exit code %d, errors %q", c, errs.String())
+	}
+	if c := script(ctx, r, []string{filepath.Join(t.TempDir(), "missing.dice")}, &errs); c != 1 {
+		t.Errorf("exit code %d for a missing script", c)
+	}
+}
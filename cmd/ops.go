@@ -1,84 +1,400 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"strconv"
+	"strings"
 
 	"github.com/thorstenrie/lpdice"
-	"github.com/thorstenrie/lpstats"
+	"github.com/thorstenrie/lpdice/limit"
+	"github.com/thorstenrie/lpdice/runner"
+	"github.com/thorstenrie/tsfio"
 )
 
-var history []int
-
-var (
-	d *lpdice.Die
+const (
+	maxHistory  = 10000
+	defaultDice = 100
+	maxDice     = 1000
 )
 
-func roll(ctx context.Context, args []string) error {
-	if len(args) != 0 {
-		return errors.New("Unexpected argument")
+type state struct {
+	history []int
+	st      lpdice.Stats
+	d       *lpdice.Die
+	rec     *lpdice.Recording
+	tables  *lpdice.Tables
+	ft      lpdice.Format
+	enc     *lpdice.Encoder
+	out     io.Writer
+	dice    int
+	lim     *limit.Limiter
+}
+
+var ft lpdice.Format
+
+func parseFormat(f string) (lpdice.Format, error) {
+	t, e := lpdice.ParseFormat(f)
+	if e != nil {
+		return t, errors.New("Format must be text, json, csv or yaml")
 	}
-	r, e := d.Roll()
+	return t, nil
+}
+
+func setFormat(f string) error {
+	var e error
+	ft, e = parseFormat(f)
+	return e
+}
+
+func newState() any {
+	d, _ := lpdice.NewD6()
+	return &state{d: d, ft: ft}
+}
+
+func remoteState(dice int, rate float64, burst int) (runner.SessionFunc, error) {
+	if dice < 0 || dice > maxDice {
+		return nil, fmt.Errorf("Dice cap must be between 0 and %d", maxDice)
+	}
+	if dice == 0 {
+		dice = defaultDice
+	}
+	if _, e := limit.NewLimiter(rate, burst); e != nil {
+		return nil, e
+	}
+	return func() any {
+		s := newState().(*state)
+		s.dice = dice
+		s.lim, _ = limit.NewLimiter(rate, burst)
+		return s
+	}, nil
+}
+
+func session(ctx context.Context) *state {
+	return runner.Session(ctx).(*state)
+}
+
+func (s *state) parse(expr string) (*lpdice.Expr, error) {
+	x, e := lpdice.ParseExpr(expr)
 	if e != nil {
-		return e
+		return nil, e
+	}
+	if e = s.capDice(x); e != nil {
+		return nil, e
+	}
+	return x, nil
+}
+
+func (s *state) capDice(x *lpdice.Expr) error {
+	if n := x.Dice(); s.dice > 0 && n > s.dice {
+		return fmt.Errorf("%d dice exceed the cap of %d dice per expression", n, s.dice)
 	}
-	history = append(history, r)
-	fmt.Printf("%d\n", r)
 	return nil
 }
 
-func stop(ctx context.Context, args []string) error {
-	if len(args) != 0 {
-		return errors.New("Unexpected argument")
+func limited(h runner.ArgsFunc) runner.ArgsFunc {
+	return func(ctx context.Context, v *runner.Values) error {
+		if s := session(ctx); s.lim != nil {
+			if ok, d := s.lim.Allow(""); !ok {
+				return limit.Reject(d)
+			}
+		}
+		return h(ctx, v)
+	}
+}
+
+func (s *state) encoder(ctx context.Context) (*lpdice.Encoder, error) {
+	w := runner.Output(ctx)
+	if s.enc == nil || s.out != w {
+		e := error(nil)
+		if s.enc, e = lpdice.NewEncoder(w, s.ft); e != nil {
+			return nil, e
+		}
+		s.out = w
+	}
+	return s.enc, nil
+}
+
+func roll(ctx context.Context, v *runner.Values) error {
+	s := session(ctx)
+	enc, e := s.encoder(ctx)
+	if e != nil {
+		return e
+	}
+	if v.Has("expression") {
+		x, e := s.parse(v.String("expression"))
+		if e != nil {
+			return e
+		}
+		p, e := x.RollWith(s.d)
+		if e != nil {
+			return e
+		}
+		s.add(p.Total)
+		return enc.EncodePool(p)
+	}
+	r, e := s.d.RollResult()
+	if e != nil {
+		return e
+	}
+	s.add(r.Value)
+	return enc.EncodeRoll(r)
+}
+
+func (s *state) add(v int) {
+	s.history = append(s.history, v)
+	if len(s.history) > maxHistory {
+		s.history = s.history[len(s.history)-maxHistory:]
 	}
-	m, _ := lpstats.ArithmeticMean(history)
-	fmt.Printf("average = %f\n", m)
+	s.st.Add(v)
+}
+
+func format(ctx context.Context, v *runner.Values) error {
+	s := session(ctx)
+	f, e := parseFormat(v.String("format"))
+	if e != nil {
+		return e
+	}
+	s.ft, s.enc = f, nil
+	fmt.Fprintf(runner.Output(ctx), "output format %s\n", v.String("format"))
 	return nil
 }
 
-func sides(ctx context.Context, args []string) error {
-	if len(args) != 1 {
-		return errors.New("Expected one argument")
+func printHistory(ctx context.Context, v *runner.Values) error {
+	s := session(ctx)
+	enc, e := s.encoder(ctx)
+	if e != nil {
+		return e
 	}
-	i, e := strconv.ParseInt(args[0], 10, 0)
+	return enc.EncodeHistory(s.history)
+}
+
+func clearHistory(ctx context.Context, v *runner.Values) error {
+	s := session(ctx)
+	s.history = nil
+	s.st.Reset()
+	fmt.Fprintln(runner.Output(ctx), "history cleared")
+	return nil
+}
+
+func stop(ctx context.Context, v *runner.Values) error {
+	m, _ := session(ctx).st.Mean()
+	fmt.Fprintf(runner.Output(ctx), "average = %f\n", m)
+	return nil
+}
+
+func stats(ctx context.Context, v *runner.Values) error {
+	s := session(ctx)
+	m, e := s.st.Mean()
 	if e != nil {
-		return errors.New("Argument must be an integer")
+		return errors.New("No rolls in history")
 	}
+	vr, _ := s.st.Variance()
+	lo, _ := s.st.Min()
+	hi, _ := s.st.Max()
+	fmt.Fprintf(runner.Output(ctx), "count = %d, mean = %f, variance = %f, min = %d, max = %d\n", s.st.Count(), m, vr, lo, hi)
+	return nil
+}
+
+func sides(ctx context.Context, v *runner.Values) error {
+	s := session(ctx)
+	i := v.Int("sides")
 	switch i {
 	case 4:
-		d, _ = lpdice.NewD4()
+		s.d, _ = lpdice.NewD4()
 	case 6:
-		d, _ = lpdice.NewD6()
+		s.d, _ = lpdice.NewD6()
 	case 8:
-		d, _ = lpdice.NewD8()
+		s.d, _ = lpdice.NewD8()
 	case 10:
-		d, _ = lpdice.NewD10()
+		s.d, _ = lpdice.NewD10()
 	case 12:
-		d, _ = lpdice.NewD12()
+		s.d, _ = lpdice.NewD12()
 	case 20:
-		d, _ = lpdice.NewD20()
-	default:
-		return errors.New("Die has 4, 6, 8, 10, 12 or 20 sides")
+		s.d, _ = lpdice.NewD20()
 	}
-	history = nil
-	fmt.Printf("new die with %d sides and no seed\n", i)
+	s.history = nil
+	s.st.Reset()
+	fmt.Fprintf(runner.Output(ctx), "new die with %d sides and no seed\n", i)
 	return nil
 }
 
-func seed(ctx context.Context, args []string) error {
-	if len(args) != 1 {
-		return errors.New("Expected one argument")
+func seed(ctx context.Context, v *runner.Values) error {
+	d, a := session(ctx).d, v.String("seed")
+	alg, e := lpdice.ParseAlgorithm(v.String("algorithm"))
+	if e != nil {
+		return errors.New("Algorithm must be tsrand, pcg-v1, chacha8-v1 or xoshiro256-v1")
 	}
-	i, e := strconv.ParseInt(args[0], 10, 0)
+	i, e := strconv.ParseInt(a, 10, 64)
 	if e != nil {
-		return errors.New("Argument must be an integer")
+		if v.Has("path") || alg != lpdice.AlgorithmTsrand {
+			return errors.New("Seed must be an integer for a path or an algorithm")
+		}
+		if e = d.SeedString(a); e != nil {
+			return e
+		}
+		fmt.Fprintf(runner.Output(ctx), "die seeded with string %q\n", a)
+		return nil
+	}
+	msg := fmt.Sprintf("die seeded with %d", i)
+	if v.Has("path") {
+		p, e := lpdice.NewSeedTree(i).Path(v.String("path"))
+		if e != nil {
+			return e
+		}
+		i, msg = p.Seed(), msg+" at path "+v.String("path")
 	}
-	e = d.Seed(i)
+	if e := d.SeedWith(alg, i); e != nil {
+		return e
+	}
+	if alg != lpdice.AlgorithmTsrand {
+		msg += " using " + string(alg)
+	}
+	fmt.Fprintln(runner.Output(ctx), msg)
+	return nil
+}
+
+func record(ctx context.Context, v *runner.Values) error {
+	s := session(ctx)
+	rec, e := s.d.Record()
 	if e != nil {
 		return e
 	}
-	fmt.Printf("die seeded with %d\n", i)
+	s.rec = rec
+	fmt.Fprintln(runner.Output(ctx), "recording die")
+	return nil
+}
+
+func saveRecording(ctx context.Context, v *runner.Values) error {
+	s := session(ctx)
+	if s.rec == nil {
+		return errors.New("No recording started")
+	}
+	var b bytes.Buffer
+	if _, e := s.rec.WriteTo(&b); e != nil {
+		return e
+	}
+	fn := v.String("file")
+	if e := tsfio.CheckFile(tsfio.Filename(fn)); e != nil {
+		return e
+	}
+	if e := os.WriteFile(fn, b.Bytes(), 0644); e != nil {
+		return e
+	}
+	fmt.Fprintf(runner.Output(ctx), "%d recorded values saved to %s\n", s.rec.Len(), fn)
+	return nil
+}
+
+func replay(ctx context.Context, v *runner.Values) error {
+	fn := v.String("file")
+	b, e := tsfio.ReadFile(tsfio.Filename(fn))
+	if e != nil {
+		return e
+	}
+	rec, e := lpdice.ReadRecording(bytes.NewReader(b))
+	if e != nil {
+		return e
+	}
+	if e = session(ctx).d.Replay(rec); e != nil {
+		return e
+	}
+	fmt.Fprintf(runner.Output(ctx), "replaying %d recorded values from %s\n", rec.Len(), fn)
+	return nil
+}
+
+func chart(ctx context.Context, v *runner.Values) error {
+	var (
+		dist *lpdice.Distribution
+		e    error
+	)
+	s := session(ctx)
+	c, e := lpdice.NewChart(v.Int("width"))
+	if e != nil {
+		return errors.New("Width must be a positive integer")
+	}
+	if !v.Has("expression") {
+		if len(s.history) == 0 {
+			return errors.New("No rolls in history")
+		}
+		dist, e = lpdice.NewHistogram(s.history)
+	} else {
+		x, err := s.parse(v.String("expression"))
+		if err != nil {
+			return err
+		}
+		dist, e = x.Distribution()
+	}
+	if e != nil {
+		return e
+	}
+	t, e := c.Render(dist)
+	if e != nil {
+		return e
+	}
+	fmt.Fprint(runner.Output(ctx), t)
+	return nil
+}
+
+func loadTables(ctx context.Context, v *runner.Values) error {
+	fn := v.String("file")
+	t, e := lpdice.LoadTables(tsfio.Filename(fn))
+	if e != nil {
+		return e
+	}
+	session(ctx).tables = t
+	fmt.Fprintf(runner.Output(ctx), "loaded tables %s from %s\n", strings.Join(t.Names(), ", "), fn)
+	return nil
+}
+
+func rollTable(ctx context.Context, v *runner.Values) error {
+	s := session(ctx)
+	if s.tables == nil {
+		return errors.New("No tables loaded")
+	}
+	r, e := s.tables.RollWith(v.String("table"), s.d)
+	if e != nil {
+		return e
+	}
+	for _, t := range r {
+		fmt.Fprintf(runner.Output(ctx), "%s: %d %s\n", t.Table, t.Roll, t.Result)
+	}
+	return nil
+}
+
+func setFair(ctx context.Context, v *runner.Values) error {
+	m := map[string]lpdice.FairMode{"off": lpdice.FairOff, "bag": lpdice.FairBag, "luck": lpdice.FairLuck}[v.String("mode")]
+	if e := session(ctx).d.SetFair(m, v.Int("strength")); e != nil {
+		return e
+	}
+	fmt.Fprintf(runner.Output(ctx), "fair mode %s\n", v.String("mode"))
+	return nil
+}
+
+func check(ctx context.Context, v *runner.Values) error {
+	s := session(ctx)
+	k, e := lpdice.NewCheck(v.String("expression"), v.Int("modifier"), v.Int("dc"))
+	if e != nil {
+		return e
+	}
+	if e = s.capDice(k.Expr()); e != nil {
+		return e
+	}
+	r, e := k.RollWith(s.d)
+	if e != nil {
+		return e
+	}
+	p, e := k.Probabilities()
+	if e != nil {
+		return e
+	}
+	w := runner.Output(ctx)
+	fmt.Fprintf(w, "%s %+d = %d vs %d: %s\n", r.Pool.Expr, v.Int("modifier"), r.Total, r.DC, r.Degree)
+	for g, q := range p {
+		fmt.Fprintf(w, "%s %.2f%%\n", lpdice.Degree(g), 100*q)
+	}
 	return nil
 }
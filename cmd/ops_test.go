@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/thorstenrie/lpdice/runner"
+)
+
+func TestHistory(t *testing.T) {
+	s := newState().(*state)
+	for i := 0; i < maxHistory+5; i++ {
+		s.add(i)
+	}
+	if len(s.history) != maxHistory || s.history[0] != 5 || s.history[maxHistory-1] != maxHistory+4 {
+		t.Errorf("history of %d results from %d to %d", len(s.history), s.history[0], s.history[len(s.history)-1])
+	}
+	if n := s.st.Count(); n != maxHistory+5 {
+		t.Errorf("stats of %d results, expected %d", n, maxHistory+5)
+	}
+}
+
+func TestRemoteState(t *testing.T) {
+	ns, e := remoteState(0, 0.01, 3)
+	if e != nil {
+		t.Fatal(e)
+	}
+	var out bytes.Buffer
+	r, e := newRunner(strings.NewReader(""), &out)
+	if e != nil {
+		t.Fatal(e)
+	}
+	ctx := runner.WithSession(context.Background(), ns())
+	// Expressions of more than 100 dice are rejected
+	for _, l := range []string{"roll 101d6", "check 15 100d6+1d20", "chart 101d10"} {
+		if _, e := r.Exec(ctx, l); e == nil || !strings.Contains(e.Error(), "cap of 100 dice") {
+			t.Errorf("%s: expected dice cap error, got %v", l, e)
+		}
+	}
+	// The fourth command exceeds the burst of three commands, but stop is not limited
+	if _, e := r.Exec(ctx, "roll 100d6"); e == nil || !strings.Contains(e.Error(), "rate limit exceeded") {
+		t.Errorf("expected rate limit error, got %v", e)
+	}
+	if _, e := r.Exec(ctx, "stop"); e != nil {
+		t.Errorf("stop: %v", e)
+	}
+	// Local sessions are not capped
+	if _, e := r.Exec(runner.WithSession(context.Background(), newState()), "roll 101d6"); e != nil {
+		t.Errorf("local roll: %v", e)
+	}
+	for _, c := range [][3]float64{{-1, 1, 1}, {maxDice + 1, 1, 1}, {0, 0, 1}, {0, 1, 0}} {
+		if _, e := remoteState(int(c[0]), c[1], int(c[2])); e == nil {
+			t.Errorf("remoteState%v returned nil error", c)
+		}
+	}
+}
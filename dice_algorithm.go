@@ -0,0 +1,142 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages as well as tserr and tsrand
+import (
+	"encoding/binary"     // binary
+	"math/rand"           // rand
+	randv2 "math/rand/v2" // rand/v2
+
+	"github.com/thorstenrie/tserr"  // tserr
+	"github.com/thorstenrie/tsrand" // tsrand
+)
+
+// An Algorithm identifies the deterministic pseudo-random number generator of a seeded Die together with the
+// derivation of its state from the seed and the mapping of its values to results. The identifier of a versioned
+// Algorithm, e.g., pcg-v1, guarantees the same series of results for the same seed across releases of Go and lpdice.
+// If any part of a versioned Algorithm changes, it gets a new identifier, e.g., pcg-v2.
+type Algorithm string
+
+// Available algorithms
+const (
+	// AlgorithmTsrand is the unversioned deterministic pseudo-random number generator of tsrand used by Seed.
+	AlgorithmTsrand Algorithm = "tsrand"
+	// AlgorithmPCGv1 is PCG-DXSM of math/rand/v2. Seed s sets its two state words to the 64-bit two's complement
+	// of s and its SplitMix64 finalization.
+	AlgorithmPCGv1 Algorithm = "pcg-v1"
+	// AlgorithmChaCha8v1 is ChaCha8 of math/rand/v2. Seed s sets its 256-bit seed to the first four outputs of
+	// SplitMix64 with state s in little-endian byte order.
+	AlgorithmChaCha8v1 Algorithm = "chacha8-v1"
+	// AlgorithmXoshiro256v1 is xoshiro256** used by Seed256, Seed128, SeedBytes and SeedString. Seed s sets its
+	// state to the first four outputs of SplitMix64 with state s.
+	AlgorithmXoshiro256v1 Algorithm = "xoshiro256-v1"
+)
+
+// ParseAlgorithm returns the Algorithm with identifier s, e.g., pcg-v1. It returns an error, if s is not an available Algorithm.
+func ParseAlgorithm(s string) (Algorithm, error) {
+	switch a := Algorithm(s); a {
+	case AlgorithmTsrand, AlgorithmPCGv1, AlgorithmChaCha8v1, AlgorithmXoshiro256v1:
+		return a, nil
+	}
+	return "", tserr.NotExistent("algorithm " + s)
+}
+
+// v2Source adapts a source of math/rand/v2 to math/rand. The results of a die are mapped from the values of the
+// source by rand.Rand.Intn of math/rand, which is frozen. Int63 returns the upper 63 bits of the next 64-bit value.
+type v2Source struct {
+	src randv2.Source // source of math/rand/v2
+}
+
+// Uint64 returns the next 64-bit value of the source.
+func (s *v2Source) Uint64() uint64 {
+	return s.src.Uint64()
+}
+
+// Int63 returns the upper 63 bits of the next 64-bit value of the source.
+func (s *v2Source) Int63() int64 {
+	return int64(s.src.Uint64() >> 1)
+}
+
+// Seed is empty, the source is seeded when it is created.
+func (s *v2Source) Seed(int64) {}
+
+// newSeeded returns a new deterministic random number generator of Algorithm a seeded with s. AlgorithmXoshiro256v1
+// is seeded by Die.seed256 instead. It returns nil and an error, if a is not an available Algorithm or the random
+// number generator cannot be created.
+func newSeeded(a Algorithm, s int64) (*rand.Rand, error) {
+	switch a {
+	case AlgorithmTsrand:
+		// Create the random number generator of tsrand
+		r, e := tsrand.NewDeterministicRand()
+		if e != nil {
+			return nil, tserr.NotAvailable(&tserr.NotAvailableArgs{S: "tsrand.NewDeterministicRand", Err: e})
+		}
+		r.Seed(s)
+		return r, nil
+	case AlgorithmPCGv1:
+		// Create PCG-DXSM with the seed and its SplitMix64 finalization
+		return rand.New(&v2Source{src: randv2.NewPCG(uint64(s), mix(uint64(s)))}), nil
+	case AlgorithmChaCha8v1:
+		// Create ChaCha8 with the SplitMix64 expansion of the seed
+		var b [32]byte
+		for i, w := range expand(s) {
+			binary.LittleEndian.PutUint64(b[8*i:], w)
+		}
+		return rand.New(&v2Source{src: randv2.NewChaCha8(b)}), nil
+	}
+	return nil, tserr.NotExistent("algorithm " + string(a))
+}
+
+// SeedWith seeds Die d with seed s using Algorithm a. Rolling the seeded die returns a deterministic series of results,
+// which is stable across releases for versioned algorithms, e.g., AlgorithmPCGv1. SeedWith with AlgorithmTsrand equals
+// Seed. SeedWith with AlgorithmXoshiro256v1 equals Seed256 with the SplitMix64 expansion of s, so that a seed gives a
+// single stream. It returns an error, if a is not an available Algorithm.
+func (d *Die) SeedWith(a Algorithm, s int64) error {
+	// Return an error if d is nil
+	if d == nil {
+		return tserr.NilPtr()
+	}
+	// Initialize the die if not initialized yet
+	if e := d.notSet(); e != nil {
+		// Return an error if the initialization fails
+		return e
+	}
+	// Seed AlgorithmXoshiro256v1 with the SplitMix64 expansion of s as described for Seed256
+	if a == AlgorithmXoshiro256v1 {
+		d.seed256(expand(s))
+		return nil
+	}
+	// Create the seeded random number generator
+	r, e := newSeeded(a, s)
+	if e != nil {
+		return e
+	}
+	// Set the deterministic and the currently used random number generators
+	d.drnd, d.alg, d.grnd = r, a, r
+	// Start the FairMode, if any, in its initial state
+	d.fr.reset()
+	// Return nil
+	return nil
+}
+
+// Algorithm returns the Algorithm of the deterministic random number generator of Die d, which is AlgorithmTsrand,
+// if d has not been seeded with SeedWith or one of the 256-bit seeds, e.g., Seed256, and AlgorithmXoshiro256v1, if
+// d has last been seeded with a 256-bit seed.
+func (d *Die) Algorithm() Algorithm {
+	if d == nil || d.alg == "" {
+		return AlgorithmTsrand
+	}
+	return d.alg
+}
+
+// SeedWith seeds the dice expression x with seed s using Algorithm a as described for Die.SeedWith.
+func (x *Expr) SeedWith(a Algorithm, s int64) error {
+	// Return an error if x is nil
+	if x == nil {
+		return tserr.NilPtr()
+	}
+	// Seed the Die providing the random number generators
+	return x.d.SeedWith(a, s)
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages as well as tserr
+import (
+	"encoding/binary"     // binary
+	randv2 "math/rand/v2" // rand/v2
+	"testing"             // testing
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// golden holds the first twelve results of a d20 seeded with 42 for each versioned Algorithm. The results
+// must never change. If an Algorithm needs to change, a new versioned Algorithm must be added instead.
+var (
+	golden = map[Algorithm][12]int{
+		AlgorithmPCGv1:        {3, 1, 5, 14, 7, 9, 9, 18, 17, 20, 2, 19},
+		AlgorithmChaCha8v1:    {9, 2, 19, 12, 20, 12, 7, 20, 8, 1, 3, 14},
+		AlgorithmXoshiro256v1: {20, 12, 6, 20, 8, 5, 15, 14, 14, 4, 19, 1},
+	}
+)
+
+// TestAlgorithmGolden rolls a d20 seeded with 42 for each versioned Algorithm. The test fails if
+// the results do not match the golden results.
+func TestAlgorithmGolden(t *testing.T) {
+	for a, want := range golden {
+		d, _ := NewD20()
+		if e := d.SeedWith(a, 42); e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "SeedWith", Fn: string(a), Err: e}))
+		}
+		var got [12]int
+		for i := range got {
+			got[i], _ = d.Roll()
+		}
+		if got != want {
+			t.Errorf("%v: results %v do not match golden results %v", a, got, want)
+		}
+		if d.Algorithm() != a {
+			t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "algorithm", Actual: string(d.Algorithm()), Want: string(a)}))
+		}
+	}
+}
+
+// TestAlgorithmSource compares the values of the versioned algorithms with equally seeded generators of math/rand/v2.
+// The test fails if the values differ, which means that the derivation of the state from the seed changed.
+func TestAlgorithmSource(t *testing.T) {
+	// The SplitMix64 expansion of 42 starts with the reference output of SplitMix64
+	w := expand(42)
+	if w[0] != 0xbdd732262feb6e95 {
+		t.Errorf("SplitMix64 output %x does not match reference output bdd732262feb6e95", w[0])
+	}
+	// b holds the seed of ChaCha8 in little-endian byte order
+	var b [32]byte
+	for i, v := range w {
+		binary.LittleEndian.PutUint64(b[8*i:], v)
+	}
+	// Compare the generators of lpdice with the generators of math/rand/v2
+	for _, c := range []struct {
+		a Algorithm
+		s randv2.Source
+	}{
+		{AlgorithmPCGv1, randv2.NewPCG(42, mix(42))},
+		{AlgorithmChaCha8v1, randv2.NewChaCha8(b)},
+	} {
+		r, _ := newSeeded(c.a, 42)
+		for i := 0; i < 10; i++ {
+			if got, want := r.Uint64(), c.s.Uint64(); got != want {
+				t.Errorf("%v: value %v does not match %v of math/rand/v2", c.a, got, want)
+			}
+		}
+	}
+}
+
+// TestAlgorithmSeed256 seeds dice with 256-bit seeds after seeding them with SeedWith. The test fails if the
+// Algorithm is not AlgorithmXoshiro256v1, AlgorithmXoshiro256v1 does not equal Seed256 with the SplitMix64
+// expansion of the seed or Seed does not reset the Algorithm.
+func TestAlgorithmSeed256(t *testing.T) {
+	// Each 256-bit seed sets the Algorithm
+	for n, f := range map[string]func(d *Die) error{
+		"Seed256":    func(d *Die) error { return d.Seed256([32]byte{1}) },
+		"Seed128":    func(d *Die) error { return d.Seed128([16]byte{1}) },
+		"SeedBytes":  func(d *Die) error { return d.SeedBytes([]byte{1}) },
+		"SeedString": func(d *Die) error { return d.SeedString("campaign") },
+	} {
+		d, _ := NewD20()
+		d.SeedWith(AlgorithmPCGv1, 7)
+		if e := f(d); e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: n, Fn: "d20", Err: e}))
+		}
+		if d.Algorithm() != AlgorithmXoshiro256v1 {
+			t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "algorithm after " + n, Actual: string(d.Algorithm()), Want: string(AlgorithmXoshiro256v1)}))
+		}
+		if d.Seed(7); d.Algorithm() != AlgorithmTsrand {
+			t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "algorithm after Seed", Actual: string(d.Algorithm()), Want: string(AlgorithmTsrand)}))
+		}
+	}
+	// AlgorithmXoshiro256v1 equals Seed256 with the SplitMix64 expansion of the seed
+	var b [32]byte
+	for i, v := range expand(42) {
+		binary.LittleEndian.PutUint64(b[8*i:], v)
+	}
+	x, _ := NewD20()
+	y, _ := NewD20()
+	x.SeedWith(AlgorithmXoshiro256v1, 42)
+	y.Seed256(b)
+	for i := 0; i < 10; i++ {
+		v, _ := x.Roll()
+		w, _ := y.Roll()
+		if v != w {
+			t.Error(tserr.Equal(&tserr.EqualArgs{Var: "roll of xoshiro256-v1", Actual: int64(v), Want: int64(w)}))
+		}
+	}
+}
+
+// TestAlgorithm seeds dice with AlgorithmTsrand and invalid algorithms and switches back to Seed. The test fails
+// if AlgorithmTsrand does not equal Seed, Seed does not reset the Algorithm or invalid algorithms are accepted.
+func TestAlgorithm(t *testing.T) {
+	// SeedWith AlgorithmTsrand equals Seed
+	a, _ := NewD20()
+	b, _ := NewD20()
+	a.SeedWith(AlgorithmTsrand, 7)
+	b.Seed(7)
+	for i := 0; i < 10; i++ {
+		x, _ := a.Roll()
+		y, _ := b.Roll()
+		if x != y {
+			t.Error(tserr.Equal(&tserr.EqualArgs{Var: "roll of tsrand", Actual: int64(x), Want: int64(y)}))
+		}
+	}
+	// Seed resets the Algorithm
+	a.SeedWith(AlgorithmPCGv1, 7)
+	a.Seed(7)
+	b.Seed(7)
+	if x, _ := a.Roll(); a.Algorithm() != AlgorithmTsrand {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "algorithm after Seed", Actual: string(a.Algorithm()), Want: string(AlgorithmTsrand)}))
+	} else if y, _ := b.Roll(); x != y {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "roll after Seed", Actual: int64(x), Want: int64(y)}))
+	}
+	// Parse the identifiers of the algorithms
+	for _, s := range []string{"tsrand", "pcg-v1", "chacha8-v1", "xoshiro256-v1"} {
+		if p, e := ParseAlgorithm(s); e != nil || string(p) != s {
+			t.Error(tserr.Op(&tserr.OpArgs{Op: "ParseAlgorithm", Fn: s, Err: e}))
+		}
+	}
+	// Invalid algorithms and nil pointers return an error
+	if _, e := ParseAlgorithm("pcg"); e == nil {
+		t.Error(tserr.NilFailed("ParseAlgorithm"))
+	}
+	if e := a.SeedWith("pcg", 1); e == nil {
+		t.Error(tserr.NilFailed("SeedWith"))
+	}
+	var (
+		d *Die
+		x *Expr
+	)
+	if d.SeedWith(AlgorithmPCGv1, 1) == nil || x.SeedWith(AlgorithmPCGv1, 1) == nil {
+		t.Error(tserr.NilFailed("SeedWith"))
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import tserr
+import (
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// A Bag draws items at random from an unordered collection, e.g., tokens from a chaos bag or tiles from a bag. Unlike
+// a Deck, a Bag has no order and no discard pile: Draw removes a uniformly chosen item, Pick returns a uniformly chosen
+// item without removing it and Put puts items back into the bag at any time, which are immediately drawable again. A
+// Bag uses the same random number generators as a Die: it draws with a cryptographically secure random number
+// generator, or a deterministic pseudo-random number generator after Seed. A Bag is not safe for concurrent use by
+// multiple goroutines.
+type Bag[T any] struct {
+	d     *Die // Die providing the random number generators
+	items []T  // items in the bag
+}
+
+// NewBag returns a pointer to a new Bag holding items. The items are copied. It returns nil and an error, if any.
+func NewBag[T any](items ...T) (*Bag[T], error) {
+	// Create the Die providing the random number generators
+	d, e := newDie(defaultN)
+	if e != nil {
+		return nil, e
+	}
+	// Return the Bag
+	return &Bag[T]{d: d, items: append([]T(nil), items...)}, nil
+}
+
+// Draw removes a uniformly chosen item from Bag b and returns it. It returns the zero value and an error, if b is
+// empty or the random number generator fails.
+func (b *Bag[T]) Draw() (T, error) {
+	// Choose an item
+	i, e := b.choose()
+	if e != nil {
+		var v T
+		return v, e
+	}
+	// Remove the item by replacing it with the last item
+	v, n := b.items[i], len(b.items)-1
+	b.items[i] = b.items[n]
+	var z T
+	b.items[n] = z
+	b.items = b.items[:n]
+	return v, nil
+}
+
+// DrawN removes n uniformly chosen items from Bag b and returns them in drawn order. It returns nil and an error,
+// if n is negative, b holds less than n items or the random number generator fails.
+func (b *Bag[T]) DrawN(n int) ([]T, error) {
+	// Return an error if b is nil
+	if b == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Return an error if n is out of bounds
+	if n < 0 {
+		return nil, tserr.Higher(&tserr.HigherArgs{Var: "n", Actual: int64(n), LowerBound: 0})
+	}
+	if n > len(b.items) {
+		return nil, tserr.Lower(&tserr.LowerArgs{Var: "n", Actual: int64(n), HigherBound: int64(len(b.items) + 1)})
+	}
+	// Draw n items
+	r := make([]T, n)
+	for i := range r {
+		v, e := b.Draw()
+		if e != nil {
+			return nil, e
+		}
+		r[i] = v
+	}
+	return r, nil
+}
+
+// Pick returns a uniformly chosen item of Bag b without removing it, which equals drawing an item and putting it
+// back. It returns the zero value and an error, if b is empty or the random number generator fails.
+func (b *Bag[T]) Pick() (T, error) {
+	// Choose an item
+	i, e := b.choose()
+	if e != nil {
+		var v T
+		return v, e
+	}
+	// Return the item
+	return b.items[i], nil
+}
+
+// Put puts items into Bag b, e.g., drawn items after use. It returns an error, if b is nil.
+func (b *Bag[T]) Put(items ...T) error {
+	// Return an error if b is nil
+	if b == nil {
+		return tserr.NilPtr()
+	}
+	// Put the items into the bag
+	b.items = append(b.items, items...)
+	return nil
+}
+
+// Len returns the number of items in Bag b.
+func (b *Bag[T]) Len() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.items)
+}
+
+// Seed seeds Bag b with seed s as described for Die.Seed. Drawing from equal bags seeded with the same seed returns
+// the same series of items. It returns an error, if any.
+func (b *Bag[T]) Seed(s int64) error {
+	// Return an error if b is nil
+	if b == nil {
+		return tserr.NilPtr()
+	}
+	// Seed the Die
+	return b.d.Seed(s)
+}
+
+// SeedWith seeds Bag b with seed s using Algorithm a as described for Die.SeedWith. It returns an error, if any.
+func (b *Bag[T]) SeedWith(a Algorithm, s int64) error {
+	// Return an error if b is nil
+	if b == nil {
+		return tserr.NilPtr()
+	}
+	// Seed the Die
+	return b.d.SeedWith(a, s)
+}
+
+// NoSeed sets Bag b to use the cryptographically secure random number generator as described for Die.NoSeed.
+// It returns an error, if any.
+func (b *Bag[T]) NoSeed() error {
+	// Return an error if b is nil
+	if b == nil {
+		return tserr.NilPtr()
+	}
+	// Remove the seed of the Die
+	return b.d.NoSeed()
+}
+
+// choose returns the index of a uniformly chosen item of Bag b. It returns an error, if b is nil or empty or the
+// random number generator fails.
+func (b *Bag[T]) choose() (int, error) {
+	// Return an error if b is nil
+	if b == nil {
+		return 0, tserr.NilPtr()
+	}
+	// Return an error if the bag is empty
+	if len(b.items) == 0 {
+		return 0, tserr.Empty("bag")
+	}
+	// Choose an index with the random number generators of the Die
+	return b.d.index(len(b.items))
+}
+
+// index returns a uniformly chosen index lower than n using the random number generators of Die d. The Observer is
+// not notified, since no dice are rolled. It returns an error, if any.
+func (d *Die) index(n int) (int, error) {
+	// Return an error if d is nil
+	if d == nil {
+		return 0, tserr.NilPtr()
+	}
+	// Initialize the die if not initialized yet
+	if e := d.notSet(); e != nil {
+		// Return an error if the initialization fails
+		return 0, e
+	}
+	// Create the random number generator, if not created yet
+	if e := d.gen(); e != nil {
+		return 0, e
+	}
+	// g holds the random number generator, rrnd if recording and grnd otherwise
+	g := d.grnd
+	if d.rrnd != nil {
+		g = d.rrnd
+	}
+	// Choose the index and return an error if the source failed
+	i := d.intn(g, n)
+	return i, d.err()
+}
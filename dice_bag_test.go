@@ -0,0 +1,112 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages as well as tserr
+import (
+	"math"    // math
+	"slices"  // slices
+	"testing" // testing
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// TestBag draws from equally seeded bags and puts items back. The test fails if the bags differ, an item is drawn
+// twice or missing, Pick removes an item, a put back item is not drawable or the bag holds a wrong number of items.
+func TestBag(t *testing.T) {
+	// c holds the tokens
+	c := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	a, e := NewBag(c...)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "NewBag", Fn: "tokens", Err: e}))
+	}
+	b, _ := NewBag(c...)
+	a.Seed(4)
+	b.Seed(4)
+	// Draw all tokens from both bags
+	x, e := a.DrawN(len(c))
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "DrawN", Fn: "bag", Err: e}))
+	}
+	y := make([]int, 0, len(c))
+	for b.Len() > 0 {
+		v, _ := b.Draw()
+		y = append(y, v)
+	}
+	if !slices.Equal(x, y) {
+		t.Errorf("equally seeded bags differ: %v, %v", x, y)
+	}
+	// The drawn tokens are a permutation of the tokens
+	if slices.Equal(x, c) {
+		t.Error("bag drawn in order")
+	}
+	if s := sorted(x); !slices.Equal(s, c) {
+		t.Errorf("drawn tokens %v are not a permutation of %v", x, c)
+	}
+	// Drawing from the empty bag returns an error
+	if _, e = a.Draw(); e == nil {
+		t.Error(tserr.NilFailed("Draw"))
+	}
+	if _, e = a.Pick(); e == nil {
+		t.Error(tserr.NilFailed("Pick"))
+	}
+	// A token put back is drawable immediately and Pick does not remove it
+	a.Put(7)
+	for i := 0; i < 3; i++ {
+		if v, e := a.Pick(); e != nil || v != 7 || a.Len() != 1 {
+			t.Errorf("picked %d from bag of %d tokens: %v", v, a.Len(), e)
+		}
+	}
+	if v, _ := a.Draw(); v != 7 || a.Len() != 0 {
+		t.Errorf("drew %d from bag of %d tokens, expected 7 and 0", v, a.Len())
+	}
+	// Removing the seed draws with the cryptographically secure random number generator
+	if e = a.NoSeed(); e != nil || a.d.source() == SourceDeterministic {
+		t.Error(tserr.Op(&tserr.OpArgs{Op: "NoSeed", Fn: "bag", Err: e}))
+	}
+	// Invalid arguments and nil pointers return an error
+	if _, e = a.DrawN(-1); e == nil {
+		t.Error(tserr.NilFailed("DrawN"))
+	}
+	if _, e = a.DrawN(1); e == nil {
+		t.Error(tserr.NilFailed("DrawN"))
+	}
+	var n *Bag[int]
+	if _, e = n.Draw(); e == nil || n.Len() != 0 {
+		t.Error(tserr.NilFailed("Draw"))
+	}
+	if _, e = n.Pick(); e == nil || n.Seed(1) == nil || n.SeedWith(AlgorithmPCGv1, 1) == nil || n.NoSeed() == nil || n.Put(1) == nil {
+		t.Error(tserr.NilFailed("Bag"))
+	}
+}
+
+// TestBagUniform picks tokens of a non-seeded bag and draws tokens putting them back. The test fails if a token is
+// picked or drawn more than six standard deviations too often or too rarely.
+func TestBagUniform(t *testing.T) {
+	// tokens holds the tokens and b the bag of tokens
+	tokens := []string{"red", "green", "blue", "black"}
+	b, _ := NewBag(tokens...)
+	// n holds the number of picks and draws and c the number of picks and draws of each token
+	n, c := 8000, make(map[string]int)
+	for i := 0; i < n; i++ {
+		v, e := b.Pick()
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Pick", Fn: "bag", Err: e}))
+		}
+		c[v]++
+		// Draw a token and put it back
+		if v, e = b.Draw(); e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Draw", Fn: "bag", Err: e}))
+		}
+		c[v]++
+		b.Put(v)
+	}
+	p := 0.25
+	want, dev := 2*float64(n)*p, 6*math.Sqrt(2*float64(n)*p*(1-p))
+	for _, v := range tokens {
+		if math.Abs(float64(c[v])-want) > dev {
+			t.Errorf("token %s picked and drawn %d times, expected %.0f", v, c[v], want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import package testing as well as tserr
+import (
+	"testing" // testing
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// TestRollInto rolls a seeded d20 with RollInto and an equally seeded d20 with Roll. The test fails if the results
+// differ, a result is out of bounds or RollInto allocates memory.
+func TestRollInto(t *testing.T) {
+	// Roll seeded dice with RollInto and Roll
+	a, _ := NewD20()
+	b, _ := NewD20()
+	a.Seed(3)
+	b.Seed(3)
+	r := make([]int, 1000)
+	if e := a.RollInto(r); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "RollInto", Fn: "d20", Err: e}))
+	}
+	for _, v := range r {
+		if w, _ := b.Roll(); v != w {
+			t.Fatal(tserr.Equal(&tserr.EqualArgs{Var: "result of RollInto", Actual: int64(v), Want: int64(w)}))
+		}
+	}
+	// Roll a non-seeded d6 beyond the buffer of the cryptographically secure random number generator
+	d, _ := NewD6()
+	n, e := d.RollN(10 * cryptoBuffer)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "RollN", Fn: "d6", Err: e}))
+	}
+	// c holds the number of results per face
+	var c [7]int
+	for _, v := range n {
+		if v < 1 || v > 6 {
+			t.Fatal(tserr.Higher(&tserr.HigherArgs{Var: "result", Actual: int64(v), LowerBound: 1}))
+		}
+		c[v]++
+	}
+	for i := 1; i <= 6; i++ {
+		if c[i] == 0 {
+			t.Errorf("face %d not rolled in %d rolls", i, len(n))
+		}
+	}
+	// RollInto does not allocate memory
+	if m := testing.AllocsPerRun(100, func() { d.RollInto(r) }); m != 0 {
+		t.Error(tserr.Equalf(&tserr.EqualfArgs{Var: "allocations of RollInto", Actual: m, Want: 0}))
+	}
+	// Invalid arguments and nil pointers return an error
+	if _, e = d.RollN(-1); e == nil {
+		t.Error(tserr.NilFailed("RollN"))
+	}
+	var z *Die
+	if e = z.RollInto(r); e == nil {
+		t.Error(tserr.NilFailed("RollInto"))
+	}
+	if _, e = z.RollN(1); e == nil {
+		t.Error(tserr.NilFailed("RollN"))
+	}
+}
+
+// BenchmarkRoll rolls a non-seeded d20 one at a time.
+func BenchmarkRoll(b *testing.B) {
+	d, _ := NewD20()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d.Roll()
+	}
+}
+
+// BenchmarkRollInto rolls a non-seeded d20 in batches of 1024 results.
+func BenchmarkRollInto(b *testing.B) {
+	d, _ := NewD20()
+	benchRollInto(b, d)
+}
+
+// BenchmarkRollIntoSeeded rolls a seeded d20 in batches of 1024 results.
+func BenchmarkRollIntoSeeded(b *testing.B) {
+	d, _ := NewD20()
+	d.Seed(1)
+	benchRollInto(b, d)
+}
+
+// BenchmarkRollIntoPCG rolls a d20 seeded with AlgorithmPCGv1 in batches of 1024 results.
+func BenchmarkRollIntoPCG(b *testing.B) {
+	d, _ := NewD20()
+	d.SeedWith(AlgorithmPCGv1, 1)
+	benchRollInto(b, d)
+}
+
+// benchRollInto rolls Die d in batches of 1024 results. The duration is also reported per result.
+func benchRollInto(b *testing.B, d *Die) {
+	r := make([]int, 1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d.RollInto(r)
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(r)), "ns/roll")
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages fmt, math, strconv and strings as well as tserr
+import (
+	"fmt"     // fmt
+	"math"    // math
+	"strconv" // strconv
+	"strings" // strings
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// defaultWidth defines the default width of the longest bar of a Chart, maxWidth its maximum
+// width and defaultBar defines the default rune to draw bars.
+const (
+	defaultWidth int  = 40
+	maxWidth     int  = 500
+	defaultBar   rune = '#'
+)
+
+// A Chart renders a Distribution as horizontal bar histogram for the terminal. Each outcome is
+// printed in a separate line with a bar proportional to its weight. The longest bar has width runes.
+// If Percent is true, each bar is labeled with the relative weight of the outcome in percent.
+// A directly instantiated Chart uses a width of 40 runes, draws bars with # and prints percentage labels.
+type Chart struct {
+	width   int  // width of the longest bar
+	bar     rune // rune to draw the bars
+	percent bool // print percentage labels
+	set     bool // true if the Chart has been initialized
+}
+
+// NewChart returns a pointer to a new Chart with bars of a maximum width of w runes and percentage labels.
+// It returns nil and an error, if w is lower than one or higher than 500.
+func NewChart(w int) (*Chart, error) {
+	// Create a new Chart with default settings
+	c := &Chart{}
+	// Set width of the chart
+	if e := c.SetWidth(w); e != nil {
+		// Return nil and an error if the width is out of bounds
+		return nil, e
+	}
+	// Return the new Chart
+	return c, nil
+}
+
+// notSet sets the default settings of Chart c, if the Chart has not been initialized.
+func (c *Chart) notSet() {
+	// If set is false, the Chart has not been initialized yet
+	if !c.set {
+		c.width, c.bar, c.percent, c.set = defaultWidth, defaultBar, true, true
+	}
+}
+
+// SetWidth sets the maximum width of the bars of Chart c to w runes. It returns an error, if w is lower than one
+// or higher than 500.
+func (c *Chart) SetWidth(w int) error {
+	// Return an error if c is nil
+	if c == nil {
+		return tserr.NilPtr()
+	}
+	// Return an error if w is lower than one
+	if w < 1 {
+		return tserr.Higher(&tserr.HigherArgs{Var: "width", Actual: int64(w), LowerBound: 1})
+	}
+	// Return an error if w is higher than maxWidth
+	if w > maxWidth {
+		return tserr.Lower(&tserr.LowerArgs{Var: "width", Actual: int64(w), HigherBound: int64(maxWidth + 1)})
+	}
+	// Initialize the Chart if not initialized yet
+	c.notSet()
+	// Set the width
+	c.width = w
+	// Return nil
+	return nil
+}
+
+// SetBar sets the rune r to draw the bars of Chart c. It returns an error, if r is not printable.
+func (c *Chart) SetBar(r rune) error {
+	// Return an error if c is nil
+	if c == nil {
+		return tserr.NilPtr()
+	}
+	// Return an error if r is not printable or a space
+	if !strconv.IsPrint(r) || r == ' ' {
+		return tserr.NonPrintable("bar")
+	}
+	// Initialize the Chart if not initialized yet
+	c.notSet()
+	// Set the rune
+	c.bar = r
+	// Return nil
+	return nil
+}
+
+// SetPercent enables percentage labels of Chart c, if p is true, and disables them otherwise.
+func (c *Chart) SetPercent(p bool) error {
+	// Return an error if c is nil
+	if c == nil {
+		return tserr.NilPtr()
+	}
+	// Initialize the Chart if not initialized yet
+	c.notSet()
+	// Set the percentage labels
+	c.percent = p
+	// Return nil
+	return nil
+}
+
+// Render returns Distribution d rendered as horizontal bar histogram by Chart c. The longest bar
+// represents the highest weight. It returns an empty string and an error, if any.
+func (c *Chart) Render(d *Distribution) (string, error) {
+	// Return an error if c or d is nil
+	if c == nil || d == nil {
+		return "", tserr.NilPtr()
+	}
+	// Return an error if d does not hold any outcome
+	if len(d.v) == 0 {
+		return "", tserr.Empty("distribution")
+	}
+	// Initialize the Chart if not initialized yet
+	c.notSet()
+	// Retrieve the highest weight, the sum of weights and the widest label of an outcome
+	mw, t, lw := 0.0, d.Total(), 0
+	for i, v := range d.v {
+		mw = math.Max(mw, d.w[i])
+		lw = max(lw, len(strconv.Itoa(v)))
+	}
+	// Return an error if all weights are zero
+	if mw <= 0 {
+		return "", tserr.Empty("weights")
+	}
+	// b holds the rendered chart
+	var b strings.Builder
+	// Render a line for each outcome
+	for i, v := range d.v {
+		// Scale the bar to the width of the chart
+		n := int(math.Round(d.w[i] / mw * float64(c.width)))
+		// Write the outcome, the bar and padding to align the labels
+		fmt.Fprintf(&b, "%*d | %s", lw, v, strings.Repeat(string(c.bar), n))
+		// Write the percentage label, if enabled
+		if c.percent {
+			fmt.Fprintf(&b, "%s %6.2f%%", strings.Repeat(" ", c.width-n), d.w[i]/t*100)
+		}
+		b.WriteByte('\n')
+	}
+	// Return the rendered chart
+	return b.String(), nil
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import packages math, strings and testing as well as tserr
+import (
+	"math"    // math
+	"strings" // strings
+	"testing" // testing
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// TestHistogram creates the Distribution of a slice of results. The test fails if
+// the outcomes or weights do not match the expected values.
+func TestHistogram(t *testing.T) {
+	// Create the Distribution of results
+	d, e := NewHistogram([]int{3, 1, 3, 6, 3, 1})
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "NewHistogram", Fn: "y", Err: e}))
+	}
+	// The test fails if outcomes or weights do not match
+	v, w := d.Values(), d.Weights()
+	if len(v) != 3 || v[0] != 1 || v[1] != 3 || v[2] != 6 || w[0] != 2 || w[1] != 3 || w[2] != 1 {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "histogram", Actual: "mismatch", Want: "1:2 3:3 6:1"}))
+	}
+	// The test fails if the probability of 3 is not 0.5
+	if p := d.Probability(3); p != 0.5 {
+		t.Error(tserr.Equalf(&tserr.EqualfArgs{Var: "probability of 3", Actual: p, Want: 0.5}))
+	}
+	// The test fails if the probability of a missing outcome is not zero
+	if p := d.Probability(2); p != 0 {
+		t.Error(tserr.Equalf(&tserr.EqualfArgs{Var: "probability of 2", Actual: p, Want: 0}))
+	}
+	// The test fails if NewHistogram returns nil for an empty slice
+	if _, e := NewHistogram(nil); e == nil {
+		t.Error(tserr.NilFailed("NewHistogram"))
+	}
+}
+
+// TestChart renders a histogram and compares it with the expected chart.
+// The test fails if the rendered chart does not match.
+func TestChart(t *testing.T) {
+	// Create the Distribution of results
+	d, _ := NewHistogram([]int{1, 2, 2, 2, 10})
+	// Create a Chart with a width of 6 runes
+	c, e := NewChart(6)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "NewChart", Fn: "6", Err: e}))
+	}
+	// Render the chart
+	r, e := c.Render(d)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Render", Fn: "d", Err: e}))
+	}
+	// want holds the expected chart
+	want := " 1 | ##      20.00%\n" +
+		" 2 | ######  60.00%\n" +
+		"10 | ##      20.00%\n"
+	// The test fails if the chart does not match
+	if r != want {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "chart", Actual: r, Want: want}))
+	}
+	// Disable percentage labels and change the bar
+	c.SetPercent(false)
+	c.SetBar('*')
+	r, _ = c.Render(d)
+	want = " 1 | **\n 2 | ******\n10 | **\n"
+	// The test fails if the chart does not match
+	if r != want {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "chart", Actual: r, Want: want}))
+	}
+}
+
+// TestChartDefault renders a chart with a directly instantiated Chart. The test fails if
+// the longest bar does not have the default width.
+func TestChartDefault(t *testing.T) {
+	// Define a standard Chart
+	var c Chart
+	// Retrieve the distribution of a six-sided die
+	x, _ := ParseExpr("d6")
+	d, _ := x.Distribution()
+	// Render the chart
+	r, e := c.Render(d)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Render", Fn: "d", Err: e}))
+	}
+	// want holds the expected first line
+	want := "1 | " + strings.Repeat("#", defaultWidth) + "  16.67%\n"
+	// The test fails if the first line does not match
+	if len(r) < len(want) || r[:len(want)] != want {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "chart", Actual: r, Want: want}))
+	}
+}
+
+// TestChartInvalid checks if invalid arguments return an error. The test fails if nil is returned.
+func TestChartInvalid(t *testing.T) {
+	// The test fails if NewChart returns nil for width zero
+	if _, e := NewChart(0); e == nil {
+		t.Error(tserr.NilFailed("NewChart"))
+	}
+	// The test fails if NewChart or SetWidth return nil for a width higher than maxWidth
+	if _, e := NewChart(math.MaxInt64); e == nil {
+		t.Error(tserr.NilFailed("NewChart"))
+	}
+	if e := (&Chart{}).SetWidth(maxWidth + 1); e == nil {
+		t.Error(tserr.NilFailed("SetWidth"))
+	}
+	// The test fails if SetBar returns nil for a non-printable rune
+	var c Chart
+	if e := c.SetBar('\n'); e == nil {
+		t.Error(tserr.NilFailed("SetBar"))
+	}
+	// The test fails if Render returns nil for an empty Distribution
+	if _, e := c.Render(&Distribution{}); e == nil {
+		t.Error(tserr.NilFailed("Render"))
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library package strings as well as tserr
+import (
+	"strings" // strings
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// defaultMargin defines the default margin of a Check by which the difficulty must be beaten or missed for a
+// critical success or failure and defaultNatural the default number of degrees a natural result shifts the degree.
+const (
+	defaultMargin  int = 10
+	defaultNatural int = 1
+)
+
+// A Degree is the degree of success of a Check
+type Degree int
+
+// Degrees of success in ascending order
+const (
+	CriticalFailure Degree = iota // the difficulty is missed by at least the failure margin
+	Failure                       // the difficulty is missed
+	Success                       // the difficulty is met or beaten
+	CriticalSuccess               // the difficulty is beaten by at least the success margin
+)
+
+// degreeNames holds the names of the degrees of success in ascending order
+var (
+	degreeNames = []string{"critical failure", "failure", "success", "critical success"}
+)
+
+// String returns the name of Degree g, e.g., critical success.
+func (g Degree) String() string {
+	if g < CriticalFailure || g > CriticalSuccess {
+		return "unknown"
+	}
+	return degreeNames[g]
+}
+
+// MarshalText returns the name of Degree g with underscores, e.g., critical_success. It is used to encode g in JSON.
+func (g Degree) MarshalText() ([]byte, error) {
+	return []byte(strings.ReplaceAll(g.String(), " ", "_")), nil
+}
+
+// A Check grades the outcome of rolling a dice expression plus a modifier against a difficulty. The outcome is a
+// Success, if the total meets or beats the difficulty, and a Failure otherwise. By default, beating the difficulty
+// by 10 is a CriticalSuccess and missing it by 10 a CriticalFailure. A critical success of a die shifts the degree
+// one up and a critical failure one down. By default, the critical results are the natural maximum and minimum of
+// the dice with the most sides, e.g., a natural 20 or 1 on the d20 of 1d20+1d4. The critical ranges are changed
+// with SetCrits of the dice expression returned by Expr.
+type Check struct {
+	x       *Expr // dice expression
+	mod     int   // modifier added to the result of the dice expression
+	dc      int   // difficulty
+	succ    int   // margin by which the difficulty is beaten for a critical success
+	fail    int   // margin by which the difficulty is missed for a critical failure
+	natural int   // number of degrees a natural maximum or minimum shifts the degree
+}
+
+// A CheckResult holds the outcome of a Check. It contains the result of the dice expression, the total including
+// the modifier, the difficulty and the degree of success.
+type CheckResult struct {
+	Pool   *PoolResult `json:"pool"`       // result of the dice expression
+	Total  int         `json:"total"`      // total including the modifier
+	DC     int         `json:"difficulty"` // difficulty
+	Degree Degree      `json:"degree"`     // degree of success
+}
+
+// NewCheck returns a pointer to a new Check rolling dice expression s, e.g., 1d20, plus modifier mod against
+// difficulty dc with the default margins and natural adjustments. It returns nil and an error, if s cannot be parsed.
+func NewCheck(s string, mod, dc int) (*Check, error) {
+	// Parse the dice expression
+	x, e := ParseExpr(s)
+	if e != nil {
+		return nil, e
+	}
+	// Limit critical results to the natural maximum and minimum of the dice with the most sides
+	if s := x.sides(); s > 0 {
+		x.SetCrits(CritRange{Sides: s, Success: 1, Failure: 1})
+	}
+	// Return the Check with default settings
+	return &Check{x: x, mod: mod, dc: dc, succ: defaultMargin, fail: defaultMargin, natural: defaultNatural}, nil
+}
+
+// SetMargins sets the margin succ by which the difficulty must be beaten for a CriticalSuccess and the margin fail
+// by which it must be missed for a CriticalFailure. It returns an error, if a margin is lower than one.
+func (c *Check) SetMargins(succ, fail int) error {
+	// Return an error if c is nil
+	if c == nil {
+		return tserr.NilPtr()
+	}
+	// Return an error if a margin is lower than one
+	for _, m := range []int{succ, fail} {
+		if m < 1 {
+			return tserr.Higher(&tserr.HigherArgs{Var: "margin", Actual: int64(m), LowerBound: 1})
+		}
+	}
+	// Set the margins
+	c.succ, c.fail = succ, fail
+	return nil
+}
+
+// SetNatural sets the number of degrees n by which a critical success of a die shifts the degree up and a critical
+// failure shifts it down, e.g., a natural 20 and 1 on 1d20. Zero disables natural adjustments. Since there are four
+// degrees, n is clamped to three shifting any degree to a CriticalSuccess or CriticalFailure. It returns an error,
+// if n is negative.
+func (c *Check) SetNatural(n int) error {
+	// Return an error if c is nil
+	if c == nil {
+		return tserr.NilPtr()
+	}
+	// Return an error if n is negative
+	if n < 0 {
+		return tserr.Higher(&tserr.HigherArgs{Var: "natural adjustment", Actual: int64(n), LowerBound: 0})
+	}
+	// Set the natural adjustment clamped to the number of degrees above CriticalFailure
+	c.natural = min(n, int(CriticalSuccess))
+	return nil
+}
+
+// Grade returns the Degree of PoolResult p of the dice expression of Check c. A critical success of a die in p
+// shifts the degree up and a critical failure shifts it down, unless p holds both. It returns CriticalFailure and
+// an error, if c or p is nil.
+func (c *Check) Grade(p *PoolResult) (Degree, error) {
+	// Return an error if c or p is nil
+	if c == nil || p == nil {
+		return CriticalFailure, tserr.NilPtr()
+	}
+	// f holds the critical flags of the dice
+	f := 0
+	for _, r := range p.Rolls {
+		f |= flags(r.CritSuccess, r.CritFailure)
+	}
+	// Return the degree
+	return c.grade(p.Total, f), nil
+}
+
+// Flags of critical results of dice
+const (
+	critSuccess int = 1 << iota // at least one die is a critical success
+	critFailure                 // at least one die is a critical failure
+)
+
+// flags returns the critical flags of a die with critical success succ and critical failure fail.
+func flags(succ, fail bool) int {
+	f := 0
+	if succ {
+		f |= critSuccess
+	}
+	if fail {
+		f |= critFailure
+	}
+	return f
+}
+
+// grade returns the Degree of result v of the dice expression of Check c, without modifier, and critical flags f.
+func (c *Check) grade(v, f int) Degree {
+	// t holds the total including the modifier
+	t := v + c.mod
+	// g holds the degree of the total
+	var g Degree
+	switch {
+	case t >= c.dc+c.succ:
+		g = CriticalSuccess
+	case t >= c.dc:
+		g = Success
+	case t <= c.dc-c.fail:
+		g = CriticalFailure
+	default:
+		g = Failure
+	}
+	// Shift the degree for a critical success or failure
+	switch f {
+	case critSuccess:
+		g = min(g+Degree(c.natural), CriticalSuccess)
+	case critFailure:
+		g = max(g-Degree(c.natural), CriticalFailure)
+	}
+	// Return the degree
+	return g
+}
+
+// Roll rolls the dice expression of Check c and returns the graded CheckResult. It returns nil and an error, if any.
+func (c *Check) Roll() (*CheckResult, error) {
+	// Return an error if c is nil
+	if c == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Roll with the random number generators of the dice expression
+	return c.RollWith(c.x.d)
+}
+
+// RollWith rolls the dice expression of Check c with the random number generators of Die d as described for
+// Expr.RollWith and returns the graded CheckResult. Die d only provides the random numbers: the dice are flagged
+// and graded with the critical ranges of the dice expression of c, as for Probabilities. It returns nil and an
+// error, if any.
+func (c *Check) RollWith(d *Die) (*CheckResult, error) {
+	// Return an error if c is nil
+	if c == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Roll the dice expression
+	p, e := c.x.RollWith(d)
+	if e != nil {
+		return nil, e
+	}
+	// Flag the dice with the critical ranges of the dice expression instead of the ranges of d
+	for i := range p.Rolls {
+		c.x.d.tag(&p.Rolls[i])
+	}
+	// Return the graded result
+	g, _ := c.Grade(p)
+	return &CheckResult{Pool: p, Total: p.Total + c.mod, DC: c.dc, Degree: g}, nil
+}
+
+// Probabilities returns the exact probability of each Degree of Check c, indexed by Degree, including the shifts
+// of critical results. It returns nil and an error, if the range of outcomes of the dice expression is too large.
+func (c *Check) Probabilities() ([]float64, error) {
+	// Return an error if c is nil
+	if c == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Return an error if the range of outcomes exceeds maxRange
+	if lo, hi := c.x.bounds(); hi-lo > maxRange {
+		return nil, tserr.Lower(&tserr.LowerArgs{Var: "range of outcomes", Actual: int64(hi - lo), HigherBound: int64(maxRange + 1)})
+	}
+	// d holds the distributions of the results for each combination of critical flags, starting with the constant zero
+	var d [critSuccess | critFailure + 1]*Distribution
+	d[0] = &Distribution{v: []int{0}, w: []float64{1}}
+	// Iterate all terms
+	for _, t := range c.x.t {
+		// Shift the distributions by constant modifiers
+		if t.s == 0 {
+			for f := range d {
+				if d[f] != nil {
+					d[f] = d[f].convolve(&Distribution{v: []int{t.sign * t.n}, w: []float64{1}})
+				}
+			}
+			continue
+		}
+		// u holds the distributions of a single die of the term for each combination of critical flags
+		var u [len(d)]*Distribution
+		for i := 1; i <= t.s; i++ {
+			f := flags(c.x.d.crits(t.s, i))
+			u[f] = u[f].add(&Distribution{v: []int{t.sign * i}, w: []float64{1 / float64(t.s)}})
+		}
+		// Convolve the distributions with each die of the term and combine the critical flags
+		for i := 0; i < t.n; i++ {
+			var n [len(d)]*Distribution
+			for f := range d {
+				for g := range u {
+					if d[f] != nil && u[g] != nil {
+						n[f|g] = n[f|g].add(d[f].convolve(u[g]))
+					}
+				}
+			}
+			d = n
+		}
+	}
+	// Sum up the probabilities of all outcomes by their degree
+	p := make([]float64, CriticalSuccess+1)
+	for f := range d {
+		if d[f] != nil {
+			for i, v := range d[f].v {
+				p[c.grade(v, f)] += d[f].w[i]
+			}
+		}
+	}
+	// Return the probabilities
+	return p, nil
+}
+
+// Expr returns the dice expression of Check c, e.g., to seed it.
+func (c *Check) Expr() *Expr {
+	if c == nil {
+		return nil
+	}
+	return c.x
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages as well as lpstats and tserr
+import (
+	"encoding/json" // json
+	"math"          // math
+	"strconv"       // strconv
+	"strings"       // strings
+	"testing"       // testing
+
+	"github.com/thorstenrie/lpstats" // lpstats
+	"github.com/thorstenrie/tserr"   // tserr
+)
+
+// TestCheck computes the probabilities of each degree of success of checks and rolls them. The test fails if
+// the probabilities differ from the expected probabilities, a roll is graded wrong or the degrees of the rolls
+// deviate more than six standard deviations from their probabilities.
+func TestCheck(t *testing.T) {
+	for _, c := range []struct {
+		s       string    // dice expression
+		mod, dc int       // modifier and difficulty
+		natural int       // natural adjustment
+		want    []float64 // expected probability of each degree in twentieths
+	}{
+		// Natural 1 is a critical failure, 2-9 fail, 10-19 succeed and natural 20 is a critical success
+		{"1d20", 5, 15, 1, []float64{1, 8, 10, 1}},
+		// Without natural adjustment, natural 1 is a failure
+		{"1d20", 5, 15, 0, []float64{0, 9, 10, 1}},
+		// Natural 20 shifts a failure to a success
+		{"1d20", 0, 25, 1, []float64{15, 4, 1, 0}},
+		// Two degrees shift a failure of the natural 20 to a critical success
+		{"1d20", 0, 25, 2, []float64{15, 4, 0, 1}},
+		// Huge adjustments are clamped and shift a critical failure of the natural 20 to a critical success
+		{"1d20", 0, 35, math.MaxInt, []float64{19, 0, 0, 1}},
+	} {
+		k, e := NewCheck(c.s, c.mod, c.dc)
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "NewCheck", Fn: c.s, Err: e}))
+		}
+		if e = k.SetNatural(c.natural); e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "SetNatural", Fn: c.s, Err: e}))
+		}
+		// The test fails if the probabilities differ from the expected probabilities
+		p, e := k.Probabilities()
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Probabilities", Fn: c.s, Err: e}))
+		}
+		for g := CriticalFailure; g <= CriticalSuccess; g++ {
+			if !lpstats.NearEqual(p[g], c.want[g]/20, 1e-12) {
+				t.Error(tserr.Equalf(&tserr.EqualfArgs{Var: "probability of " + g.String(), Actual: p[g], Want: c.want[g] / 20}))
+			}
+		}
+		// Roll the check and count the degrees
+		k.Expr().Seed(int64(c.dc + min(c.natural, 3)))
+		n, f := 20000, make([]int, CriticalSuccess+1)
+		for i := 0; i < n; i++ {
+			r, e := k.Roll()
+			if e != nil {
+				t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Roll", Fn: c.s, Err: e}))
+			}
+			if g, e := k.Grade(r.Pool); e != nil || r.Total != r.Pool.Total+c.mod || r.DC != c.dc || r.Degree != g {
+				t.Fatalf("wrong result %+v of check %s%+d against %d", r, c.s, c.mod, c.dc)
+			}
+			f[r.Degree]++
+		}
+		for g := range f {
+			want := float64(n) * p[g]
+			if math.Abs(float64(f[g])-want) > 6*math.Sqrt(want*(1-p[g]))+1e-9 {
+				t.Errorf("%s rolled %d times, expected %.0f", Degree(g), f[g], want)
+			}
+		}
+	}
+}
+
+// TestCheckNatural computes the probabilities of each degree of success of 1d20+1d4 against difficulty 15 and rolls
+// it. The test fails if the probabilities differ from the expected probabilities or a natural 20 or 1 on the d20 does
+// not shift the degree, although the total is not the highest or lowest total of the expression.
+func TestCheckNatural(t *testing.T) {
+	k, e := NewCheck("1d20+1d4", 0, 15)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "NewCheck", Fn: "1d20+1d4", Err: e}))
+	}
+	// The natural 20 and 1 on the d20 are a critical success and failure, but not the results of the d4
+	p, e := k.Probabilities()
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Probabilities", Fn: "1d20+1d4", Err: e}))
+	}
+	want := []float64{10, 36, 30, 4}
+	for g := CriticalFailure; g <= CriticalSuccess; g++ {
+		if !lpstats.NearEqual(p[g], want[g]/80, 1e-12) {
+			t.Error(tserr.Equalf(&tserr.EqualfArgs{Var: "probability of " + g.String(), Actual: p[g], Want: want[g] / 80}))
+		}
+	}
+	// Roll the check until the d20 rolled a natural 20 and 1 with any result of the d4
+	k.Expr().Seed(5)
+	n := [2]int{}
+	for i := 0; i < 2000; i++ {
+		r, e := k.Roll()
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Roll", Fn: "1d20+1d4", Err: e}))
+		}
+		switch d := r.Pool.Rolls[0]; {
+		case d.Value == 20 && r.Degree != CriticalSuccess, d.Value == 1 && r.Degree != CriticalFailure:
+			t.Fatalf("natural %d graded %s in %+v", d.Value, r.Degree, r.Pool)
+		case d.Value == 20 && r.Total < 24:
+			n[0]++
+		case d.Value == 1 && r.Total > 2:
+			n[1]++
+		}
+	}
+	if n[0] == 0 || n[1] == 0 {
+		t.Errorf("%d natural 20 and %d natural 1 without highest or lowest total in 2000 rolls", n[0], n[1])
+	}
+}
+
+// TestCheckRollWith rolls 1d20+1d4 against difficulty 15 with a Die flagging the natural maximum and minimum of all
+// dice as critical. The test fails if a d4 is flagged critical or the degrees of the rolls deviate more than six
+// standard deviations from the probabilities of the check.
+func TestCheckRollWith(t *testing.T) {
+	k, e := NewCheck("1d20+1d4", 0, 15)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "NewCheck", Fn: "1d20+1d4", Err: e}))
+	}
+	p, e := k.Probabilities()
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Probabilities", Fn: "1d20+1d4", Err: e}))
+	}
+	// d only provides the random numbers, its critical ranges do not apply
+	d, _ := NewD6()
+	if e = d.SetCrits(CritRange{Success: 1, Failure: 1}); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "SetCrits", Fn: "die", Err: e}))
+	}
+	d.Seed(7)
+	// Roll the check and count the degrees
+	n, f := 20000, make([]int, CriticalSuccess+1)
+	for i := 0; i < n; i++ {
+		r, e := k.RollWith(d)
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "RollWith", Fn: "1d20+1d4", Err: e}))
+		}
+		if x := r.Pool.Rolls[1]; x.CritSuccess || x.CritFailure {
+			t.Fatalf("d4 flagged critical in %+v", r.Pool)
+		}
+		f[r.Degree]++
+	}
+	for g := range f {
+		want := float64(n) * p[g]
+		if math.Abs(float64(f[g])-want) > 6*math.Sqrt(want*(1-p[g]))+1e-9 {
+			t.Errorf("%s rolled %d times, expected %.0f", Degree(g), f[g], want)
+		}
+	}
+}
+
+// TestCheckMargins sets the margins of a 2d6 check. The test fails if a total is graded wrong, the degree is not
+// encoded by name or invalid arguments do not return an error.
+func TestCheckMargins(t *testing.T) {
+	k, _ := NewCheck("2d6", 0, 7)
+	if e := k.SetMargins(3, 2); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "SetMargins", Fn: "2d6", Err: e}))
+	}
+	// want holds the expected degree of each result from 2 to 12
+	want := []Degree{CriticalFailure, CriticalFailure, CriticalFailure, CriticalFailure, Failure, Success, Success, Success, CriticalSuccess, CriticalSuccess, CriticalSuccess}
+	for v := 2; v <= 12; v++ {
+		if g := k.grade(v, 0); g != want[v-2] {
+			t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "degree of " + strconv.Itoa(v), Actual: g.String(), Want: want[v-2].String()}))
+		}
+	}
+	// The degree is encoded in JSON by name
+	b, _ := json.Marshal(CheckResult{Degree: CriticalSuccess})
+	if !strings.Contains(string(b), `"degree":"critical_success"`) {
+		t.Errorf("degree not encoded by name in %s", b)
+	}
+	if Degree(7).String() != "unknown" {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "name of degree 7", Actual: Degree(7).String(), Want: "unknown"}))
+	}
+	// Invalid arguments and nil pointers return an error
+	if k.SetMargins(0, 1) == nil || k.SetNatural(-1) == nil {
+		t.Error(tserr.NilFailed("SetMargins"))
+	}
+	if _, e := NewCheck("d", 0, 0); e == nil {
+		t.Error(tserr.NilFailed("NewCheck"))
+	}
+	var z *Check
+	if _, e := z.Roll(); e == nil || z.SetMargins(1, 1) == nil || z.SetNatural(1) == nil || z.Expr() != nil {
+		t.Error(tserr.NilFailed("Roll"))
+	}
+	if _, e := z.Probabilities(); e == nil {
+		t.Error(tserr.NilFailed("Probabilities"))
+	}
+	if _, e := k.Grade(nil); e == nil {
+		t.Error(tserr.NilFailed("Grade"))
+	}
+	if _, e := z.Grade(&PoolResult{}); e == nil {
+		t.Error(tserr.NilFailed("Grade"))
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import tserr
+import (
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// A CritRange defines the critical ranges of dice with Sides sides, or of all dice, if Sides is zero. A result
+// within the Success highest faces is a critical success and a result within the Failure lowest faces is a critical
+// failure, e.g., CritRange{Sides: 20, Success: 2, Failure: 1} for 19-20 and 1 on a d20. Without critical ranges,
+// the highest face is a critical success and the lowest face is a critical failure.
+type CritRange struct {
+	Sides   int // number of sides of the dice, zero for all dice
+	Success int // number of highest faces being a critical success
+	Failure int // number of lowest faces being a critical failure
+}
+
+// defaultCrit defines the critical ranges of dice without critical ranges set, the natural maximum and minimum
+var (
+	defaultCrit = CritRange{Success: 1, Failure: 1}
+)
+
+// SetCrits sets the critical ranges r of Die d. The first range in r matching the number of sides of a die applies
+// to it. A die without matching range has no critical results. SetCrits without ranges sets d back to the natural
+// maximum and minimum as critical results. The ranges apply to results of d and of dice expressions rolled with d.
+// It returns an error, if a number of sides or faces is negative.
+func (d *Die) SetCrits(r ...CritRange) error {
+	// Return an error if d is nil
+	if d == nil {
+		return tserr.NilPtr()
+	}
+	// Return an error if a number of sides or faces is negative
+	for _, c := range r {
+		for _, v := range []struct {
+			n string
+			v int
+		}{{"sides of critical range", c.Sides}, {"critical successes", c.Success}, {"critical failures", c.Failure}} {
+			if v.v < 0 {
+				return tserr.Higher(&tserr.HigherArgs{Var: v.n, Actual: int64(v.v), LowerBound: 0})
+			}
+		}
+	}
+	// Set a copy of the critical ranges
+	d.crit = append([]CritRange(nil), r...)
+	// Return nil
+	return nil
+}
+
+// SetCrits sets the critical ranges r of the dice expression x as described for Die.SetCrits.
+func (x *Expr) SetCrits(r ...CritRange) error {
+	// Return an error if x is nil
+	if x == nil {
+		return tserr.NilPtr()
+	}
+	// Set the critical ranges of the Die providing the random number generators
+	return x.d.SetCrits(r...)
+}
+
+// tag sets the natural and critical flags of RollResult r according to the critical ranges of Die d.
+func (d *Die) tag(r *RollResult) {
+	// Flag the natural maximum and minimum
+	r.NaturalMax, r.NaturalMin = r.Value == r.Sides, r.Value == 1
+	// Flag critical results
+	r.CritSuccess, r.CritFailure = d.crits(r.Sides, r.Value)
+}
+
+// crits returns whether result v of a die with s sides is a critical success and a critical failure according
+// to the critical ranges of Die d.
+func (d *Die) crits(s, v int) (succ, fail bool) {
+	// c holds the critical range of the die
+	c, ok := defaultCrit, len(d.crit) == 0
+	for _, r := range d.crit {
+		if r.Sides == 0 || r.Sides == s {
+			c, ok = r, true
+			break
+		}
+	}
+	// Return false, if no critical range matches
+	if !ok {
+		return false, false
+	}
+	// Return the critical flags
+	return v > s-c.Success, v <= c.Failure
+}
+
+// CritSuccesses returns the number of dice in PoolResult p with a critical success.
+func (p *PoolResult) CritSuccesses() int {
+	return p.count(func(r RollResult) bool { return r.CritSuccess })
+}
+
+// CritFailures returns the number of dice in PoolResult p with a critical failure.
+func (p *PoolResult) CritFailures() int {
+	return p.count(func(r RollResult) bool { return r.CritFailure })
+}
+
+// count returns the number of dice in PoolResult p matching f.
+func (p *PoolResult) count(f func(RollResult) bool) int {
+	// Return zero if p is nil
+	if p == nil {
+		return 0
+	}
+	// n holds the number of matching dice
+	n := 0
+	for _, r := range p.Rolls {
+		if f(r) {
+			n++
+		}
+	}
+	return n
+}
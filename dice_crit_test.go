@@ -0,0 +1,94 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import package testing as well as tserr
+import (
+	"testing" // testing
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// TestCrits rolls a seeded d20 with the natural maximum and minimum as critical results and a seeded dice
+// expression with a critical range of 19-20 for d20 only. The test fails if a flag does not match the rolled
+// value or the number of critical results of a pool is wrong.
+func TestCrits(t *testing.T) {
+	d, _ := NewD20()
+	d.Seed(20)
+	// f counts the natural maximum and minimum results
+	f := [2]int{}
+	for i := 0; i < 1000; i++ {
+		r, e := d.RollResult()
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "RollResult", Fn: "d20", Err: e}))
+		}
+		if r.NaturalMax != (r.Value == 20) || r.NaturalMin != (r.Value == 1) || r.CritSuccess != r.NaturalMax || r.CritFailure != r.NaturalMin {
+			t.Fatalf("wrong flags of natural result %+v", r)
+		}
+		if r.NaturalMax {
+			f[0]++
+		}
+		if r.NaturalMin {
+			f[1]++
+		}
+	}
+	if f[0] == 0 || f[1] == 0 {
+		t.Errorf("%d natural maximum and %d natural minimum results in 1000 rolls", f[0], f[1])
+	}
+	// Roll an expression with critical successes of 19-20 on d20 and no critical results on other dice
+	x, _ := ParseExpr("1d20+2d6-1d4")
+	x.Seed(3)
+	if e := x.SetCrits(CritRange{Sides: 20, Success: 2, Failure: 1}); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "SetCrits", Fn: x.String(), Err: e}))
+	}
+	// c counts the critical successes on d20
+	c := 0
+	for i := 0; i < 500; i++ {
+		p, e := x.RollPool()
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "RollPool", Fn: x.String(), Err: e}))
+		}
+		// n holds the expected number of critical successes and failures of the pool
+		n := [2]int{}
+		for _, r := range p.Rolls {
+			if r.NaturalMax != (r.Value == r.Sides) || r.NaturalMin != (r.Value == 1) {
+				t.Fatalf("wrong natural flags of %+v", r)
+			}
+			cs, cf := r.Sides == 20 && r.Value >= 19, r.Sides == 20 && r.Value == 1
+			if r.CritSuccess != cs || r.CritFailure != cf {
+				t.Fatalf("wrong critical flags of %+v", r)
+			}
+			if cs {
+				n[0]++
+			}
+			if cf {
+				n[1]++
+			}
+		}
+		if p.CritSuccesses() != n[0] || p.CritFailures() != n[1] {
+			t.Fatalf("pool %+v has %d critical successes and %d failures, expected %v", p, p.CritSuccesses(), p.CritFailures(), n)
+		}
+		c += n[0]
+	}
+	if c == 0 {
+		t.Error("no critical success in 500 rolls")
+	}
+	// SetCrits without ranges sets the natural maximum and minimum back
+	x.SetCrits()
+	if p, _ := x.RollPool(); p.Rolls[1].CritSuccess != p.Rolls[1].NaturalMax {
+		t.Errorf("wrong critical flags of %+v", p.Rolls[1])
+	}
+	// Negative ranges and nil pointers return an error
+	if e := d.SetCrits(CritRange{Success: -1}); e == nil {
+		t.Error(tserr.NilFailed("SetCrits"))
+	}
+	var z *Expr
+	if e := z.SetCrits(); e == nil {
+		t.Error(tserr.NilFailed("SetCrits"))
+	}
+	var p *PoolResult
+	if p.CritSuccesses() != 0 || p.CritFailures() != 0 {
+		t.Error(tserr.NilFailed("CritSuccesses"))
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages as well as tserr
+import (
+	crand "crypto/rand" // crypto/rand
+	"encoding/binary"   // binary
+	"math/rand"         // rand
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// cryptoBuffer defines the number of bytes read at once from the cryptographically secure random number generator
+const (
+	cryptoBuffer int = 512
+)
+
+// cryptoSource is a cryptographically secure source of random numbers based on crypto/rand. It reads cryptoBuffer
+// bytes at once to avoid reading from the operating system for each value. It is not safe for concurrent use by
+// multiple goroutines. If reading fails, it returns zero and holds the error in err.
+type cryptoSource struct {
+	b   [cryptoBuffer]byte // buffered random bytes
+	i   int                // position of the next unused byte in b
+	err error              // last error occurring, if any
+}
+
+// newCryptoRand returns a new instance of rand.Rand with a buffered cryptographically secure source and the source.
+// It returns nil and an error, if the cryptographically secure random number generator is not available on the platform.
+func newCryptoRand() (*rand.Rand, *cryptoSource, error) {
+	// Fill the buffer to check the availability
+	s := &cryptoSource{}
+	if s.fill(); s.err != nil {
+		return nil, nil, tserr.NotAvailable(&tserr.NotAvailableArgs{S: "crypto/rand", Err: s.err})
+	}
+	// Return the random number generator and its source
+	return rand.New(s), s, nil
+}
+
+// fill reads cryptoBuffer bytes into the buffer of s.
+func (s *cryptoSource) fill() {
+	if _, e := crand.Read(s.b[:]); e != nil {
+		s.err = e
+		return
+	}
+	s.i = 0
+}
+
+// Uint64 returns a cryptographically secure random 64-bit value.
+func (s *cryptoSource) Uint64() uint64 {
+	// Fill the buffer, if all bytes are used
+	if s.i+8 > cryptoBuffer {
+		if s.fill(); s.err != nil {
+			return 0
+		}
+	}
+	// Return the next eight bytes
+	v := binary.LittleEndian.Uint64(s.b[s.i:])
+	s.i += 8
+	return v
+}
+
+// Int63 returns a cryptographically secure random 63-bit value.
+func (s *cryptoSource) Int63() int64 {
+	return int64(s.Uint64() & ^uint64(1<<63))
+}
+
+// Seed is empty, the cryptographically secure source cannot be seeded.
+func (s *cryptoSource) Seed(int64) {}
@@ -0,0 +1,212 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import tserr
+import (
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// A Deck draws items without replacement, e.g., cards from a deck. For unordered items, see Bag. It holds a draw pile and a
+// discard pile. Drawn items are put on the discard pile with Discard and Reshuffle shuffles them back into the draw
+// pile. A Deck uses the same random number generators as a Die: it is shuffled with a cryptographically secure random
+// number generator, or a deterministic pseudo-random number generator after Seed. The draw pile is shuffled before the
+// first draw and again before the next draw after Seed or NoSeed. A Deck is not safe for concurrent use by multiple goroutines.
+type Deck[T any] struct {
+	d        *Die // Die providing the random number generators
+	draw     []T  // draw pile, the top item is last
+	disc     []T  // discard pile
+	shuffled bool // true if the draw pile is shuffled
+}
+
+// NewDeck returns a pointer to a new Deck with items in its draw pile. The items are copied. It returns nil and an error, if any.
+func NewDeck[T any](items ...T) (*Deck[T], error) {
+	// Create the Die providing the random number generators
+	d, e := newDie(defaultN)
+	if e != nil {
+		return nil, e
+	}
+	// Return the Deck
+	return &Deck[T]{d: d, draw: append([]T(nil), items...)}, nil
+}
+
+// shuffle shuffles the draw pile with the random number generator of the Die, if not shuffled yet.
+func (k *Deck[T]) shuffle() error {
+	// Return if the draw pile is shuffled
+	if k.shuffled {
+		return nil
+	}
+	// Shuffle the draw pile with Fisher-Yates
+	if e := k.d.shuffle(len(k.draw), func(i, j int) { k.draw[i], k.draw[j] = k.draw[j], k.draw[i] }); e != nil {
+		return e
+	}
+	k.shuffled = true
+	return nil
+}
+
+// Draw removes the top item from the draw pile and returns it. It returns the zero value and an error, if the draw
+// pile is empty or shuffling fails.
+func (k *Deck[T]) Draw() (T, error) {
+	// Retrieve the top item
+	v, e := k.Peek()
+	if e != nil {
+		return v, e
+	}
+	// Remove the top item from the draw pile
+	k.draw = k.draw[:len(k.draw)-1]
+	return v, nil
+}
+
+// DrawN removes the top n items from the draw pile and returns them in drawn order. It returns nil and an error,
+// if n is negative, the draw pile holds less than n items or shuffling fails.
+func (k *Deck[T]) DrawN(n int) ([]T, error) {
+	// Return an error if k is nil
+	if k == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Return an error if n is out of bounds
+	if n < 0 {
+		return nil, tserr.Higher(&tserr.HigherArgs{Var: "n", Actual: int64(n), LowerBound: 0})
+	}
+	if n > len(k.draw) {
+		return nil, tserr.Lower(&tserr.LowerArgs{Var: "n", Actual: int64(n), HigherBound: int64(len(k.draw) + 1)})
+	}
+	// Draw n items
+	r := make([]T, n)
+	for i := range r {
+		v, e := k.Draw()
+		if e != nil {
+			return nil, e
+		}
+		r[i] = v
+	}
+	return r, nil
+}
+
+// Peek returns the top item of the draw pile without removing it. It returns the zero value and an error, if the
+// draw pile is empty or shuffling fails.
+func (k *Deck[T]) Peek() (T, error) {
+	// v holds the zero value
+	var v T
+	// Return an error if k is nil
+	if k == nil {
+		return v, tserr.NilPtr()
+	}
+	// Return an error if the draw pile is empty
+	if len(k.draw) == 0 {
+		return v, tserr.Empty("draw pile")
+	}
+	// Shuffle the draw pile, if not shuffled yet
+	if e := k.shuffle(); e != nil {
+		return v, e
+	}
+	// Return the top item
+	return k.draw[len(k.draw)-1], nil
+}
+
+// Discard puts items on the discard pile, e.g., drawn items after use. It returns an error, if k is nil.
+func (k *Deck[T]) Discard(items ...T) error {
+	// Return an error if k is nil
+	if k == nil {
+		return tserr.NilPtr()
+	}
+	// Put the items on the discard pile
+	k.disc = append(k.disc, items...)
+	return nil
+}
+
+// Reshuffle moves the discard pile into the draw pile and shuffles the draw pile. It returns an error, if any.
+func (k *Deck[T]) Reshuffle() error {
+	// Return an error if k is nil
+	if k == nil {
+		return tserr.NilPtr()
+	}
+	// Move the discard pile into the draw pile
+	k.draw, k.disc = append(k.draw, k.disc...), k.disc[:0]
+	// Shuffle the draw pile
+	k.shuffled = false
+	return k.shuffle()
+}
+
+// Remaining returns the number of items in the draw pile.
+func (k *Deck[T]) Remaining() int {
+	if k == nil {
+		return 0
+	}
+	return len(k.draw)
+}
+
+// Discarded returns the number of items in the discard pile.
+func (k *Deck[T]) Discarded() int {
+	if k == nil {
+		return 0
+	}
+	return len(k.disc)
+}
+
+// Seed seeds Deck k with seed s as described for Die.Seed. The draw pile is shuffled with the seeded random number
+// generator before the next draw. Drawing from equal decks seeded with the same seed returns the same series of items.
+// It returns an error, if any.
+func (k *Deck[T]) Seed(s int64) error {
+	// Return an error if k is nil
+	if k == nil {
+		return tserr.NilPtr()
+	}
+	// Seed the Die and shuffle the draw pile before the next draw
+	k.shuffled = false
+	return k.d.Seed(s)
+}
+
+// SeedWith seeds Deck k with seed s using Algorithm a as described for Die.SeedWith. The draw pile is shuffled with
+// the seeded random number generator before the next draw. It returns an error, if any.
+func (k *Deck[T]) SeedWith(a Algorithm, s int64) error {
+	// Return an error if k is nil
+	if k == nil {
+		return tserr.NilPtr()
+	}
+	// Seed the Die and shuffle the draw pile before the next draw
+	k.shuffled = false
+	return k.d.SeedWith(a, s)
+}
+
+// NoSeed sets Deck k to use the cryptographically secure random number generator as described for Die.NoSeed.
+// The draw pile is shuffled before the next draw. It returns an error, if any.
+func (k *Deck[T]) NoSeed() error {
+	// Return an error if k is nil
+	if k == nil {
+		return tserr.NilPtr()
+	}
+	// Remove the seed of the Die and shuffle the draw pile before the next draw
+	k.shuffled = false
+	return k.d.NoSeed()
+}
+
+// shuffle shuffles n items with Fisher-Yates using the random number generators of Die d. Swap swaps the items
+// with indexes i and j. The Observer is not notified, since no dice are rolled. It returns an error, if any.
+func (d *Die) shuffle(n int, swap func(i, j int)) error {
+	// Return an error if d is nil
+	if d == nil {
+		return tserr.NilPtr()
+	}
+	// Initialize the die if not initialized yet
+	if e := d.notSet(); e != nil {
+		// Return an error if the initialization fails
+		return e
+	}
+	// Create the random number generator, if not created yet
+	if e := d.gen(); e != nil {
+		return e
+	}
+	// g holds the random number generator, rrnd if recording and grnd otherwise
+	g := d.grnd
+	if d.rrnd != nil {
+		g = d.rrnd
+	}
+	// Swap each item with a uniformly chosen item at or before it
+	for i := n - 1; i > 0; i-- {
+		swap(i, d.intn(g, i+1))
+	}
+	// Return an error if the source failed
+	return d.err()
+}
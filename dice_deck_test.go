@@ -0,0 +1,123 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages as well as tserr
+import (
+	"math"    // math
+	"slices"  // slices
+	"testing" // testing
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// TestDeck draws from equally seeded decks, discards and reshuffles. The test fails if the decks differ, an item
+// is drawn twice or missing, Peek differs from Draw or the piles hold a wrong number of items.
+func TestDeck(t *testing.T) {
+	// c holds the cards
+	c := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	a, e := NewDeck(c...)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "NewDeck", Fn: "cards", Err: e}))
+	}
+	b, _ := NewDeck(c...)
+	a.Seed(4)
+	b.Seed(4)
+	// Draw all cards from both decks
+	x, e := a.DrawN(len(c))
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "DrawN", Fn: "deck", Err: e}))
+	}
+	y := make([]int, 0, len(c))
+	for b.Remaining() > 0 {
+		p, _ := b.Peek()
+		v, _ := b.Draw()
+		if p != v {
+			t.Error(tserr.Equal(&tserr.EqualArgs{Var: "peeked card", Actual: int64(p), Want: int64(v)}))
+		}
+		y = append(y, v)
+	}
+	if !slices.Equal(x, y) {
+		t.Errorf("equally seeded decks differ: %v, %v", x, y)
+	}
+	// The drawn cards are a permutation of the cards
+	if slices.Equal(x, c) {
+		t.Error("deck not shuffled")
+	}
+	if s := sorted(x); !slices.Equal(s, c) {
+		t.Errorf("drawn cards %v are not a permutation of %v", x, c)
+	}
+	// Drawing from the empty deck returns an error
+	if _, e = a.Draw(); e == nil {
+		t.Error(tserr.NilFailed("Draw"))
+	}
+	if _, e = a.DrawN(1); e == nil {
+		t.Error(tserr.NilFailed("DrawN"))
+	}
+	// Discard and reshuffle three cards
+	a.Discard(x[:3]...)
+	if a.Discarded() != 3 || a.Remaining() != 0 {
+		t.Errorf("%d cards discarded and %d remaining, expected 3 and 0", a.Discarded(), a.Remaining())
+	}
+	if e = a.Reshuffle(); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Reshuffle", Fn: "deck", Err: e}))
+	}
+	z, _ := a.DrawN(3)
+	if a.Discarded() != 0 || !slices.Equal(sorted(z), sorted(x[:3])) {
+		t.Errorf("reshuffled cards %v differ from discarded cards %v", z, x[:3])
+	}
+	// Removing the seed shuffles with the cryptographically secure random number generator
+	a.Discard(z...)
+	a.Reshuffle()
+	if e = a.NoSeed(); e != nil || a.d.source() == SourceDeterministic {
+		t.Error(tserr.Op(&tserr.OpArgs{Op: "NoSeed", Fn: "deck", Err: e}))
+	}
+	if a.Remaining() != 3 {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "remaining cards", Actual: int64(a.Remaining()), Want: 3}))
+	}
+	// Invalid arguments and nil pointers return an error
+	if _, e = a.DrawN(-1); e == nil {
+		t.Error(tserr.NilFailed("DrawN"))
+	}
+	var n *Deck[int]
+	if _, e = n.Draw(); e == nil || n.Remaining() != 0 || n.Discarded() != 0 {
+		t.Error(tserr.NilFailed("Draw"))
+	}
+	if n.Seed(1) == nil || n.NoSeed() == nil || n.Discard(1) == nil || n.Reshuffle() == nil {
+		t.Error(tserr.NilFailed("Deck"))
+	}
+}
+
+// TestDeckUniform draws the first token of a non-seeded bag repeatedly. The test fails if a token is drawn
+// first more than six standard deviations too often or too rarely.
+func TestDeckUniform(t *testing.T) {
+	// k holds a bag of tokens
+	k, _ := NewDeck("red", "green", "blue", "black")
+	// n holds the number of draws and c the number of first draws of each token
+	n, c := 8000, make(map[string]int)
+	for i := 0; i < n; i++ {
+		v, e := k.Draw()
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Draw", Fn: "bag", Err: e}))
+		}
+		c[v]++
+		// Return the token and reshuffle the bag
+		k.Discard(v)
+		k.Reshuffle()
+	}
+	p := 0.25
+	want, dev := float64(n)*p, 6*math.Sqrt(float64(n)*p*(1-p))
+	for _, v := range []string{"red", "green", "blue", "black"} {
+		if math.Abs(float64(c[v])-want) > dev {
+			t.Errorf("token %s drawn first %d times, expected %.0f", v, c[v], want)
+		}
+	}
+}
+
+// sorted returns a sorted copy of x.
+func sorted(x []int) []int {
+	s := slices.Clone(x)
+	slices.Sort(s)
+	return s
+}
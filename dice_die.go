@@ -17,43 +17,87 @@ const (
 )
 
 // A Die holds random number generators to roll a die with s sides. It contains a pointer to a pseudo-random number generator in prnd
-// and a pointer to a deterministic random number generator in drnd. The currently used random number generator is stored in grnd.
+// and a pointer to a deterministic random number generator in drnd. Seeds larger than 64 bits use the deterministic random number
+// generator lrnd with 256 bits of state. The currently used random number generator is stored in grnd.
+// While recording, the values of grnd are passed through rrnd. While replaying a Recording, grnd is set to xrnd.
 type Die struct {
-	prnd *rand.Rand // Pseudo-random number generator
-	drnd *rand.Rand // Deterministic pseudo-random number generator
-	grnd *rand.Rand // Currently used random number generator, either prnd or drnd
-	s    int        // number of sides
+	prnd *rand.Rand    // Pseudo-random number generator
+	drnd *rand.Rand    // Deterministic pseudo-random number generator
+	grnd *rand.Rand    // Currently used random number generator, either prnd, drnd, lrnd or xrnd
+	lrnd *rand.Rand    // Deterministic pseudo-random number generator with 256 bits of state, if seeded with more than 64 bits
+	lsrc *xoshiro      // Source of lrnd
+	rrnd *rand.Rand    // Random number generator recording the values of grnd, if recording
+	xrnd *rand.Rand    // Random number generator replaying a Recording, if any
+	play *replaySource // Source of xrnd
+	csrc *cryptoSource // Source of prnd, if it is cryptographically secure
+	smp  *sampler      // Entropy-efficient sampler of csrc
+	shr  *Shared       // Shared generator used by prnd, if any
+	st   *Stats        // Stats tracking the results, if any
+	fr   *fair         // State of the FairMode, if any
+	crit []CritRange   // Critical ranges, natural maximum and minimum if empty
+	alg  Algorithm     // Algorithm of the deterministic random number generator, empty if seeded with Seed
+	src  Source        // Kind of random number generator in prnd
+	s    int           // number of sides
 }
 
-// init initializes a Die. It returns a pointer to the die and an error, if any.
-// A pointer to a new cryptographically secure random number generator will be stored in prnd. If it
-// is not available on the platform, a pseudo-random number generator will be used. A pointer to a
-// new deterministic pseudo-random number generator will be stored in drnd. The currently used
-// random number generator grnd is set to prnd.
+// init initializes a Die. It returns a pointer to the die and an error, if any. The random number generators
+// are not created by init, but lazily on first use: gen creates the non-seeded random number generator prnd on the
+// first roll and Seed creates the deterministic random number generator drnd. Therefore, creating a die is cheap,
+// even if it is short-lived or never seeded.
 func (d *Die) init() (*Die, error) {
 	// Return an error if d is nil
 	if d == nil {
 		return nil, tserr.NilPtr()
 	}
-	// err holds the error of creating a random number generator, if any
-	var err error
-	// Retrieve a new cryptographically secure random number generator in prnd
-	if d.prnd, err = tsrand.NewCryptoRand(); err != nil {
-		// Retrieve a new pseudo-random number generator in prnd, if the cryptographically secure random number generator is not available on the platform
-		if d.prnd, err = tsrand.NewPseudoRandomRand(); err != nil {
-			// Return nil and an error if any
-			return nil, tserr.NotAvailable(&tserr.NotAvailableArgs{S: "tsrand.NewPseudoRandomRand", Err: err})
-		}
+	// Return d and nil
+	return d, nil
+}
+
+// gen sets the currently used random number generator grnd to prnd, if it is not set yet. If prnd has not been
+// created yet, it is created. The generator of the Shared set with Share is used, if any. Otherwise, a pointer to a
+// new cryptographically secure random number generator will be stored in prnd. If it is not available on the platform,
+// a pseudo-random number generator will be used. It returns an error, if any.
+func (d *Die) gen() error {
+	// Return if grnd is already set
+	if d.grnd != nil {
+		return nil
 	}
-	// Retrieve a new deterministic random number generator in drnd
-	if d.drnd, err = tsrand.NewDeterministicRand(); err != nil {
-		// Return nil and an error if any
-		return nil, tserr.NotAvailable(&tserr.NotAvailableArgs{S: "tsrand.NewDeterministicRand", Err: err})
+	// Create prnd, if not created yet
+	if d.prnd == nil {
+		// Use the generator of the Shared, if any
+		if d.shr != nil {
+			d.prnd, d.src = rand.New(d.shr), SourceCrypto
+		} else if e := d.newPrnd(); e != nil {
+			return e
+		}
 	}
 	// Set the currently used random number generator to prnd
 	d.grnd = d.prnd
-	// Return d and nil
-	return d, nil
+	return nil
+}
+
+// newPrnd stores a pointer to a new buffered cryptographically secure random number generator in prnd. If it is not
+// available on the platform, a pseudo-random number generator will be used. It returns an error, if any.
+func (d *Die) newPrnd() error {
+	// err holds the error of creating a random number generator, if any
+	var err error
+	// Retrieve a new buffered cryptographically secure random number generator in prnd
+	d.src = SourceCrypto
+	if d.prnd, d.csrc, err = newCryptoRand(); err == nil {
+		d.smp = newSampler(d.csrc)
+		return nil
+	}
+	// Notify the Observer, if any, of the fallback to a pseudo-random number generator
+	if o := observer(); o != nil {
+		o.Fallback(err)
+	}
+	d.src = SourcePseudo
+	// Retrieve a new pseudo-random number generator in prnd, if the cryptographically secure random number generator is not available on the platform
+	if d.prnd, err = tsrand.NewPseudoRandomRand(); err != nil {
+		// Return an error if any
+		return tserr.NotAvailable(&tserr.NotAvailableArgs{S: "tsrand.NewPseudoRandomRand", Err: err})
+	}
+	return nil
 }
 
 // notSet sets the number of sides of Die d to the default number of sides defaultN
@@ -88,12 +132,180 @@ func (d *Die) Roll() (int, error) {
 		// Return zero and an error if the initialization fails
 		return 0, e
 	}
-	// Return zero and an error if grnd is nil
-	if d.grnd == nil {
+	// Roll the die through grnd
+	v, e := d.rollSides(d.s, true)
+	// Add the result to the tracking Stats, if any
+	if e == nil && d.st != nil {
+		d.st.Add(v)
+	}
+	// Return the result
+	return v, e
+}
+
+// RollInto fills dst with the results of rolling the die len(dst) times. It does not allocate memory and checks
+// the die only once, so that rolling many dice is not dominated by the overhead of each roll. It returns an error,
+// if any. In this case, the contents of dst are undefined.
+func (d *Die) RollInto(dst []int) error {
+	// Return an error if d is nil
+	if d == nil {
+		return tserr.NilPtr()
+	}
+	// Initialize the die if not initialized yet
+	if e := d.notSet(); e != nil {
+		// Return an error if the initialization fails
+		return e
+	}
+	// Create the random number generator, if not created yet
+	if e := d.gen(); e != nil {
+		return e
+	}
+	// g holds the random number generator, rrnd if recording and grnd otherwise
+	g := d.grnd
+	if d.rrnd != nil {
+		g = d.rrnd
+	}
+	// Roll the die through g
+	for i := range dst {
+		dst[i] = d.face(g) + 1
+	}
+	// Return an error if the source failed
+	if e := d.err(); e != nil {
+		return e
+	}
+	// Notify the Observer, if any, of the rolled dice
+	if o := observer(); o != nil {
+		src := d.source()
+		for range dst {
+			o.Rolled(d.s, src)
+		}
+	}
+	// Add the results to the tracking Stats, if any
+	if d.st != nil {
+		for _, v := range dst {
+			d.st.Add(v)
+		}
+	}
+	// Return nil
+	return nil
+}
+
+// RollN returns the results of rolling the die n times in a new slice. It returns nil and an error, if any.
+func (d *Die) RollN(n int) ([]int, error) {
+	// Return an error if n is negative
+	if n < 0 {
+		return nil, tserr.Higher(&tserr.HigherArgs{Var: "n", Actual: int64(n), LowerBound: 0})
+	}
+	// Roll the die n times
+	r := make([]int, n)
+	if e := d.RollInto(r); e != nil {
+		return nil, e
+	}
+	// Return the results
+	return r, nil
+}
+
+// rollSides returns the result of rolling a die with s sides through the currently
+// used random number generator grnd of Die d. It returns zero and an error, if any.
+// It enables dice expressions to roll dice of different sizes with the random number generators of one Die.
+// If f is true, the die is rolled in the FairMode of d, which requires s to be the number of sides of d.
+func (d *Die) rollSides(s int, f bool) (int, error) {
+	// Return zero and an error if d is nil
+	if d == nil {
 		return 0, tserr.NilPtr()
 	}
-	// Return the result of rolling the die through grnd
-	return d.grnd.Intn(d.s) + 1, nil
+	// Create the random number generator, if not created yet
+	if e := d.gen(); e != nil {
+		return 0, e
+	}
+	// Return zero and an error if s is lower than one
+	if s < 1 {
+		return 0, tserr.Higher(&tserr.HigherArgs{Var: "sides", Actual: int64(s), LowerBound: 1})
+	}
+	// g holds the random number generator, rrnd if recording and grnd otherwise
+	g := d.grnd
+	if d.rrnd != nil {
+		g = d.rrnd
+	}
+	// Roll the die through g, in the FairMode of d if f is true
+	var v int
+	if f {
+		v = d.face(g) + 1
+	} else {
+		v = d.intn(g, s) + 1
+	}
+	// Return zero and an error if the source failed
+	if e := d.err(); e != nil {
+		return 0, e
+	}
+	// Notify the Observer, if any, of the rolled die
+	if o := observer(); o != nil {
+		o.Rolled(s, d.source())
+	}
+	// Return the result of rolling the die
+	return v, nil
+}
+
+// intn returns a uniformly distributed result in [0, n) of random number generator g of Die d. If g is
+// the cryptographically secure random number generator, the entropy-efficient sampler is used.
+func (d *Die) intn(g *rand.Rand, n int) int {
+	if g == d.prnd && uint64(n) <= samplerBound {
+		// Use the sampler of the Shared, if any
+		if d.shr != nil {
+			return int(d.shr.intn(uint64(n)))
+		}
+		if d.smp != nil {
+			return int(d.smp.intn(uint64(n)))
+		}
+	}
+	return g.Intn(n)
+}
+
+// Entropy returns the entropy drawn by Die d from its cryptographically secure random number generator. Rolls
+// of a seeded, replaying or recording die and of a die without cryptographically secure random number generator
+// are not counted. If d uses a Shared, the entropy drawn by all dice using the Shared is returned.
+func (d *Die) Entropy() Entropy {
+	if d == nil || (d.smp == nil && d.shr == nil) {
+		return Entropy{}
+	}
+	// Return the entropy of the Shared, if any
+	if d.shr != nil {
+		return d.shr.Entropy()
+	}
+	return Entropy{Bits: d.smp.drawn, Rolls: d.smp.rolls}
+}
+
+// err returns the error of the source of the currently used random number generator grnd of Die d, if any,
+// e.g., if the replayed Recording is exhausted.
+func (d *Die) err() error {
+	switch {
+	case d.grnd == nil:
+		return nil
+	case d.grnd == d.xrnd && d.play.err != nil:
+		return d.play.err
+	case d.grnd == d.prnd && d.csrc != nil && d.csrc.err != nil:
+		return tserr.NotAvailable(&tserr.NotAvailableArgs{S: "crypto/rand", Err: d.csrc.err})
+	case d.grnd == d.prnd && d.shr != nil:
+		return d.shr.err()
+	}
+	return nil
+}
+
+// source returns the kind of the currently used random number generator grnd of Die d.
+func (d *Die) source() Source {
+	// Return the kind of prnd if the die is not seeded
+	if d.grnd == nil || d.grnd == d.prnd {
+		return d.src
+	}
+	// Return SourceDeterministic if the die is seeded
+	if d.grnd == d.drnd || d.grnd == d.lrnd {
+		return SourceDeterministic
+	}
+	// Return SourceReplay if the die replays a Recording
+	if d.grnd == d.xrnd {
+		return SourceReplay
+	}
+	// Return the kind of prnd otherwise
+	return d.src
 }
 
 // Seed seeds the die with seed s. Therefore it sets the currently used random number generator grnd
@@ -108,14 +320,21 @@ func (d *Die) Seed(s int64) error {
 		// Return an error if the initialization fails
 		return e
 	}
-	// Return an error if drnd is nil
-	if d.drnd == nil {
-		return tserr.NilPtr()
+	// Create drnd seeded with s, if not created yet, or set it back to the random number generator of tsrand, if the
+	// die has been seeded with another Algorithm. Otherwise, seed drnd with s.
+	if d.drnd == nil || d.alg != "" {
+		r, e := newSeeded(AlgorithmTsrand, s)
+		if e != nil {
+			return e
+		}
+		d.drnd, d.alg = r, ""
+	} else {
+		d.drnd.Seed(s)
 	}
-	// Seed the deterministic random number generator drnd
-	d.drnd.Seed(s)
 	// Set the currently used random number generator grnd to drnd
 	d.grnd = d.drnd
+	// Start the FairMode, if any, in its initial state
+	d.fr.reset()
 	// Return nil
 	return nil
 }
@@ -133,12 +352,10 @@ func (d *Die) NoSeed() error {
 		// Return an error if the initialization fails
 		return e
 	}
-	// Return an error if prnd is nil
-	if d.prnd == nil {
-		return tserr.NilPtr()
-	}
-	// Set the currently used random number generator grnd to prnd
+	// Set the currently used random number generator grnd to prnd, which is created on the next roll, if not created yet
 	d.grnd = d.prnd
+	// Start the FairMode, if any, in its initial state
+	d.fr.reset()
 	// Return nil
 	return nil
 }
@@ -0,0 +1,156 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library package sort as well as tserr
+import (
+	"sort" // sort
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// A Distribution holds the outcomes of rolling dice in ascending order together with the weight of each outcome.
+// The weight is either the number of occurrences of the outcome, e.g., for a histogram of results, or the
+// probability of the outcome, e.g., for the probability distribution of a dice expression.
+type Distribution struct {
+	v []int     // outcomes in ascending order
+	w []float64 // weight of each outcome
+}
+
+// NewHistogram returns a pointer to the Distribution of results y. The weight of each
+// outcome is the number of its occurrences in y. It returns nil and an error, if y is empty.
+func NewHistogram(y []int) (*Distribution, error) {
+	// Return an error if y is empty
+	if len(y) == 0 {
+		return nil, tserr.Empty("y")
+	}
+	// c holds the number of occurrences of each outcome
+	c := make(map[int]float64)
+	for _, v := range y {
+		c[v]++
+	}
+	// d holds the new Distribution
+	d := &Distribution{v: make([]int, 0, len(c)), w: make([]float64, 0, len(c))}
+	// Retrieve all outcomes
+	for v := range c {
+		d.v = append(d.v, v)
+	}
+	// Sort the outcomes in ascending order
+	sort.Ints(d.v)
+	// Retrieve the weight of each outcome
+	for _, v := range d.v {
+		d.w = append(d.w, c[v])
+	}
+	// Return the Distribution
+	return d, nil
+}
+
+// Values returns a copy of the outcomes of Distribution d in ascending order.
+func (d *Distribution) Values() []int {
+	// Return nil if d is nil
+	if d == nil {
+		return nil
+	}
+	// Return a copy of the outcomes
+	return append([]int(nil), d.v...)
+}
+
+// Weights returns a copy of the weights of the outcomes of Distribution d in the order of Values.
+func (d *Distribution) Weights() []float64 {
+	// Return nil if d is nil
+	if d == nil {
+		return nil
+	}
+	// Return a copy of the weights
+	return append([]float64(nil), d.w...)
+}
+
+// Total returns the sum of all weights of Distribution d.
+func (d *Distribution) Total() float64 {
+	// Return zero if d is nil
+	if d == nil {
+		return 0
+	}
+	// t holds the sum of weights
+	t := 0.0
+	for _, w := range d.w {
+		t += w
+	}
+	// Return the sum of weights
+	return t
+}
+
+// Probability returns the relative weight of outcome v in Distribution d. It returns
+// zero, if v is not an outcome of d.
+func (d *Distribution) Probability(v int) float64 {
+	// Retrieve the sum of weights
+	t := d.Total()
+	// Return zero if the Distribution is empty
+	if t == 0 {
+		return 0
+	}
+	// Search for outcome v
+	i := sort.SearchInts(d.v, v)
+	// Return zero if v is not an outcome of d
+	if i == len(d.v) || d.v[i] != v {
+		return 0
+	}
+	// Return the relative weight of v
+	return d.w[i] / t
+}
+
+// convolve returns the Distribution of the sum of outcomes of Distribution d and Distribution u.
+// Both d and u are expected to hold outcomes in ascending order without gaps of missing weights.
+func (d *Distribution) convolve(u *Distribution) *Distribution {
+	// The outcomes of the sum range from the sum of the lowest to the sum of the highest outcomes
+	lo, hi := d.v[0]+u.v[0], d.v[len(d.v)-1]+u.v[len(u.v)-1]
+	// r holds the resulting Distribution
+	r := &Distribution{v: make([]int, hi-lo+1), w: make([]float64, hi-lo+1)}
+	// Set the outcomes of the resulting Distribution
+	for i := range r.v {
+		r.v[i] = lo + i
+	}
+	// Add the product of weights to the corresponding sum of outcomes
+	for i := range d.v {
+		for j := range u.v {
+			r.w[d.v[i]+u.v[j]-lo] += d.w[i] * u.w[j]
+		}
+	}
+	// Return the resulting Distribution
+	return r
+}
+
+// add returns the Distribution holding the outcomes of Distribution d and Distribution u with the sum of their
+// weights. Both d and u are expected to hold outcomes in ascending order without gaps. If d is nil, u is returned.
+func (d *Distribution) add(u *Distribution) *Distribution {
+	// Return u if d is nil
+	if d == nil {
+		return u
+	}
+	// The outcomes range from the lowest to the highest outcome of d and u
+	lo, hi := min(d.v[0], u.v[0]), max(d.v[len(d.v)-1], u.v[len(u.v)-1])
+	// r holds the resulting Distribution
+	r := &Distribution{v: make([]int, hi-lo+1), w: make([]float64, hi-lo+1)}
+	// Set the outcomes of the resulting Distribution
+	for i := range r.v {
+		r.v[i] = lo + i
+	}
+	// Add the weights of both distributions to the corresponding outcomes
+	for _, x := range []*Distribution{d, u} {
+		for i, v := range x.v {
+			r.w[v-lo] += x.w[i]
+		}
+	}
+	// Return the resulting Distribution
+	return r
+}
+
+// reverse reverses the order of outcomes and weights of Distribution d.
+func (d *Distribution) reverse() {
+	// Swap outcomes and weights from both ends
+	for i, j := 0, len(d.v)-1; i < j; i, j = i+1, j-1 {
+		d.v[i], d.v[j] = d.v[j], d.v[i]
+		d.w[i], d.w[j] = d.w[j], d.w[i]
+	}
+}
@@ -0,0 +1,349 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages strconv, strings and time as well as tserr
+import (
+	"strconv" // strconv
+	"strings" // strings
+	"time"    // time
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// maxDice defines the maximum number of dice in a dice expression across all terms and
+// maxSides defines the maximum number of sides of a die in a dice expression. The bounds
+// limit the effort to roll an expression. maxRange defines the maximum range of outcomes
+// of a dice expression to calculate its probability distribution. maxModifier defines the
+// maximum value of a constant modifier, added or subtracted, so that totals cannot overflow.
+const (
+	maxDice     int = 1000
+	maxSides    int = 1000
+	maxRange    int = 10000
+	maxModifier int = 1000000
+)
+
+// A term is a part of a dice expression. It holds the number of dice n with s sides each. A term
+// with zero sides is a constant modifier n. The sign is either 1 or -1 for subtracted terms.
+type term struct {
+	n    int // number of dice or value of a constant modifier
+	s    int // number of sides of each die, zero for a constant modifier
+	sign int // sign of the term, either 1 or -1
+}
+
+// An Expr is a dice expression in common dice notation, e.g., 2d6+1d4-1. It holds the parsed terms of
+// the expression and a Die, which provides the random number generators to roll the expression.
+// An Expr can be seeded with Seed and set back to non-seeded random numbers with NoSeed.
+type Expr struct {
+	t  []term // terms of the dice expression
+	d  *Die   // Die providing the random number generators
+	st *Stats // Stats tracking the totals, if any
+}
+
+// ParseExpr parses dice expression s in common dice notation and returns a pointer to the Expr. The
+// expression is a sum of terms separated by + or -. A term is either a constant integer or NdS
+// for N dice with S sides. If N is omitted, it defaults to one die, e.g., d20. Whitespace is ignored.
+// It returns nil and an error, if s is not a valid dice expression.
+func ParseExpr(s string) (*Expr, error) {
+	// Parse the expression and notify the Observer, if any
+	t := time.Now()
+	x, e := parseExpr(s)
+	evaluated(OpParse, t, e)
+	return x, e
+}
+
+// parseExpr parses dice expression s as described for ParseExpr.
+func parseExpr(s string) (*Expr, error) {
+	// Split s at whitespace
+	f := strings.Fields(s)
+	// Return an error if whitespace separates two terms without an operator
+	for i := 1; i < len(f); i++ {
+		if !strings.ContainsAny(f[i-1][len(f[i-1])-1:]+f[i][:1], "+-") {
+			return nil, tserr.Check(&tserr.CheckArgs{F: s, Err: tserr.NotSet("operator")})
+		}
+	}
+	// Remove all whitespace from s
+	c := strings.Join(f, "")
+	// Return an error if the expression is empty
+	if c == "" {
+		return nil, tserr.Empty("dice expression")
+	}
+	// x holds the new dice expression
+	x := &Expr{}
+	// sign holds the sign of the next term
+	sign := 1
+	// Iterate the expression and split it into terms at + and -
+	for len(c) > 0 {
+		// Retrieve the sign of the term, if it is provided
+		switch c[0] {
+		case '+':
+			sign, c = 1, c[1:]
+		case '-':
+			sign, c = -1, c[1:]
+		default:
+			// Return an error, if the sign is missing between two terms
+			if len(x.t) > 0 {
+				return nil, tserr.Check(&tserr.CheckArgs{F: s, Err: tserr.NotSet("operator")})
+			}
+		}
+		// Retrieve the term up to the next operator
+		i := strings.IndexAny(c, "+-")
+		if i < 0 {
+			i = len(c)
+		}
+		// Parse the term
+		t, e := parseTerm(c[:i])
+		// Return an error, if the term is not valid
+		if e != nil {
+			return nil, tserr.Check(&tserr.CheckArgs{F: s, Err: e})
+		}
+		// Set the sign of the term and append it to the terms of the expression
+		t.sign = sign
+		x.t = append(x.t, t)
+		// Continue with the remaining expression
+		c = c[i:]
+	}
+	// Return an error if the number of dice across all terms exceeds maxDice
+	if n := x.Dice(); n > maxDice {
+		return nil, tserr.Check(&tserr.CheckArgs{F: s, Err: tserr.Lower(&tserr.LowerArgs{Var: "number of dice", Actual: int64(n), HigherBound: int64(maxDice + 1)})})
+	}
+	// Create the Die providing the random number generators
+	d, e := newDie(defaultN)
+	// Return an error, if the Die cannot be created
+	if e != nil {
+		return nil, e
+	}
+	x.d = d
+	// Return the dice expression
+	return x, nil
+}
+
+// parseTerm parses a single term t of a dice expression, which is either a constant integer or NdS.
+// It returns the term and an error, if any.
+func parseTerm(t string) (term, error) {
+	// Return an error if the term is empty
+	if t == "" {
+		return term{}, tserr.Empty("term")
+	}
+	// Retrieve the position of the die separator
+	i := strings.IndexAny(t, "dD")
+	// If the term has no die separator, it is a constant modifier
+	if i < 0 {
+		// Parse the constant
+		n, e := strconv.Atoi(t)
+		if e != nil {
+			return term{}, tserr.Op(&tserr.OpArgs{Op: "Atoi", Fn: t, Err: e})
+		}
+		// Return an error if the constant is out of bounds
+		if n > maxModifier {
+			return term{}, tserr.Lower(&tserr.LowerArgs{Var: "constant modifier", Actual: int64(n), HigherBound: int64(maxModifier + 1)})
+		}
+		// Return the constant modifier
+		return term{n: n}, nil
+	}
+	// The number of dice defaults to one
+	n := 1
+	// Parse the number of dice, if it is provided
+	if i > 0 {
+		var e error
+		if n, e = strconv.Atoi(t[:i]); e != nil {
+			return term{}, tserr.Op(&tserr.OpArgs{Op: "Atoi", Fn: t[:i], Err: e})
+		}
+	}
+	// Parse the number of sides
+	s, e := strconv.Atoi(t[i+1:])
+	if e != nil {
+		return term{}, tserr.Op(&tserr.OpArgs{Op: "Atoi", Fn: t[i+1:], Err: e})
+	}
+	// Return an error if the number of dice is out of bounds
+	if n < 1 {
+		return term{}, tserr.Higher(&tserr.HigherArgs{Var: "number of dice", Actual: int64(n), LowerBound: 1})
+	}
+	if n > maxDice {
+		return term{}, tserr.Lower(&tserr.LowerArgs{Var: "number of dice", Actual: int64(n), HigherBound: int64(maxDice + 1)})
+	}
+	// Return an error if the number of sides is out of bounds
+	if s < 2 {
+		return term{}, tserr.Higher(&tserr.HigherArgs{Var: "number of sides", Actual: int64(s), LowerBound: 2})
+	}
+	if s > maxSides {
+		return term{}, tserr.Lower(&tserr.LowerArgs{Var: "number of sides", Actual: int64(s), HigherBound: int64(maxSides + 1)})
+	}
+	// Return the term
+	return term{n: n, s: s}, nil
+}
+
+// String returns the dice expression x in normalized common dice notation, e.g., 2d6+1d4-1.
+func (x *Expr) String() string {
+	// Return an empty string, if x is nil
+	if x == nil {
+		return ""
+	}
+	// b holds the normalized expression
+	var b strings.Builder
+	// Iterate all terms
+	for i, t := range x.t {
+		// Write the operator
+		if t.sign < 0 {
+			b.WriteByte('-')
+		} else if i > 0 {
+			b.WriteByte('+')
+		}
+		// Write the constant modifier or the dice
+		if t.s == 0 {
+			b.WriteString(strconv.Itoa(t.n))
+		} else {
+			b.WriteString(strconv.Itoa(t.n) + "d" + strconv.Itoa(t.s))
+		}
+	}
+	// Return the normalized expression
+	return b.String()
+}
+
+// Dice returns the number of dice rolled by dice expression x, e.g., 3 for 2d6+1d4-1. Constant modifiers are not counted.
+func (x *Expr) Dice() int {
+	// Return zero, if x is nil
+	if x == nil {
+		return 0
+	}
+	// n holds the number of dice
+	n := 0
+	// Sum up the dice of all terms, which are not constant modifiers
+	for _, t := range x.t {
+		if t.s != 0 {
+			n += t.n
+		}
+	}
+	// Return the number of dice
+	return n
+}
+
+// sides returns the highest number of sides of the dice of dice expression x, or zero, if x holds no dice.
+func (x *Expr) sides() int {
+	s := 0
+	for _, t := range x.t {
+		s = max(s, t.s)
+	}
+	return s
+}
+
+// bounds returns the lowest and the highest result of the dice expression x.
+func (x *Expr) bounds() (lo, hi int) {
+	for _, t := range x.t {
+		switch {
+		// Constant modifiers shift both bounds
+		case t.s == 0:
+			lo, hi = lo+t.sign*t.n, hi+t.sign*t.n
+		// Added dice add at least one and at most the number of sides each
+		case t.sign > 0:
+			lo, hi = lo+t.n, hi+t.n*t.s
+		// Subtracted dice subtract at least one and at most the number of sides each
+		default:
+			lo, hi = lo-t.n*t.s, hi-t.n
+		}
+	}
+	return lo, hi
+}
+
+// Roll returns the result of rolling the dice expression x. It returns zero and an error, if any.
+func (x *Expr) Roll() (int, error) {
+	// Roll the dice expression
+	p, e := x.RollPool()
+	// Return zero and an error if rolling the expression fails
+	if e != nil {
+		return 0, e
+	}
+	// Return the total result
+	return p.Total, nil
+}
+
+// Seed seeds the dice expression x with seed s. Rolling the seeded expression returns a deterministic series of results.
+func (x *Expr) Seed(s int64) error {
+	// Return an error if x is nil
+	if x == nil {
+		return tserr.NilPtr()
+	}
+	// Seed the Die providing the random number generators
+	return x.d.Seed(s)
+}
+
+// SeedBytes seeds the dice expression x with byte slice b as described for Die.SeedBytes.
+func (x *Expr) SeedBytes(b []byte) error {
+	// Return an error if x is nil
+	if x == nil {
+		return tserr.NilPtr()
+	}
+	// Seed the Die providing the random number generators
+	return x.d.SeedBytes(b)
+}
+
+// SeedString seeds the dice expression x with string s as described for Die.SeedString.
+func (x *Expr) SeedString(s string) error {
+	// Return an error if x is nil
+	if x == nil {
+		return tserr.NilPtr()
+	}
+	// Seed the Die providing the random number generators
+	return x.d.SeedString(s)
+}
+
+// NoSeed sets the dice expression x back to the non-seeded random number generator.
+func (x *Expr) NoSeed() error {
+	// Return an error if x is nil
+	if x == nil {
+		return tserr.NilPtr()
+	}
+	// Set the Die providing the random number generators to non-seeded
+	return x.d.NoSeed()
+}
+
+// Distribution returns the exact probability distribution of the results of dice expression x. The
+// weight of each outcome is its probability. It returns nil and an error, if any.
+func (x *Expr) Distribution() (*Distribution, error) {
+	// Compute the distribution and notify the Observer, if any
+	t := time.Now()
+	d, e := x.distribution()
+	evaluated(OpDistribution, t, e)
+	return d, e
+}
+
+// distribution returns the exact probability distribution of the results of dice expression x.
+func (x *Expr) distribution() (*Distribution, error) {
+	// Return an error if x is nil
+	if x == nil {
+		return nil, tserr.NilPtr()
+	}
+	// r holds the range of outcomes of the dice expression, which constant modifiers do not change
+	lo, hi := x.bounds()
+	r := hi - lo
+	// Return an error if the range of outcomes exceeds maxRange
+	if r > maxRange {
+		return nil, tserr.Lower(&tserr.LowerArgs{Var: "range of outcomes", Actual: int64(r), HigherBound: int64(maxRange + 1)})
+	}
+	// Start with the distribution of the constant zero
+	d := &Distribution{v: []int{0}, w: []float64{1}}
+	// Iterate all terms
+	for _, t := range x.t {
+		// Shift the distribution by constant modifiers
+		if t.s == 0 {
+			d = d.convolve(&Distribution{v: []int{t.sign * t.n}, w: []float64{1}})
+			continue
+		}
+		// u holds the uniform distribution of a single die of the term
+		u := &Distribution{v: make([]int, t.s), w: make([]float64, t.s)}
+		for i := 0; i < t.s; i++ {
+			u.v[i], u.w[i] = t.sign*(i+1), 1/float64(t.s)
+		}
+		// Subtracted dice have descending outcomes, therefore reverse them to keep ascending order
+		if t.sign < 0 {
+			u.reverse()
+		}
+		// Convolve the distribution with each die of the term
+		for i := 0; i < t.n; i++ {
+			d = d.convolve(u)
+		}
+	}
+	// Return the distribution
+	return d, nil
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import package testing as well as tserr and lpstats
+import (
+	"testing" // testing
+
+	"github.com/thorstenrie/lpstats" // lpstats
+	"github.com/thorstenrie/tserr"   // tserr
+)
+
+// TestParseExpr parses valid and invalid dice expressions. The test fails if a valid
+// expression returns an error or is not normalized as expected, or if an invalid expression
+// does not return an error.
+func TestParseExpr(t *testing.T) {
+	// valid holds valid dice expressions, their normalized notation and number of dice
+	valid := []struct {
+		x, want string
+		n       int
+	}{
+		{"d20", "1d20", 1},
+		{"2d6+3", "2d6+3", 2},
+		{" 2D6 + 1d4 - 1 ", "2d6+1d4-1", 3},
+		{"-1d4+10", "-1d4+10", 1},
+		{"1d6-1000000", "1d6-1000000", 1},
+	}
+	// Iterate all valid expressions
+	for _, c := range valid {
+		x, e := ParseExpr(c.x)
+		// The test fails if ParseExpr returns an error
+		if e != nil {
+			t.Error(tserr.Op(&tserr.OpArgs{Op: "ParseExpr", Fn: c.x, Err: e}))
+			continue
+		}
+		// The test fails if the normalized notation does not match
+		if x.String() != c.want {
+			t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: c.x, Actual: x.String(), Want: c.want}))
+		}
+		// The test fails if the number of dice does not match
+		if x.Dice() != c.n {
+			t.Error(tserr.Equal(&tserr.EqualArgs{Var: "dice of " + c.x, Actual: int64(x.Dice()), Want: int64(c.n)}))
+		}
+	}
+	// Iterate all invalid expressions
+	for _, c := range []string{"", "d", "2d", "0d6", "2d1", "2d6++3", "2d6 3", "xd6", "2d6+", "1001d6", "d1001", "600d6+401d6", "1000d6-1d4", "1d6+9223372036854775807", "1d6-1000001", "1000001"} {
+		// The test fails if ParseExpr returns nil
+		if _, e := ParseExpr(c); e == nil {
+			t.Error(tserr.NilFailed("ParseExpr " + c))
+		}
+	}
+}
+
+// TestExprRoll rolls a seeded dice expression and compares the arithmetic mean and variance of
+// the results with its probability distribution. The test fails if they are not near equal or
+// a result is out of bounds.
+func TestExprRoll(t *testing.T) {
+	var (
+		// Roll the expression itr times
+		itr int = 1000000
+		// maxDiff holds the maximum difference of near equal floats
+		maxDiff float64 = 0.1
+		// y holds the results
+		y []int = make([]int, itr)
+	)
+	// Parse the dice expression
+	x, e := ParseExpr("2d6-1d4+3")
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "ParseExpr", Fn: "2d6-1d4+3", Err: e}))
+	}
+	// Roll the expression itr times
+	for i := range y {
+		if y[i], e = x.Roll(); e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Roll", Fn: "x", Err: e}))
+		}
+		// The test fails if a result is out of bounds
+		if y[i] < 2-4+3 || y[i] > 12-1+3 {
+			t.Fatal(tserr.Equal(&tserr.EqualArgs{Var: "result in bounds", Actual: int64(y[i]), Want: 0}))
+		}
+	}
+	// Retrieve the probability distribution of the expression
+	d, e := x.Distribution()
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Distribution", Fn: "x", Err: e}))
+	}
+	// Calculate the expected value and variance from the distribution
+	meane, varie := 0.0, 0.0
+	for i, v := range d.Values() {
+		meane += float64(v) * d.Weights()[i]
+	}
+	for i, v := range d.Values() {
+		varie += (float64(v) - meane) * (float64(v) - meane) * d.Weights()[i]
+	}
+	// The test fails if the sum of probabilities is not one
+	if !lpstats.NearEqual(d.Total(), 1, 1e-9) {
+		t.Error(tserr.Equalf(&tserr.EqualfArgs{Var: "sum of probabilities", Actual: d.Total(), Want: 1}))
+	}
+	// The test fails if the expected value does not match 7 - 2.5 + 3
+	if !lpstats.NearEqual(meane, 7.5, 1e-9) {
+		t.Error(tserr.Equalf(&tserr.EqualfArgs{Var: "expected value", Actual: meane, Want: 7.5}))
+	}
+	// Calculate arithmetic mean and variance of the results
+	mean, _ := lpstats.ArithmeticMean(y)
+	vari, _ := lpstats.Variance(y)
+	// The test fails if the arithmetic mean does not near equal the expected value
+	if !lpstats.NearEqual(mean, meane, maxDiff) {
+		t.Error(tserr.Equalf(&tserr.EqualfArgs{Var: "arithmetic mean of y", Actual: mean, Want: meane}))
+	}
+	// The test fails if the variance does not near equal the expected variance
+	if !lpstats.NearEqual(vari, varie, maxDiff) {
+		t.Error(tserr.Equalf(&tserr.EqualfArgs{Var: "variance of y", Actual: vari, Want: varie}))
+	}
+}
+
+// TestExprSeed rolls two dice expressions with the same seed. The test fails if the results differ.
+func TestExprSeed(t *testing.T) {
+	// Parse two identical dice expressions
+	x1, e1 := ParseExpr("3d8+d20")
+	x2, e2 := ParseExpr("3d8+d20")
+	if e1 != nil || e2 != nil {
+		t.Fatal(tserr.NotAvailable(&tserr.NotAvailableArgs{S: "ParseExpr", Err: tserr.NilPtr()}))
+	}
+	// Seed both expressions with the same seed
+	if e := x1.Seed(7); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Seed", Fn: "x1", Err: e}))
+	}
+	if e := x2.Seed(7); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Seed", Fn: "x2", Err: e}))
+	}
+	// The test fails if the results differ
+	for i := 0; i < 1000; i++ {
+		r1, _ := x1.Roll()
+		r2, _ := x2.Roll()
+		if r1 != r2 {
+			t.Fatal(tserr.Equal(&tserr.EqualArgs{Var: "r1", Actual: int64(r1), Want: int64(r2)}))
+		}
+	}
+}
+
+// TestExprNil checks if all methods of Expr return an error for a nil Expr.
+func TestExprNil(t *testing.T) {
+	// Define nil Expr x
+	var x *Expr = nil
+	// The test fails if Roll returns nil
+	if _, e := x.Roll(); e == nil {
+		t.Error(tserr.NilFailed("Roll"))
+	}
+	// The test fails if Seed returns nil
+	if e := x.Seed(0); e == nil {
+		t.Error(tserr.NilFailed("Seed"))
+	}
+	// The test fails if NoSeed returns nil
+	if e := x.NoSeed(); e == nil {
+		t.Error(tserr.NilFailed("NoSeed"))
+	}
+	// The test fails if Distribution returns nil
+	if _, e := x.Distribution(); e == nil {
+		t.Error(tserr.NilFailed("Distribution"))
+	}
+	// The test fails if Dice does not return zero
+	if n := x.Dice(); n != 0 {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "Dice", Actual: int64(n), Want: 0}))
+	}
+}
+
+// TestExprRange computes the distribution of dice expressions with large constant modifiers. The test fails
+// if the range of outcomes exceeds maxRange without an error or a valid expression returns an error.
+func TestExprRange(t *testing.T) {
+	// Constant modifiers do not hide the range of the dice from the bound
+	for _, c := range []string{"200d100+100000", "1000d1000+999000", "200d100-100000"} {
+		x, e := ParseExpr(c)
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "ParseExpr", Fn: c, Err: e}))
+		}
+		if _, e = x.Distribution(); e == nil {
+			t.Error(tserr.NilFailed("Distribution " + c))
+		}
+	}
+	// The range of outcomes of 100d100+5000 is within the bound
+	x, _ := ParseExpr("100d100+5000")
+	d, e := x.Distribution()
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Distribution", Fn: "100d100+5000", Err: e}))
+	}
+	if v := d.Values(); len(v) != 9901 || v[0] != 5100 {
+		t.Errorf("distribution of 100d100+5000 has %d outcomes from %d, expected 9901 from 5100", len(v), v[0])
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library package math/rand as well as tserr
+import (
+	"math/rand" // rand
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// maxStrength defines the maximum strength of a FairMode and fairStreak the maximum number of equal results in a
+// row of a die in mode FairLuck.
+const (
+	maxStrength int = 100
+	fairStreak  int = 3
+)
+
+// A FairMode defines how results of a die depend on previous results to feel fair to players. In FairBag and
+// FairLuck, long runs of equal results are prevented while each face is still rolled equally often in the long run.
+type FairMode int
+
+// Available modes
+const (
+	// FairOff rolls independent results, which is the default.
+	FairOff FairMode = iota
+	// FairBag draws results without replacement from a shuffle bag holding each face strength times. The bag is
+	// refilled when empty. Each face is rolled exactly strength times per bag and at most 2 * strength times in a row.
+	FairBag
+	// FairLuck weights each face by one plus strength times the number of rolls since it was last rolled, up to
+	// the number of sides. Faces not rolled for a while become more likely. A face is rolled at most three times in a row.
+	FairLuck
+)
+
+// fair holds the state of a die in a FairMode other than FairOff.
+type fair struct {
+	m      FairMode // mode
+	k      int      // strength
+	s      int      // number of sides
+	bag    []int    // faces remaining in the shuffle bag in FairBag
+	c      []int    // number of rolls since each face was last rolled in FairLuck
+	last   int      // last face rolled
+	streak int      // number of times the last face was rolled in a row
+}
+
+// reset sets f to its initial state with an empty shuffle bag and no previous rolls. It is a no-op, if f is nil.
+func (f *fair) reset() {
+	if f == nil {
+		return
+	}
+	f.bag, f.c, f.last, f.streak = f.bag[:0], make([]int, f.s), -1, 0
+}
+
+// next returns the next face of a die starting from zero rolled through g of Die d.
+func (f *fair) next(d *Die, g *rand.Rand) int {
+	// v holds the face
+	var v int
+	switch f.m {
+	case FairBag:
+		// Refill the shuffle bag, if empty
+		if len(f.bag) == 0 {
+			for i := 0; i < f.s*f.k; i++ {
+				f.bag = append(f.bag, i%f.s)
+			}
+		}
+		// Draw a face from the bag and remove it
+		i := d.intn(g, len(f.bag))
+		v = f.bag[i]
+		f.bag[i] = f.bag[len(f.bag)-1]
+		f.bag = f.bag[:len(f.bag)-1]
+	case FairLuck:
+		// w holds the total weight of all faces, the last face is excluded if rolled fairStreak times in a row
+		w := 0
+		for i := range f.c {
+			w += f.weight(i)
+		}
+		// Select the face of a uniformly distributed weight
+		r := d.intn(g, w)
+		for v = 0; r >= f.weight(v); v++ {
+			r -= f.weight(v)
+		}
+		// Count the rolls since each face was last rolled
+		for i := range f.c {
+			f.c[i] = min(f.c[i]+1, f.s)
+		}
+		f.c[v] = 0
+	}
+	// Count the times the face was rolled in a row
+	if v == f.last {
+		f.streak++
+	} else {
+		f.last, f.streak = v, 1
+	}
+	return v
+}
+
+// weight returns the weight of face i in FairLuck. It is zero for the last face, if rolled fairStreak times in a row.
+func (f *fair) weight(i int) int {
+	if i == f.last && f.streak >= fairStreak {
+		return 0
+	}
+	return 1 + f.k*f.c[i]
+}
+
+// face returns the face of Die d starting from zero rolled through g in its FairMode, if set.
+func (d *Die) face(g *rand.Rand) int {
+	if d.fr == nil {
+		return d.intn(g, d.s)
+	}
+	return d.fr.next(d, g)
+}
+
+// SetFair sets the FairMode of Die d to m with strength k. The strength must be between 1 and 100 and is ignored
+// for FairOff. The mode only applies to Roll, RollInto and RollN of d, not to dice expressions rolled with d. Setting
+// the mode and seeding d start with an empty shuffle bag and no previous rolls, so that equally seeded dice in the
+// same mode return the same series of results. It returns an error, if any.
+func (d *Die) SetFair(m FairMode, k int) error {
+	// Return an error if d is nil
+	if d == nil {
+		return tserr.NilPtr()
+	}
+	// Initialize the die if not initialized yet
+	if e := d.notSet(); e != nil {
+		// Return an error if the initialization fails
+		return e
+	}
+	switch m {
+	case FairOff:
+		d.fr = nil
+		return nil
+	case FairBag, FairLuck:
+	default:
+		return tserr.NotExistent("fair mode")
+	}
+	// Return an error if the strength is out of bounds
+	if k < 1 {
+		return tserr.Higher(&tserr.HigherArgs{Var: "strength", Actual: int64(k), LowerBound: 1})
+	}
+	if k > maxStrength {
+		return tserr.Lower(&tserr.LowerArgs{Var: "strength", Actual: int64(k), HigherBound: int64(maxStrength + 1)})
+	}
+	// Set the mode in its initial state
+	d.fr = &fair{m: m, k: k, s: d.s}
+	d.fr.reset()
+	// Return nil
+	return nil
+}
+
+// Fair returns the FairMode of Die d and its strength.
+func (d *Die) Fair() (FairMode, int) {
+	if d == nil || d.fr == nil {
+		return FairOff, 0
+	}
+	return d.fr.m, d.fr.k
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages math, strconv and testing as well as tserr
+import (
+	"math"    // math
+	"strconv" // strconv
+	"testing" // testing
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// TestFair rolls seeded d6 in each FairMode. The test fails if a face is rolled too often or too rarely in the long
+// run or the longest run of equal results exceeds the bound of the mode. Without a FairMode, the longest run exceeds
+// the bound of FairLuck.
+func TestFair(t *testing.T) {
+	// n holds the number of rolls
+	n := 60000
+	for _, c := range []struct {
+		m      FairMode // mode
+		k      int      // strength
+		streak int      // maximum number of equal results in a row, zero if unbounded
+	}{
+		{FairOff, 0, 0},
+		{FairBag, 1, 2},
+		{FairBag, 4, 8},
+		{FairLuck, 1, fairStreak},
+		{FairLuck, 10, fairStreak},
+	} {
+		d, _ := NewD6()
+		d.Seed(13)
+		if e := d.SetFair(c.m, c.k); e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "SetFair", Fn: "d6", Err: e}))
+		}
+		r, e := d.RollN(n)
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "RollN", Fn: "d6", Err: e}))
+		}
+		// f holds the number of results per face and l the longest run of equal results
+		f, l, run := make([]int, 7), 0, 0
+		for i, v := range r {
+			if v < 1 || v > 6 {
+				t.Fatal(tserr.Higher(&tserr.HigherArgs{Var: "result", Actual: int64(v), LowerBound: 1}))
+			}
+			f[v]++
+			if i > 0 && v == r[i-1] {
+				run++
+			} else {
+				run = 1
+			}
+			l = max(l, run)
+		}
+		// The test fails if a face deviates more than six standard deviations of independent rolls from its expected number of results
+		want, dev := float64(n)/6, 6*math.Sqrt(float64(n)*5/36)
+		for v := 1; v <= 6; v++ {
+			if math.Abs(float64(f[v])-want) > dev {
+				t.Errorf("mode %d strength %d: face %d rolled %d times, expected %.0f", c.m, c.k, v, f[v], want)
+			}
+		}
+		// The test fails if the longest run exceeds the bound of the mode or independent rolls have no long run
+		if c.streak > 0 && l > c.streak {
+			t.Errorf("mode %d strength %d: %d equal results in a row, expected at most %d", c.m, c.k, l, c.streak)
+		}
+		if c.streak == 0 && l <= fairStreak {
+			t.Errorf("independent rolls: at most %d equal results in a row", l)
+		}
+	}
+}
+
+// TestFairBag rolls a d20 in FairBag with Roll and RollInto. The test fails if a face is not rolled exactly
+// strength times per bag or equally seeded dice differ.
+func TestFairBag(t *testing.T) {
+	a, _ := NewD20()
+	b, _ := NewD20()
+	a.SetFair(FairBag, 3)
+	b.SetFair(FairBag, 3)
+	a.Seed(2)
+	b.Seed(2)
+	// Roll five bags
+	r := make([]int, 5*3*20)
+	if e := a.RollInto(r); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "RollInto", Fn: "d20", Err: e}))
+	}
+	for i := 0; i < len(r); i += 60 {
+		// f holds the number of results per face in the bag
+		f := make([]int, 21)
+		for j, v := range r[i : i+60] {
+			f[v]++
+			if w, _ := b.Roll(); w != v {
+				t.Fatal(tserr.Equal(&tserr.EqualArgs{Var: "result " + strconv.Itoa(i+j), Actual: int64(w), Want: int64(v)}))
+			}
+		}
+		for v := 1; v <= 20; v++ {
+			if f[v] != 3 {
+				t.Errorf("face %d rolled %d times in bag %d, expected 3", v, f[v], i/60)
+			}
+		}
+	}
+	// The mode is retrieved with Fair and FairOff removes it
+	if m, k := a.Fair(); m != FairBag || k != 3 {
+		t.Errorf("mode %d strength %d, expected %d and 3", m, k, FairBag)
+	}
+	a.SetFair(FairOff, 0)
+	if m, _ := a.Fair(); m != FairOff {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "mode", Actual: int64(m), Want: int64(FairOff)}))
+	}
+	// Invalid modes and strengths and nil pointers return an error
+	for _, c := range []struct{ m, k int }{{int(FairBag), 0}, {int(FairLuck), maxStrength + 1}, {7, 1}} {
+		if e := a.SetFair(FairMode(c.m), c.k); e == nil {
+			t.Error(tserr.NilFailed("SetFair"))
+		}
+	}
+	var z *Die
+	if e := z.SetFair(FairBag, 1); e == nil {
+		t.Error(tserr.NilFailed("SetFair"))
+	}
+}
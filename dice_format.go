@@ -0,0 +1,253 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages as well as tserr
+import (
+	"encoding/csv"  // csv
+	"encoding/json" // json
+	"fmt"           // fmt
+	"io"            // io
+	"strconv"       // strconv
+	"strings"       // strings
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// A Format defines the output format of an Encoder.
+type Format int
+
+// Available output formats of an Encoder
+const (
+	FormatText Format = iota // plain text, e.g., 4 for a roll
+	FormatJSON               // one JSON object per line
+	FormatCSV                // comma-separated values with a header line for each record type
+	FormatYAML               // YAML-like list entries
+)
+
+// formats holds the names of all available output formats
+var (
+	formats = map[Format]string{FormatText: "text", FormatJSON: "json", FormatCSV: "csv", FormatYAML: "yaml"}
+)
+
+// String returns the name of Format f, e.g., json.
+func (f Format) String() string {
+	// Return the name of the format, if it exists
+	if n, ok := formats[f]; ok {
+		return n
+	}
+	// Return unknown for undefined formats
+	return "unknown"
+}
+
+// ParseFormat returns the Format named s. Valid names are text, json, csv and yaml.
+// It returns FormatText and an error, if s does not name a Format.
+func ParseFormat(s string) (Format, error) {
+	// Search for the format named s
+	for f, n := range formats {
+		if n == strings.ToLower(s) {
+			return f, nil
+		}
+	}
+	// Return an error if the format does not exist
+	return FormatText, tserr.NotExistent("format " + s)
+}
+
+// An Encoder writes roll results, pool results and histories in a Format to an io.Writer. In
+// FormatCSV, a header line is written before the first record and each time the record type changes.
+// The natural and critical flags of a roll are written by their JSON names, e.g., crit_success. In
+// FormatText, the flags of each die are appended in parentheses to its value, e.g., 20(natural_max,crit_success).
+// In FormatCSV and FormatYAML, a pool holds the number of dice with each flag.
+type Encoder struct {
+	w io.Writer // destination of the encoded output
+	f Format    // output format
+	h string    // record type of the last written csv header
+}
+
+// NewEncoder returns a pointer to a new Encoder writing to w in Format f. It returns nil and an error,
+// if w is nil or f is not a valid Format.
+func NewEncoder(w io.Writer, f Format) (*Encoder, error) {
+	// Return an error if w is nil
+	if w == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Return an error if f is not a valid Format
+	if _, ok := formats[f]; !ok {
+		return nil, tserr.NotExistent("format " + strconv.Itoa(int(f)))
+	}
+	// Return the new Encoder
+	return &Encoder{w: w, f: f}, nil
+}
+
+// EncodeRoll writes RollResult r. It returns an error, if any.
+func (enc *Encoder) EncodeRoll(r RollResult) error {
+	// Return an error if enc is nil
+	if enc == nil {
+		return tserr.NilPtr()
+	}
+	// Write r in the format of the Encoder
+	switch enc.f {
+	case FormatJSON:
+		return enc.json(r)
+	case FormatCSV:
+		// f holds the flags of r
+		f := make([]string, len(flagNames))
+		for i, b := range r.flags() {
+			f[i] = strconv.FormatBool(b)
+		}
+		return enc.csv("roll", append([]string{"sides", "value"}, flagNames...), append([]string{strconv.Itoa(r.Sides), strconv.Itoa(r.Value)}, f...))
+	case FormatYAML:
+		// y holds the flags of r set to true
+		var y strings.Builder
+		for i, b := range r.flags() {
+			if b {
+				y.WriteString("  " + flagNames[i] + ": true\n")
+			}
+		}
+		return enc.printf("- sides: %d\n  value: %d\n%s", r.Sides, r.Value, y.String())
+	default:
+		return enc.printf("%d%s\n", r.Value, r.mark())
+	}
+}
+
+// EncodePool writes PoolResult p. It returns an error, if any.
+func (enc *Encoder) EncodePool(p *PoolResult) error {
+	// Return an error if enc or p is nil
+	if enc == nil || p == nil {
+		return tserr.NilPtr()
+	}
+	// v holds the rolled values, negative if subtracted, m the values with their flags and n the number of dice with each flag
+	v, m, n := make([]string, len(p.Rolls)), make([]string, len(p.Rolls)), make([]string, len(flagNames))
+	for i, r := range p.Rolls {
+		if r.Subtract {
+			v[i] = strconv.Itoa(-r.Value)
+		} else {
+			v[i] = strconv.Itoa(r.Value)
+		}
+		m[i] = v[i] + r.mark()
+	}
+	for i := range n {
+		n[i] = strconv.Itoa(p.count(func(r RollResult) bool { return r.flags()[i] }))
+	}
+	// Write p in the format of the Encoder
+	switch enc.f {
+	case FormatJSON:
+		return enc.json(p)
+	case FormatCSV:
+		return enc.csv("pool", append([]string{"expr", "rolls", "modifier", "total"}, flagNames...), append([]string{p.Expr, strings.Join(v, " "), strconv.Itoa(p.Modifier), strconv.Itoa(p.Total)}, n...))
+	case FormatYAML:
+		// y holds the number of dice with each flag
+		var y strings.Builder
+		for i := range n {
+			y.WriteString("  " + flagNames[i] + ": " + n[i] + "\n")
+		}
+		return enc.printf("- expr: %q\n  rolls: [%s]\n  modifier: %d\n  total: %d\n%s", p.Expr, strings.Join(v, ", "), p.Modifier, p.Total, y.String())
+	default:
+		return enc.printf("%s: [%s] %+d = %d\n", p.Expr, strings.Join(m, " "), p.Modifier, p.Total)
+	}
+}
+
+// flagNames holds the names of the natural and critical flags of a RollResult in the order of RollResult.flags
+var (
+	flagNames = []string{"natural_max", "natural_min", "crit_success", "crit_failure"}
+)
+
+// flags returns the natural and critical flags of RollResult r in the order of flagNames.
+func (r RollResult) flags() []bool {
+	return []bool{r.NaturalMax, r.NaturalMin, r.CritSuccess, r.CritFailure}
+}
+
+// mark returns the names of the flags of RollResult r set to true in parentheses, e.g., (natural_max,crit_success),
+// or an empty string, if no flag is set.
+func (r RollResult) mark() string {
+	// f holds the names of the flags set to true
+	var f []string
+	for i, b := range r.flags() {
+		if b {
+			f = append(f, flagNames[i])
+		}
+	}
+	// Return an empty string if no flag is set
+	if len(f) == 0 {
+		return ""
+	}
+	return "(" + strings.Join(f, ",") + ")"
+}
+
+// EncodeHistory writes the history h of results. It returns an error, if any.
+func (enc *Encoder) EncodeHistory(h []int) error {
+	// Return an error if enc is nil
+	if enc == nil {
+		return tserr.NilPtr()
+	}
+	// v holds the results as strings
+	v := make([]string, len(h))
+	for i, r := range h {
+		v[i] = strconv.Itoa(r)
+	}
+	// Write h in the format of the Encoder
+	switch enc.f {
+	case FormatJSON:
+		// Encode an empty history as empty array instead of null
+		if h == nil {
+			h = []int{}
+		}
+		return enc.json(struct {
+			History []int `json:"history"`
+		}{h})
+	case FormatCSV:
+		// Write one line for each result
+		for i := range v {
+			if e := enc.csv("history", []string{"index", "value"}, []string{strconv.Itoa(i + 1), v[i]}); e != nil {
+				return e
+			}
+		}
+		return nil
+	case FormatYAML:
+		return enc.printf("history: [%s]\n", strings.Join(v, ", "))
+	default:
+		return enc.printf("%s\n", strings.Join(v, " "))
+	}
+}
+
+// json writes v as JSON object in a single line. It returns an error, if any.
+func (enc *Encoder) json(v any) error {
+	// Encode v with a trailing newline
+	if e := json.NewEncoder(enc.w).Encode(v); e != nil {
+		return tserr.Op(&tserr.OpArgs{Op: "encode", Fn: "json", Err: e})
+	}
+	// Return nil
+	return nil
+}
+
+// csv writes record r of type t. If the type differs from the type of the previous record,
+// header h is written first. It returns an error, if any.
+func (enc *Encoder) csv(t string, h, r []string) error {
+	// Create a csv writer
+	w := csv.NewWriter(enc.w)
+	// Write the header, if the record type changed
+	if enc.h != t {
+		if e := w.Write(h); e != nil {
+			return tserr.Op(&tserr.OpArgs{Op: "write", Fn: "csv header", Err: e})
+		}
+		enc.h = t
+	}
+	// Write the record
+	if e := w.Write(r); e != nil {
+		return tserr.Op(&tserr.OpArgs{Op: "write", Fn: "csv record", Err: e})
+	}
+	// Flush the writer and return an error, if any
+	w.Flush()
+	return tserr.Op(&tserr.OpArgs{Op: "flush", Fn: "csv", Err: w.Error()})
+}
+
+// printf writes the formatted string f with arguments a. It returns an error, if any.
+func (enc *Encoder) printf(f string, a ...any) error {
+	// Write the formatted string
+	if _, e := fmt.Fprintf(enc.w, f, a...); e != nil {
+		return tserr.Op(&tserr.OpArgs{Op: "write", Fn: "output", Err: e})
+	}
+	// Return nil
+	return nil
+}
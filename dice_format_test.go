@@ -0,0 +1,114 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages as well as tserr
+import (
+	"encoding/json" // json
+	"strings"       // strings
+	"testing"       // testing
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// TestFormat encodes a roll, a pool and a history with natural and critical flags in each Format. The test fails
+// if the encoded output does not match the expected output.
+func TestFormat(t *testing.T) {
+	var (
+		// r holds a roll result
+		r = RollResult{Sides: 6, Value: 6, NaturalMax: true, CritSuccess: true}
+		// p holds a pool result
+		p = &PoolResult{Expr: "2d6-1d4+3", Rolls: []RollResult{{Sides: 6, Value: 4}, {Sides: 6, Value: 2}, {Sides: 4, Value: 1, Subtract: true, NaturalMin: true, CritFailure: true}}, Modifier: 3, Total: 8}
+		// h holds a history
+		h = []int{4, 2, 6}
+	)
+	// tc holds the expected output for each Format
+	tc := []struct {
+		f    string
+		want string
+	}{
+		{"text", "6(natural_max,crit_success)\n2d6-1d4+3: [4 2 -1(natural_min,crit_failure)] +3 = 8\n4 2 6\n"},
+		{"JSON", `{"sides":6,"value":6,"natural_max":true,"crit_success":true}` + "\n" +
+			`{"expr":"2d6-1d4+3","rolls":[{"sides":6,"value":4},{"sides":6,"value":2},{"sides":4,"value":1,"subtract":true,"natural_min":true,"crit_failure":true}],"modifier":3,"total":8}` + "\n" +
+			`{"history":[4,2,6]}` + "\n"},
+		{"csv", "sides,value,natural_max,natural_min,crit_success,crit_failure\n6,6,true,false,true,false\n" +
+			"expr,rolls,modifier,total,natural_max,natural_min,crit_success,crit_failure\n2d6-1d4+3,4 2 -1,3,8,0,1,0,1\n" +
+			"index,value\n1,4\n2,2\n3,6\n"},
+		{"yaml", "- sides: 6\n  value: 6\n  natural_max: true\n  crit_success: true\n" +
+			"- expr: \"2d6-1d4+3\"\n  rolls: [4, 2, -1]\n  modifier: 3\n  total: 8\n  natural_max: 0\n  natural_min: 1\n  crit_success: 0\n  crit_failure: 1\n" +
+			"history: [4, 2, 6]\n"},
+	}
+	// Iterate all formats
+	for _, c := range tc {
+		// Parse the name of the format
+		f, e := ParseFormat(c.f)
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "ParseFormat", Fn: c.f, Err: e}))
+		}
+		// b holds the encoded output
+		var b strings.Builder
+		// Create the Encoder
+		enc, e := NewEncoder(&b, f)
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "NewEncoder", Fn: c.f, Err: e}))
+		}
+		// Encode roll, pool and history
+		if e = enc.EncodeRoll(r); e != nil {
+			t.Error(tserr.Op(&tserr.OpArgs{Op: "EncodeRoll", Fn: c.f, Err: e}))
+		}
+		if e = enc.EncodePool(p); e != nil {
+			t.Error(tserr.Op(&tserr.OpArgs{Op: "EncodePool", Fn: c.f, Err: e}))
+		}
+		if e = enc.EncodeHistory(h); e != nil {
+			t.Error(tserr.Op(&tserr.OpArgs{Op: "EncodeHistory", Fn: c.f, Err: e}))
+		}
+		// The test fails if the output does not match
+		if b.String() != c.want {
+			t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: c.f, Actual: b.String(), Want: c.want}))
+		}
+	}
+	// The test fails if ParseFormat returns nil for an unknown format
+	if _, e := ParseFormat("xml"); e == nil {
+		t.Error(tserr.NilFailed("ParseFormat"))
+	}
+}
+
+// TestRollPool rolls a dice expression and decodes its JSON encoding. The test fails if
+// the decoded total does not match the sum of the rolls and the modifier.
+func TestRollPool(t *testing.T) {
+	// Parse the dice expression
+	x, e := ParseExpr("3d6-d4+2")
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "ParseExpr", Fn: "3d6-d4+2", Err: e}))
+	}
+	// Roll the expression
+	p, e := x.RollPool()
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "RollPool", Fn: "x", Err: e}))
+	}
+	// Encode the result in JSON
+	var b strings.Builder
+	enc, _ := NewEncoder(&b, FormatJSON)
+	if e = enc.EncodePool(p); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "EncodePool", Fn: "p", Err: e}))
+	}
+	// Decode the result
+	var q PoolResult
+	if e = json.Unmarshal([]byte(b.String()), &q); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Unmarshal", Fn: "p", Err: e}))
+	}
+	// sum holds the sum of rolls and modifier
+	sum := q.Modifier
+	for _, r := range q.Rolls {
+		if r.Subtract {
+			sum -= r.Value
+		} else {
+			sum += r.Value
+		}
+	}
+	// The test fails if the number of rolls or the total does not match
+	if len(q.Rolls) != 4 || sum != q.Total || q.Modifier != 2 {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "total", Actual: int64(q.Total), Want: int64(sum)}))
+	}
+}
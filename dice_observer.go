@@ -0,0 +1,81 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages sync/atomic and time
+import (
+	"sync/atomic" // atomic
+	"time"        // time
+)
+
+// A Source is the kind of random number generator used to roll a die.
+type Source int
+
+// Available kinds of random number generators
+const (
+	SourceCrypto        Source = iota // cryptographically secure random number generator
+	SourcePseudo                      // pseudo-random number generator, used if the cryptographically secure one is not available
+	SourceDeterministic               // deterministic pseudo-random number generator of a seeded die
+	SourceReplay                      // replayed Recording
+)
+
+// String returns the name of Source s.
+func (s Source) String() string {
+	switch s {
+	case SourcePseudo:
+		return "pseudo"
+	case SourceDeterministic:
+		return "deterministic"
+	case SourceReplay:
+		return "replay"
+	default:
+		return "crypto"
+	}
+}
+
+// Operations on dice expressions reported to an Observer
+const (
+	OpParse        string = "parse"        // ParseExpr
+	OpRoll         string = "roll"         // Expr.RollWith, Expr.RollPool and Expr.Roll
+	OpDistribution string = "distribution" // Expr.Distribution
+)
+
+// An Observer is notified of events of all dice, e.g., to collect metrics. Rolled is called for each rolled die
+// with its number of sides and the kind of random number generator. Fallback is called, if the cryptographically
+// secure random number generator is not available when a die is initialized and a pseudo-random number generator
+// is used instead. Evaluated is called after each operation op on a dice expression with its duration and error,
+// if any. An Observer must be safe for concurrent use by multiple goroutines.
+type Observer interface {
+	Rolled(sides int, src Source)
+	Fallback(err error)
+	Evaluated(op string, d time.Duration, err error)
+}
+
+// observerBox holds the Observer, so that the atomic value always stores the same concrete type
+type observerBox struct {
+	o Observer // Observer, may be nil
+}
+
+// obs holds the current Observer in an observerBox
+var (
+	obs atomic.Value
+)
+
+// SetObserver sets the Observer notified of events of all dice. If o is nil, no Observer is notified.
+func SetObserver(o Observer) {
+	obs.Store(observerBox{o: o})
+}
+
+// observer returns the current Observer. It returns nil, if no Observer is set.
+func observer() Observer {
+	b, _ := obs.Load().(observerBox)
+	return b.o
+}
+
+// evaluated notifies the current Observer, if any, that operation op started at t ended with error e.
+func evaluated(op string, t time.Time, e error) {
+	if o := observer(); o != nil {
+		o.Evaluated(op, time.Since(t), e)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages sync, testing and time as well as tserr
+import (
+	"sync"    // sync
+	"testing" // testing
+	"time"    // time
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// recorder is an Observer recording all events
+type recorder struct {
+	mu     sync.Mutex     // mutex to enable concurrency
+	rolled map[Source]int // rolled dice by kind of random number generator
+	sides  map[int]int    // rolled dice by number of sides
+	ops    map[string]int // operations by name
+	errs   map[string]int // errors by operation
+}
+
+// Rolled records a rolled die.
+func (r *recorder) Rolled(sides int, src Source) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rolled[src]++
+	r.sides[sides]++
+}
+
+// Fallback is not recorded.
+func (r *recorder) Fallback(err error) {}
+
+// Evaluated records an operation and its error, if any.
+func (r *recorder) Evaluated(op string, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ops[op]++
+	if err != nil {
+		r.errs[op]++
+	}
+}
+
+// TestObserver rolls seeded and non-seeded dice and dice expressions with an Observer. The test fails
+// if the recorded events do not match the rolled dice and operations.
+func TestObserver(t *testing.T) {
+	// Set the Observer
+	r := &recorder{rolled: make(map[Source]int), sides: make(map[int]int), ops: make(map[string]int), errs: make(map[string]int)}
+	SetObserver(r)
+	defer SetObserver(nil)
+	// Roll a seeded d8 twice and a non-seeded d8 once
+	d, _ := NewD8()
+	d.Seed(1)
+	d.Roll()
+	d.Roll()
+	d.NoSeed()
+	d.Roll()
+	// Roll an expression, compute a distribution and fail to parse an expression
+	x, _ := ParseExpr("3d4+1")
+	x.Roll()
+	x.Distribution()
+	ParseExpr("3d4+")
+	// The test fails if the recorded events do not match
+	for _, c := range []struct {
+		n         string
+		got, want int
+	}{
+		{"deterministic rolls", r.rolled[SourceDeterministic], 2},
+		{"non-seeded rolls", r.rolled[SourceCrypto] + r.rolled[SourcePseudo], 4},
+		{"d8 rolls", r.sides[8], 3},
+		{"d4 rolls", r.sides[4], 3},
+		{"parse operations", r.ops[OpParse], 2},
+		{"parse errors", r.errs[OpParse], 1},
+		{"roll operations", r.ops[OpRoll], 1},
+		{"distribution operations", r.ops[OpDistribution], 1},
+	} {
+		if c.got != c.want {
+			t.Error(tserr.Equal(&tserr.EqualArgs{Var: c.n, Actual: int64(c.got), Want: int64(c.want)}))
+		}
+	}
+	// No events are recorded after removing the Observer
+	SetObserver(nil)
+	d.Roll()
+	if r.sides[8] != 3 {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "d8 rolls without Observer", Actual: int64(r.sides[8]), Want: 3}))
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages as well as tserr
+import (
+	"bytes"           // bytes
+	"encoding/binary" // binary
+	"io"              // io
+	"math/rand"       // rand
+	"strconv"         // strconv
+	"sync"            // sync
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// recordMagic identifies the binary format of a Recording and its current version 2 with varint values.
+// recordMagicV1 identifies version 1 with fixed-size values, which is still read.
+var (
+	recordMagic   = []byte("LPDR\x02")
+	recordMagicV1 = []byte("LPDR\x01")
+)
+
+// A Recording holds every value produced by the random number generator of a Die while recording. It is
+// created with Die.Record and replayed with Die.Replay. It can be written to and read from a compact binary
+// format with WriteTo and ReadRecording. A Recording is safe for concurrent use by multiple goroutines.
+type Recording struct {
+	mu sync.Mutex // mutex to enable concurrency
+	v  []uint64   // recorded values
+}
+
+// Len returns the number of recorded values of r.
+func (r *Recording) Len() int {
+	// Return zero, if r is nil
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.v)
+}
+
+// add appends value v to Recording r.
+func (r *Recording) add(v uint64) {
+	r.mu.Lock()
+	r.v = append(r.v, v)
+	r.mu.Unlock()
+}
+
+// at returns the value at position i of Recording r and true. It returns zero and false, if i is out of range.
+func (r *Recording) at(i int) (uint64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i >= len(r.v) {
+		return 0, false
+	}
+	return r.v[i], true
+}
+
+// WriteTo writes Recording r to w in a compact binary format. The format consists of the header LPDR with version
+// byte 2 followed by each value as unsigned varint as encoded by binary.AppendUvarint. It returns the number of bytes
+// written and an error, if any.
+func (r *Recording) WriteTo(w io.Writer) (int64, error) {
+	// Return an error if r is nil
+	if r == nil {
+		return 0, tserr.NilPtr()
+	}
+	// b holds the binary format
+	r.mu.Lock()
+	b := append(make([]byte, 0, len(recordMagic)+binary.MaxVarintLen64*len(r.v)), recordMagic...)
+	for _, v := range r.v {
+		b = binary.AppendUvarint(b, v)
+	}
+	r.mu.Unlock()
+	// Write the binary format
+	n, e := w.Write(b)
+	if e != nil {
+		return int64(n), tserr.Op(&tserr.OpArgs{Op: "write", Fn: "recording", Err: e})
+	}
+	return int64(n), nil
+}
+
+// ReadRecording reads a Recording in the binary format written by WriteTo from rd. It also reads Recordings in the
+// binary format of version 1, with header LPDR and version byte 1 followed by each value as eight bytes in
+// little-endian byte order. It returns nil and an error, if the Recording cannot be read or is not in one of the
+// binary formats.
+func ReadRecording(rd io.Reader) (*Recording, error) {
+	// Return an error if rd is nil
+	if rd == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Read the binary format
+	b, e := io.ReadAll(rd)
+	if e != nil {
+		return nil, tserr.Op(&tserr.OpArgs{Op: "read", Fn: "recording", Err: e})
+	}
+	// Decode the values of version 1
+	if bytes.HasPrefix(b, recordMagicV1) {
+		return readRecordingV1(b[len(recordMagicV1):])
+	}
+	// Return an error if the header does not match
+	if !bytes.HasPrefix(b, recordMagic) {
+		return nil, tserr.Check(&tserr.CheckArgs{F: "recording", Err: tserr.NotExistent("header LPDR version 1 or 2")})
+	}
+	b = b[len(recordMagic):]
+	// Decode the values and return an error if a value is truncated or overflows
+	r := &Recording{}
+	for len(b) > 0 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, tserr.Check(&tserr.CheckArgs{F: "recording", Err: tserr.NotExistent("varint value " + strconv.Itoa(len(r.v)))})
+		}
+		r.v, b = append(r.v, v), b[n:]
+	}
+	// Return the Recording
+	return r, nil
+}
+
+// readRecordingV1 decodes the values of a Recording in the binary format of version 1 from b without header. It
+// returns nil and an error, if b holds trailing bytes.
+func readRecordingV1(b []byte) (*Recording, error) {
+	// Return an error if the length does not match
+	if len(b)%8 != 0 {
+		return nil, tserr.Check(&tserr.CheckArgs{F: "recording", Err: tserr.Equal(&tserr.EqualArgs{Var: "trailing bytes", Actual: int64(len(b) % 8), Want: 0})})
+	}
+	// Decode the values
+	r := &Recording{v: make([]uint64, 0, len(b)/8)}
+	for i := 0; i < len(b); i += 8 {
+		r.v = append(r.v, binary.LittleEndian.Uint64(b[i:]))
+	}
+	// Return the Recording
+	return r, nil
+}
+
+// recordSource is a source of random numbers, which passes through the values of the currently used random
+// number generator grnd of Die d and records them in Recording r.
+type recordSource struct {
+	d *Die       // recorded Die
+	r *Recording // Recording holding the values
+}
+
+// Int63 returns the next 63-bit value of the recorded Die and records it.
+func (s *recordSource) Int63() int64 {
+	v := s.d.grnd.Int63()
+	s.r.add(uint64(v))
+	return v
+}
+
+// Uint64 returns the next 64-bit value of the recorded Die and records it.
+func (s *recordSource) Uint64() uint64 {
+	v := s.d.grnd.Uint64()
+	s.r.add(v)
+	return v
+}
+
+// Seed is empty, the recorded Die is seeded with Die.Seed.
+func (s *recordSource) Seed(int64) {}
+
+// replaySource is a source of random numbers, which returns the values of Recording r in order. If all values
+// have been returned, it returns zero and sets err.
+type replaySource struct {
+	r   *Recording // replayed Recording
+	i   int        // position of the next value
+	err error      // error, if the Recording is exhausted
+}
+
+// Uint64 returns the next recorded value.
+func (s *replaySource) Uint64() uint64 {
+	v, ok := s.r.at(s.i)
+	if !ok {
+		s.err = tserr.NotAvailable(&tserr.NotAvailableArgs{S: "recorded value", Err: io.EOF})
+		return 0
+	}
+	s.i++
+	return v
+}
+
+// Int63 returns the next recorded value as 63-bit value.
+func (s *replaySource) Int63() int64 {
+	return int64(s.Uint64() & ^uint64(1<<63))
+}
+
+// Seed is empty, a Recording cannot be seeded.
+func (s *replaySource) Seed(int64) {}
+
+// Record starts recording every value produced by the random number generator of Die d and returns the new
+// Recording. Recording continues after Seed, NoSeed and Replay until StopRecording is called. A previous
+// Recording of d is stopped. It returns nil and an error, if any.
+func (d *Die) Record() (*Recording, error) {
+	// Return an error if d is nil
+	if d == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Initialize the die if not initialized yet
+	if e := d.notSet(); e != nil {
+		// Return nil and an error if the initialization fails
+		return nil, e
+	}
+	// Wrap the currently used random number generator with a recordSource
+	r := &Recording{}
+	d.rrnd = rand.New(&recordSource{d: d, r: r})
+	// Return the Recording
+	return r, nil
+}
+
+// StopRecording stops recording the values of the random number generator of Die d.
+func (d *Die) StopRecording() error {
+	// Return an error if d is nil
+	if d == nil {
+		return tserr.NilPtr()
+	}
+	d.rrnd = nil
+	return nil
+}
+
+// Replay sets the random number generator of Die d to the values of Recording r. Rolling d returns the same
+// results as the recorded Die, if it has the same number of sides and rolls the same dice. Rolling d returns an
+// error, if all values of r have been replayed. Replay ends with Seed or NoSeed. It returns an error, if any.
+func (d *Die) Replay(r *Recording) error {
+	// Return an error if d or r is nil
+	if d == nil || r == nil {
+		return tserr.NilPtr()
+	}
+	// Initialize the die if not initialized yet
+	if e := d.notSet(); e != nil {
+		// Return an error if the initialization fails
+		return e
+	}
+	// Set the currently used random number generator grnd to the replayed Recording
+	d.play = &replaySource{r: r}
+	d.xrnd = rand.New(d.play)
+	d.grnd = d.xrnd
+	// Start the FairMode, if any, in its initial state
+	d.fr.reset()
+	// Return nil
+	return nil
+}
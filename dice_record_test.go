@@ -0,0 +1,175 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages bytes, binary, math, reflect, strconv, strings and testing as well as tserr
+import (
+	"bytes"           // bytes
+	"encoding/binary" // binary
+	"math"            // math
+	"reflect"         // reflect
+	"strconv"         // strconv
+	"strings"         // strings
+	"testing"         // testing
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// TestRecordReplay records a non-seeded d20 and a dice expression rolled with it, writes and reads the
+// Recording and replays it with a new d20. The test fails if the replayed results differ from the recorded
+// results or rolling beyond the end of the Recording does not return an error.
+func TestRecordReplay(t *testing.T) {
+	// Record a non-seeded d20
+	d, _ := NewD20()
+	r, e := d.Record()
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Record", Fn: "d20", Err: e}))
+	}
+	x, _ := ParseExpr("3d6+2d8")
+	// roll rolls d and x with Die d in turn and returns the results
+	roll := func(d *Die) []int {
+		var res []int
+		for i := 0; i < 50; i++ {
+			v, e := d.Roll()
+			if e != nil {
+				t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Roll", Fn: "d20", Err: e}))
+			}
+			p, e := x.RollWith(d)
+			if e != nil {
+				t.Fatal(tserr.Op(&tserr.OpArgs{Op: "RollWith", Fn: x.String(), Err: e}))
+			}
+			res = append(res, v, p.Total)
+		}
+		return res
+	}
+	want := roll(d)
+	d.StopRecording()
+	// Rolls after stopping are not recorded
+	n := r.Len()
+	d.Roll()
+	if r.Len() != n {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "recorded values after StopRecording", Actual: int64(r.Len()), Want: int64(n)}))
+	}
+	// Write and read the Recording
+	var b bytes.Buffer
+	if _, e = r.WriteTo(&b); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "WriteTo", Fn: "recording", Err: e}))
+	}
+	size := 5
+	for _, v := range r.v {
+		size += len(binary.AppendUvarint(nil, v))
+	}
+	if b.Len() != size {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "size of recording", Actual: int64(b.Len()), Want: int64(size)}))
+	}
+	q, e := ReadRecording(&b)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "ReadRecording", Fn: "recording", Err: e}))
+	}
+	// Replay the Recording with a new d20
+	g, _ := NewD20()
+	if e = g.Replay(q); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Replay", Fn: "d20", Err: e}))
+	}
+	if got := roll(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed results %v do not match recorded results %v", got, want)
+	}
+	if g.source() != SourceReplay {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "source", Actual: g.source().String(), Want: SourceReplay.String()}))
+	}
+	// Rolling beyond the end of the Recording returns an error
+	if _, e = g.Roll(); e == nil {
+		t.Error(tserr.NilFailed("Roll beyond the end of the recording"))
+	}
+	// Seed ends the replay
+	if g.Seed(1); g.source() != SourceDeterministic {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "source after Seed", Actual: g.source().String(), Want: SourceDeterministic.String()}))
+	}
+}
+
+// TestRecordSeeded records a seeded d6. The test fails if replaying the Recording differs from the seeded die.
+func TestRecordSeeded(t *testing.T) {
+	// Record a seeded d6
+	d, _ := NewD6()
+	d.Seed(42)
+	r, _ := d.Record()
+	var want []int
+	for i := 0; i < 20; i++ {
+		v, _ := d.Roll()
+		want = append(want, v)
+	}
+	// Replay the Recording twice with the same Die
+	g, _ := NewD6()
+	for k := 0; k < 2; k++ {
+		g.Replay(r)
+		for i, w := range want {
+			if v, _ := g.Roll(); v != w {
+				t.Error(tserr.Equal(&tserr.EqualArgs{Var: "replayed roll " + strconv.Itoa(i), Actual: int64(v), Want: int64(w)}))
+			}
+		}
+	}
+}
+
+// TestRecordingVersion writes a Recording with varint values and reads a Recording in the binary format of version 1.
+// The test fails if the values differ or small values are not encoded in fewer bytes than in version 1.
+func TestRecordingVersion(t *testing.T) {
+	// v holds the values
+	v := []uint64{0, 1, 127, 128, 1 << 40, math.MaxUint64}
+	// Write the values with varints
+	var b bytes.Buffer
+	if _, e := (&Recording{v: v}).WriteTo(&b); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "WriteTo", Fn: "recording", Err: e}))
+	}
+	if n := b.Len(); !strings.HasPrefix(b.String(), "LPDR\x02") || n != 5+1+1+1+2+6+10 {
+		t.Errorf("recording of %d bytes with header %q", n, b.String()[:5])
+	}
+	// w holds the values in the binary format of version 1
+	w := []byte("LPDR\x01")
+	for _, x := range v {
+		w = binary.LittleEndian.AppendUint64(w, x)
+	}
+	// Both versions are read
+	for _, c := range [][]byte{b.Bytes(), w} {
+		r, e := ReadRecording(bytes.NewReader(c))
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "ReadRecording", Fn: string(c[:5]), Err: e}))
+		}
+		if !reflect.DeepEqual(r.v, v) {
+			t.Errorf("read values %v, expected %v", r.v, v)
+		}
+	}
+}
+
+// TestRecordingErrors reads invalid Recordings and calls the methods on nil pointers. The test fails if no error is returned.
+func TestRecordingErrors(t *testing.T) {
+	// Read invalid Recordings
+	for _, c := range []string{"", "LPDR", "LPDR\x03", "LPDR\x01\x00\x00", "LPDR\x02\x80", "LPDR\x02\xff\xff\xff\xff\xff\xff\xff\xff\xff\x02"} {
+		if _, e := ReadRecording(strings.NewReader(c)); e == nil {
+			t.Error(tserr.NilFailed("ReadRecording " + c))
+		}
+	}
+	if _, e := ReadRecording(nil); e == nil {
+		t.Error(tserr.NilFailed("ReadRecording"))
+	}
+	// Call the methods on nil pointers
+	var (
+		d *Die
+		r *Recording
+	)
+	if _, e := d.Record(); e == nil {
+		t.Error(tserr.NilFailed("Record"))
+	}
+	if e := d.StopRecording(); e == nil {
+		t.Error(tserr.NilFailed("StopRecording"))
+	}
+	if e := d.Replay(&Recording{}); e == nil {
+		t.Error(tserr.NilFailed("Replay"))
+	}
+	if _, e := r.WriteTo(&bytes.Buffer{}); e == nil {
+		t.Error(tserr.NilFailed("WriteTo"))
+	}
+	if r.Len() != 0 {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "Len", Actual: int64(r.Len()), Want: 0}))
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library package time and tserr
+import (
+	"time" // time
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// A RollResult holds the result of rolling a single die. It contains the number of sides of the die
+// and the rolled value. If the die is subtracted in a dice expression, Subtract is true. The natural and
+// critical flags refer to the rolled face, also if the die is subtracted. Critical results are defined by
+// the critical ranges set with SetCrits.
+type RollResult struct {
+	Sides       int  `json:"sides"`                  // number of sides of the die
+	Value       int  `json:"value"`                  // rolled value
+	Subtract    bool `json:"subtract,omitempty"`     // true if the value is subtracted in a dice expression
+	NaturalMax  bool `json:"natural_max,omitempty"`  // true if the value is the highest face
+	NaturalMin  bool `json:"natural_min,omitempty"`  // true if the value is the lowest face
+	CritSuccess bool `json:"crit_success,omitempty"` // true if the value is a critical success
+	CritFailure bool `json:"crit_failure,omitempty"` // true if the value is a critical failure
+}
+
+// A PoolResult holds the result of rolling a dice expression. It contains the normalized dice expression,
+// the result of each rolled die, the sum of all constant modifiers and the total result.
+type PoolResult struct {
+	Expr     string       `json:"expr"`     // normalized dice expression
+	Rolls    []RollResult `json:"rolls"`    // result of each rolled die
+	Modifier int          `json:"modifier"` // sum of constant modifiers
+	Total    int          `json:"total"`    // total result
+}
+
+// Sides returns the number of sides of Die d. It returns zero and an error, if any.
+func (d *Die) Sides() (int, error) {
+	// Return an error if d is nil
+	if d == nil {
+		return 0, tserr.NilPtr()
+	}
+	// Initialize the die if not initialized yet
+	if e := d.notSet(); e != nil {
+		// Return zero and an error if the initialization fails
+		return 0, e
+	}
+	// Return the number of sides
+	return d.s, nil
+}
+
+// RollResult returns the result of rolling the die together with its number of sides.
+// It returns an empty RollResult and an error, if any.
+func (d *Die) RollResult() (RollResult, error) {
+	// Roll the die
+	v, e := d.Roll()
+	// Return an empty RollResult and an error, if rolling the die fails
+	if e != nil {
+		return RollResult{}, e
+	}
+	// Return the RollResult with its natural and critical flags
+	r := RollResult{Sides: d.s, Value: v}
+	d.tag(&r)
+	return r, nil
+}
+
+// RollPool returns the result of rolling the dice expression x including the result of each rolled die.
+// It returns nil and an error, if any.
+func (x *Expr) RollPool() (*PoolResult, error) {
+	// Return an error if x is nil
+	if x == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Roll the dice expression with its own random number generators
+	return x.RollWith(x.d)
+}
+
+// RollWith returns the result of rolling the dice expression x with the random number generators of Die d
+// including the result of each rolled die. If d is seeded, the expression is rolled with the seeded random
+// number generator of d. The number of sides of d is not used. It returns nil and an error, if any.
+func (x *Expr) RollWith(d *Die) (*PoolResult, error) {
+	// Roll the expression and notify the Observer, if any
+	t := time.Now()
+	p, e := x.rollWith(d)
+	evaluated(OpRoll, t, e)
+	// Add the total to the tracking Stats, if any
+	if e == nil && x.st != nil {
+		x.st.Add(p.Total)
+	}
+	return p, e
+}
+
+// rollWith returns the result of rolling the dice expression x with the random number generators of Die d.
+func (x *Expr) rollWith(d *Die) (*PoolResult, error) {
+	// Return an error if x or d is nil
+	if x == nil || d == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Initialize the die if not initialized yet
+	if e := d.notSet(); e != nil {
+		// Return nil and an error if the initialization fails
+		return nil, e
+	}
+	// p holds the result of the dice expression
+	p := &PoolResult{Expr: x.String()}
+	// Iterate all terms
+	for _, t := range x.t {
+		// Add constant modifiers to the modifier and the total
+		if t.s == 0 {
+			p.Modifier += t.sign * t.n
+			p.Total += t.sign * t.n
+			continue
+		}
+		// Roll n dice with s sides and add each result to the rolls and the total
+		for i := 0; i < t.n; i++ {
+			v, e := d.rollSides(t.s, false)
+			// Return nil and an error if rolling the die fails
+			if e != nil {
+				return nil, e
+			}
+			r := RollResult{Sides: t.s, Value: v, Subtract: t.sign < 0}
+			d.tag(&r)
+			p.Rolls = append(p.Rolls, r)
+			p.Total += t.sign * v
+		}
+	}
+	// Return the result
+	return p, nil
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library package math/bits
+import (
+	"math/bits" // bits
+)
+
+// samplerBound defines the lower bound of the range of the uniform state of a sampler. Bits are only drawn from the
+// source, if the range is lower than samplerBound, and numbers of sides up to samplerBound are sampled.
+const (
+	samplerBound uint64 = 1 << 32
+)
+
+// A sampler draws uniformly distributed results from a cryptographically secure source with only the entropy
+// required. It holds a uniformly distributed value v in the range [0, r). To roll a die with n sides, bits are added
+// to v until r is at least samplerBound. If v is lower than the largest multiple of n within r, the result is v mod n
+// and v / n is kept as uniform state for subsequent rolls. Otherwise, the remainder is kept and the roll is repeated.
+// Therefore, on average only slightly more than log2(n) bits are drawn per roll. It is not safe for concurrent use by
+// multiple goroutines.
+type sampler struct {
+	src   *cryptoSource // source of random bits
+	v, r  uint64        // uniform state, v in [0, r)
+	b     uint64        // buffered bits of the source
+	nb    int           // number of buffered bits in b
+	drawn uint64        // number of bits drawn from the buffered bits
+	rolls uint64        // number of rolls
+}
+
+// newSampler returns a pointer to a new sampler drawing bits from src.
+func newSampler(src *cryptoSource) *sampler {
+	return &sampler{src: src, r: 1}
+}
+
+// take returns k bits, which k must not exceed 64.
+func (s *sampler) take(k int) uint64 {
+	// v holds the bits
+	var v uint64
+	for k > 0 {
+		// Retrieve 64 new bits, if all bits are used
+		if s.nb == 0 {
+			s.b, s.nb = s.src.Uint64(), 64
+		}
+		// Take up to k of the buffered bits
+		m := min(k, s.nb)
+		v = v<<m | s.b&(1<<m-1)
+		s.b >>= m
+		s.nb -= m
+		k -= m
+		s.drawn += uint64(m)
+	}
+	return v
+}
+
+// intn returns a uniformly distributed result in [0, n). n must be between 1 and samplerBound.
+func (s *sampler) intn(n uint64) uint64 {
+	s.rolls++
+	for {
+		// Add bits to the uniform state until its range is at least samplerBound
+		if s.r < samplerBound {
+			k := bits.LeadingZeros64(s.r) - bits.LeadingZeros64(samplerBound)
+			s.v, s.r = s.v<<k|s.take(k), s.r<<k
+		}
+		// q holds the number of multiples of n within the range
+		q := s.r / n
+		// Return the result and keep the quotient as uniform state
+		if s.v < q*n {
+			res := s.v % n
+			s.v, s.r = s.v/n, q
+			return res
+		}
+		// Keep the remainder as uniform state and repeat
+		s.v, s.r = s.v-q*n, s.r-q*n
+	}
+}
+
+// Entropy holds the entropy drawn by a die from its cryptographically secure random number generator.
+type Entropy struct {
+	Bits  uint64 // number of random bits drawn
+	Rolls uint64 // number of rolls
+}
+
+// PerRoll returns the average number of random bits drawn per roll. It returns zero, if no die has been rolled.
+func (e Entropy) PerRoll() float64 {
+	if e.Rolls == 0 {
+		return 0
+	}
+	return float64(e.Bits) / float64(e.Rolls)
+}
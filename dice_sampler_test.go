@@ -0,0 +1,63 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages math, strconv and testing as well as tserr
+import (
+	"math"    // math
+	"strconv" // strconv
+	"testing" // testing
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// TestSampler rolls non-seeded dice with the entropy-efficient sampler. The test fails if a face is rolled
+// too often or too rarely or the dice draw noticeably more entropy than log2 of their number of sides.
+func TestSampler(t *testing.T) {
+	// n holds the number of rolls per die
+	n := 60000
+	for _, s := range []int{4, 6, 8, 20} {
+		d, _ := newDie(s)
+		if d.gen(); d.smp == nil {
+			t.Skip("cryptographically secure random number generator not available")
+		}
+		r, e := d.RollN(n)
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "RollN", Fn: "d" + strconv.Itoa(s), Err: e}))
+		}
+		// c holds the number of results per face
+		c := make([]int, s+1)
+		for _, v := range r {
+			c[v]++
+		}
+		// The test fails if a face deviates more than six standard deviations from its expected number of results
+		p := 1 / float64(s)
+		want, dev := float64(n)*p, 6*math.Sqrt(float64(n)*p*(1-p))
+		for f := 1; f <= s; f++ {
+			if math.Abs(float64(c[f])-want) > dev {
+				t.Errorf("d%d: face %d rolled %d times, expected %.0f", s, f, c[f], want)
+			}
+		}
+		// The test fails if the entropy per roll exceeds log2 of the number of sides by more than 1 percent
+		if h, b := d.Entropy().PerRoll(), math.Log2(float64(s)); h > 1.01*b || d.Entropy().Rolls != uint64(n) {
+			t.Errorf("d%d: %v bits drawn per roll in %d rolls, expected %v", s, h, d.Entropy().Rolls, b)
+		}
+	}
+}
+
+// TestEntropy rolls seeded dice and a nil die. The test fails if their entropy is counted.
+func TestEntropy(t *testing.T) {
+	// Rolls of a seeded die are not counted
+	d, _ := NewD6()
+	d.Seed(1)
+	d.RollN(100)
+	if e := d.Entropy(); e.Rolls != 0 || e.PerRoll() != 0 {
+		t.Errorf("entropy of seeded die counted: %+v", e)
+	}
+	// A nil die has no entropy
+	var n *Die
+	if e := n.Entropy(); e.Rolls != 0 {
+		t.Errorf("entropy of nil die counted: %+v", e)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages as well as tserr
+import (
+	"crypto/sha256"   // sha256
+	"encoding/binary" // binary
+	"hash/fnv"        // fnv
+	"math/rand"       // rand
+	"strings"         // strings
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// A SeedTree derives independent seeds for many dice from one master seed. Each node of the tree holds a seed.
+// The seed of a child is derived from the seed of its parent and the name of the child. Therefore, the seed of
+// a path, e.g., player2/attack, only depends on the master seed and the path, regardless of other paths in use.
+// A SeedTree is immutable and safe for concurrent use by multiple goroutines.
+type SeedTree struct {
+	s uint64 // seed of the node
+}
+
+// NewSeedTree returns a pointer to the root of a new SeedTree with master seed m.
+func NewSeedTree(m int64) *SeedTree {
+	return &SeedTree{s: mix(uint64(m))}
+}
+
+// gamma defines the increment of the state of SplitMix64
+const (
+	gamma uint64 = 0x9e3779b97f4a7c15
+)
+
+// mix returns the output of one full SplitMix64 step from state z: the state is advanced by gamma and the
+// advanced state is finalized. Therefore, mix(z) is the next output of SplitMix64 with state z.
+func mix(z uint64) uint64 {
+	z += gamma
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Child returns the descendant of SeedTree t with the given names, one level per name, e.g., Child("player2", "attack").
+// The seed of each child is derived with SplitMix64 from the seed of its parent and the FNV-1a hash of its name.
+// It returns t, if no names are given, and nil, if t is nil.
+func (t *SeedTree) Child(names ...string) *SeedTree {
+	// Return nil if t is nil
+	if t == nil {
+		return nil
+	}
+	// s holds the seed of the current node
+	s := t.s
+	// Derive the seed of each level
+	for _, n := range names {
+		h := fnv.New64a()
+		h.Write([]byte(n))
+		s = mix(s ^ mix(h.Sum64()))
+	}
+	// Return the descendant
+	return &SeedTree{s: s}
+}
+
+// Path returns the descendant of SeedTree t at path p with names separated by slashes, e.g., player2/attack.
+// It equals Child with the names of the path. It returns t, if p is empty, and nil and an error, if t is nil
+// or p contains an empty name.
+func (t *SeedTree) Path(p string) (*SeedTree, error) {
+	// Return an error if t is nil
+	if t == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Return t if p is empty
+	if p == "" {
+		return t, nil
+	}
+	// Split the path and return an error for empty names
+	n := strings.Split(p, "/")
+	for _, v := range n {
+		if v == "" {
+			return nil, tserr.Check(&tserr.CheckArgs{F: "path " + p, Err: tserr.Empty("name")})
+		}
+	}
+	// Return the descendant
+	return t.Child(n...), nil
+}
+
+// Seed returns the seed of SeedTree t, which can be used to seed a Die with Die.Seed. It returns zero, if t is nil.
+func (t *SeedTree) Seed() int64 {
+	if t == nil {
+		return 0
+	}
+	return int64(t.s)
+}
+
+// SeedDie seeds Die d with the seed of the descendant of SeedTree t at path p. It returns an error, if any.
+func (t *SeedTree) SeedDie(d *Die, p string) error {
+	// Retrieve the descendant
+	c, e := t.Path(p)
+	if e != nil {
+		return e
+	}
+	// Seed the die
+	return d.Seed(c.Seed())
+}
+
+// Seed256 seeds Die d with the 256-bit seed s. The die uses the deterministic pseudo-random number generator
+// xoshiro256** with the four little-endian 64-bit words of s as its state. An all-zero seed is replaced by the
+// SplitMix64 expansion of zero. Rolling the seeded die returns a deterministic series of results. The Algorithm
+// of the die is AlgorithmXoshiro256v1. SeedWith with AlgorithmXoshiro256v1 and seed n equals Seed256 with the
+// SplitMix64 expansion of n.
+func (d *Die) Seed256(s [32]byte) error {
+	// Return an error if d is nil
+	if d == nil {
+		return tserr.NilPtr()
+	}
+	// Initialize the die if not initialized yet
+	if e := d.notSet(); e != nil {
+		// Return an error if the initialization fails
+		return e
+	}
+	// Seed the die with the words of s
+	var w [4]uint64
+	for i := range w {
+		w[i] = binary.LittleEndian.Uint64(s[8*i:])
+	}
+	d.seed256(w)
+	// Return nil
+	return nil
+}
+
+// seed256 sets the state of the random number generator with 256 bits of state of Die d to w. It is the only
+// path seeding AlgorithmXoshiro256v1, shared by the 256-bit seeds and SeedWith.
+func (d *Die) seed256(w [4]uint64) {
+	// Create the random number generator with 256 bits of state, if not created yet
+	if d.lrnd == nil {
+		d.lsrc = &xoshiro{}
+		d.lrnd = rand.New(d.lsrc)
+	}
+	// Set the state to w
+	d.lsrc.seed256(w)
+	// Set the currently used random number generator grnd to lrnd and its Algorithm
+	d.grnd, d.alg = d.lrnd, AlgorithmXoshiro256v1
+	// Start the FairMode, if any, in its initial state
+	d.fr.reset()
+}
+
+// Seed128 seeds Die d with the 128-bit seed s. The seed is extended to 256 bits by appending the SplitMix64
+// finalization of its two little-endian 64-bit words. Then, the die is seeded as described for Seed256.
+func (d *Die) Seed128(s [16]byte) error {
+	// Extend the seed to 256 bits
+	var b [32]byte
+	copy(b[:], s[:])
+	binary.LittleEndian.PutUint64(b[16:], mix(binary.LittleEndian.Uint64(s[:])))
+	binary.LittleEndian.PutUint64(b[24:], mix(binary.LittleEndian.Uint64(s[8:])))
+	// Seed the die
+	return d.Seed256(b)
+}
+
+// SeedBytes seeds Die d with the byte slice b of any length. The 256-bit seed is the SHA-256 hash of b. Then, the die
+// is seeded as described for Seed256. The hashing step is stable, so the same bytes always roll the same results.
+func (d *Die) SeedBytes(b []byte) error {
+	return d.Seed256(sha256.Sum256(b))
+}
+
+// SeedString seeds Die d with string s, e.g., the name of a campaign. It is equal to SeedBytes with the UTF-8 bytes of s.
+func (d *Die) SeedString(s string) error {
+	return d.SeedBytes([]byte(s))
+}
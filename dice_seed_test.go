@@ -0,0 +1,185 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages crypto/sha256 and testing as well as tserr
+import (
+	"crypto/sha256" // sha256
+	"testing"       // testing
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// TestSeedTreeGolden derives seeds from master seeds. The test fails if the seeds do not match the golden
+// values, which must not change between versions to keep seeded dice reproducible.
+func TestSeedTreeGolden(t *testing.T) {
+	// The root seed of master seed 42 is the first SplitMix64 output of state 42
+	p, _ := NewSeedTree(42).Path("player2/attack")
+	for _, c := range []struct {
+		n         string
+		got, want int64
+	}{
+		{"root 42", NewSeedTree(42).Seed(), -4767286540954276203},
+		{"42 player2/attack", p.Seed(), 6113826769779214761},
+		{"0 a", NewSeedTree(0).Child("a").Seed(), 7483122976069423010},
+	} {
+		if c.got != c.want {
+			t.Error(tserr.Equal(&tserr.EqualArgs{Var: c.n, Actual: c.got, Want: c.want}))
+		}
+	}
+}
+
+// TestSeedTree derives seeds by path and by name. The test fails if equal paths derive different seeds,
+// different paths derive equal seeds or a die seeded by path is not reproducible.
+func TestSeedTree(t *testing.T) {
+	r := NewSeedTree(7)
+	// Equal paths derive equal seeds
+	p, e := r.Path("player2/attack")
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Path", Fn: "player2/attack", Err: e}))
+	}
+	if c := r.Child("player2").Child("attack"); c.Seed() != p.Seed() {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "seed of player2, attack", Actual: c.Seed(), Want: p.Seed()}))
+	}
+	if q, _ := r.Path(""); q != r {
+		t.Error("empty path does not return the root")
+	}
+	// Different paths and master seeds derive different seeds
+	seeds := make(map[int64]string)
+	for _, n := range []string{"player1", "player2", "player1/attack", "player2/attack", "player2/defense", "attack/player2", "player2attack"} {
+		c, _ := r.Path(n)
+		if o, ok := seeds[c.Seed()]; ok {
+			t.Errorf("paths %v and %v derive equal seeds", o, n)
+		}
+		seeds[c.Seed()] = n
+	}
+	if q, _ := NewSeedTree(8).Path("player2/attack"); q.Seed() == p.Seed() {
+		t.Error("master seeds 7 and 8 derive equal seeds")
+	}
+	// A die seeded by path rolls the same results as a die seeded with the seed of the path
+	a, _ := NewD20()
+	b, _ := NewD20()
+	if e = r.SeedDie(a, "player2/attack"); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "SeedDie", Fn: "player2/attack", Err: e}))
+	}
+	b.Seed(p.Seed())
+	for i := 0; i < 20; i++ {
+		x, _ := a.Roll()
+		y, _ := b.Roll()
+		if x != y {
+			t.Error(tserr.Equal(&tserr.EqualArgs{Var: "seeded roll", Actual: int64(x), Want: int64(y)}))
+		}
+	}
+}
+
+// TestSeedTreeErrors derives invalid paths and calls the methods on a nil SeedTree. The test fails if no error is returned.
+func TestSeedTreeErrors(t *testing.T) {
+	r := NewSeedTree(1)
+	// Paths with empty names return an error
+	for _, p := range []string{"/", "a/", "/a", "a//b"} {
+		if _, e := r.Path(p); e == nil {
+			t.Error(tserr.NilFailed("Path " + p))
+		}
+	}
+	// Methods on a nil SeedTree
+	var n *SeedTree
+	if _, e := n.Path("a"); e == nil {
+		t.Error(tserr.NilFailed("Path"))
+	}
+	if n.Child("a") != nil || n.Seed() != 0 {
+		t.Error("nil SeedTree returns a child or a seed")
+	}
+	if e := r.SeedDie(nil, "a"); e == nil {
+		t.Error(tserr.NilFailed("SeedDie"))
+	}
+}
+
+// TestXoshiro generates values from state 1, 2, 3, 4. The test fails if they do not match the reference
+// implementation of xoshiro256** or an all-zero state only produces zeros.
+func TestXoshiro(t *testing.T) {
+	x := &xoshiro{}
+	x.seed256([4]uint64{1, 2, 3, 4})
+	for _, want := range []uint64{11520, 0, 1509978240, 1215971899390074240} {
+		if got := x.Uint64(); got != want {
+			t.Error(tserr.Equal(&tserr.EqualArgs{Var: "xoshiro256**", Actual: int64(got), Want: int64(want)}))
+		}
+	}
+	x.seed256([4]uint64{})
+	if x.Uint64() == 0 && x.Uint64() == 0 {
+		t.Error("all-zero state produces zeros")
+	}
+}
+
+// TestSeedString seeds dice with strings, bytes and 128-bit and 256-bit seeds. The test fails if the results do not
+// match the golden values, equal seeds roll different results or different seeds roll equal results.
+func TestSeedString(t *testing.T) {
+	// rolls returns 8 rolls of a d20 seeded by f
+	rolls := func(f func(d *Die) error) [8]int {
+		t.Helper()
+		d, _ := NewD20()
+		if e := f(d); e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Seed", Fn: "d20", Err: e}))
+		}
+		var r [8]int
+		for i := range r {
+			r[i], _ = d.Roll()
+		}
+		return r
+	}
+	// The results of a seeded string must not change between versions
+	got := rolls(func(d *Die) error { return d.SeedString("campaign") })
+	if want := [8]int{10, 15, 3, 1, 17, 1, 19, 13}; got != want {
+		t.Errorf("rolls %v of seed campaign do not match golden rolls %v", got, want)
+	}
+	// SeedString equals SeedBytes and Seed256 with the SHA-256 hash
+	if r := rolls(func(d *Die) error { return d.SeedBytes([]byte("campaign")) }); r != got {
+		t.Errorf("rolls %v of SeedBytes do not match %v of SeedString", r, got)
+	}
+	if r := rolls(func(d *Die) error { return d.Seed256(sha256.Sum256([]byte("campaign"))) }); r != got {
+		t.Errorf("rolls %v of Seed256 do not match %v of SeedString", r, got)
+	}
+	// Different seeds roll different results
+	for _, f := range []func(d *Die) error{
+		func(d *Die) error { return d.SeedString("campaign2") },
+		func(d *Die) error { return d.Seed128([16]byte{1}) },
+		func(d *Die) error { return d.Seed(1) },
+	} {
+		if r := rolls(f); r == got {
+			t.Errorf("different seeds roll equal results %v", r)
+		}
+	}
+	// Seed128 differs from Seed256 with the same leading bytes
+	if rolls(func(d *Die) error { return d.Seed128([16]byte{1}) }) == rolls(func(d *Die) error { return d.Seed256([32]byte{1}) }) {
+		t.Error("Seed128 equals Seed256 with the same leading bytes")
+	}
+	// A die seeded with a string is deterministic and NoSeed sets it back
+	d, _ := NewD20()
+	d.SeedString("campaign")
+	if d.source() != SourceDeterministic {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "source", Actual: d.source().String(), Want: SourceDeterministic.String()}))
+	}
+	if d.NoSeed(); d.source() == SourceDeterministic {
+		t.Error("NoSeed does not set back the die")
+	}
+	// An expression seeded with a string rolls the same results for equal seeds
+	x, _ := ParseExpr("4d6")
+	y, _ := ParseExpr("4d6")
+	x.SeedString("campaign")
+	y.SeedBytes([]byte("campaign"))
+	for i := 0; i < 10; i++ {
+		a, _ := x.Roll()
+		b, _ := y.Roll()
+		if a != b {
+			t.Error(tserr.Equal(&tserr.EqualArgs{Var: "seeded roll of 4d6", Actual: int64(a), Want: int64(b)}))
+		}
+	}
+	// Methods return an error for nil pointers
+	var (
+		n  *Die
+		nx *Expr
+	)
+	if n.SeedString("a") == nil || n.SeedBytes(nil) == nil || n.Seed128([16]byte{}) == nil || nx.SeedString("a") == nil || nx.SeedBytes(nil) == nil {
+		t.Error(tserr.NilFailed("seeding a nil pointer"))
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library package sync as well as tserr
+import (
+	"sync" // sync
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// A Shared is a buffered cryptographically secure random number generator with an entropy-efficient sampler, which
+// can be shared by many dice with Die.Share. Dice sharing a Shared do not create a random number generator of their
+// own, which saves the cost of creating generators, e.g., for servers creating many short-lived dice. A Shared is
+// safe for concurrent use by multiple goroutines. Seeded dice use their own deterministic random number generators.
+type Shared struct {
+	mu  sync.Mutex    // mutex for src and smp
+	src *cryptoSource // buffered cryptographically secure source
+	smp *sampler      // entropy-efficient sampler of src
+}
+
+// NewShared returns a pointer to a new Shared. It returns nil and an error, if the cryptographically secure random
+// number generator is not available on the platform.
+func NewShared() (*Shared, error) {
+	// Create the buffered cryptographically secure source
+	_, src, e := newCryptoRand()
+	if e != nil {
+		return nil, e
+	}
+	// Return the Shared
+	return &Shared{src: src, smp: newSampler(src)}, nil
+}
+
+// Uint64 returns a cryptographically secure random 64-bit value.
+func (s *Shared) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+// Int63 returns a cryptographically secure random 63-bit value.
+func (s *Shared) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+// Seed is empty, the Shared cannot be seeded.
+func (s *Shared) Seed(int64) {}
+
+// intn returns a uniformly distributed result in [0, n) from the sampler. n must be between 1 and samplerBound.
+func (s *Shared) intn(n uint64) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.smp.intn(n)
+}
+
+// err returns an error, if reading from the cryptographically secure random number generator failed.
+func (s *Shared) err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.src.err != nil {
+		return tserr.NotAvailable(&tserr.NotAvailableArgs{S: "crypto/rand", Err: s.src.err})
+	}
+	return nil
+}
+
+// Entropy returns the entropy drawn by all dice using Shared s.
+func (s *Shared) Entropy() Entropy {
+	if s == nil {
+		return Entropy{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Entropy{Bits: s.smp.drawn, Rolls: s.smp.rolls}
+}
+
+// Share sets Die d to use Shared s for non-seeded rolls instead of its own random number generator. If d is
+// seeded, it uses s after NoSeed. Share with nil sets d back to its own random number generator, which is
+// created on the next non-seeded roll. It returns an error, if d is nil.
+func (d *Die) Share(s *Shared) error {
+	// Return an error if d is nil
+	if d == nil {
+		return tserr.NilPtr()
+	}
+	// Initialize the die if not initialized yet
+	if e := d.notSet(); e != nil {
+		// Return an error if the initialization fails
+		return e
+	}
+	// Reset the currently used random number generator, if the die is not seeded
+	if d.grnd == d.prnd {
+		d.grnd = nil
+	}
+	// Remove the random number generator of the die, it is created on the next non-seeded roll
+	d.shr, d.prnd, d.csrc, d.smp = s, nil, nil, nil
+	// Return nil
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages sync and testing as well as tserr
+import (
+	"sync"    // sync
+	"testing" // testing
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// TestLazy creates a d6. The test fails if random number generators are created before the die is rolled or seeded.
+func TestLazy(t *testing.T) {
+	d, _ := NewD6()
+	if d.prnd != nil || d.drnd != nil || d.grnd != nil {
+		t.Fatal("random number generators created with the die")
+	}
+	// Seeding creates only the deterministic random number generator
+	d.Seed(1)
+	if d.prnd != nil || d.drnd == nil {
+		t.Error("unexpected random number generators after Seed")
+	}
+	// Rolling after NoSeed creates the non-seeded random number generator
+	d.NoSeed()
+	if _, e := d.Roll(); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Roll", Fn: "d6", Err: e}))
+	}
+	if d.prnd == nil || d.grnd != d.prnd {
+		t.Error("non-seeded random number generator not created on roll")
+	}
+}
+
+// TestShared rolls dice sharing a Shared concurrently. The test fails if a result is out of bounds, the dice
+// create generators of their own or the entropy of the Shared does not count all rolls.
+func TestShared(t *testing.T) {
+	s, e := NewShared()
+	if e != nil {
+		t.Skip("cryptographically secure random number generator not available")
+	}
+	// Roll 8 dice with 1000 rolls each concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		d, _ := NewD20()
+		if e = d.Share(s); e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Share", Fn: "d20", Err: e}))
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if r, e := d.Roll(); e != nil || r < 1 || r > 20 {
+					t.Errorf("roll %d of shared d20 returned %d, %v", j, r, e)
+					return
+				}
+			}
+			if d.csrc != nil || d.smp != nil {
+				t.Error("shared d20 created a generator of its own")
+			}
+			if d.source() != SourceCrypto {
+				t.Error(tserr.Equal(&tserr.EqualArgs{Var: "source", Actual: int64(d.source()), Want: int64(SourceCrypto)}))
+			}
+		}()
+	}
+	wg.Wait()
+	if r := s.Entropy().Rolls; r != 8000 {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "rolls of Shared", Actual: int64(r), Want: 8000}))
+	}
+	// A seeded die sharing s keeps its deterministic series of results
+	a, _ := NewD20()
+	b, _ := NewD20()
+	a.Share(s)
+	a.Seed(5)
+	b.Seed(5)
+	for i := 0; i < 10; i++ {
+		if x, _ := a.Roll(); x != must(b.Roll()) {
+			t.Fatal("seeded die sharing a Shared not deterministic")
+		}
+	}
+	// Share with nil sets the die back to its own random number generator
+	a.NoSeed()
+	a.Share(nil)
+	if _, e = a.Roll(); e != nil || a.smp == nil {
+		t.Errorf("die not set back to own random number generator: %v", e)
+	}
+	var z *Die
+	if e = z.Share(s); e == nil {
+		t.Error(tserr.NilFailed("Share"))
+	}
+}
+
+// must returns the result r and ignores the error.
+func must(r int, _ error) int {
+	return r
+}
+
+// BenchmarkNewD6 creates a d6.
+func BenchmarkNewD6(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewD6()
+	}
+}
+
+// BenchmarkNewD6Roll creates a d6 and rolls it once.
+func BenchmarkNewD6Roll(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d, _ := NewD6()
+		d.Roll()
+	}
+}
+
+// BenchmarkNewD6Shared creates a d6 sharing a Shared and rolls it once.
+func BenchmarkNewD6Shared(b *testing.B) {
+	s, e := NewShared()
+	if e != nil {
+		b.Skip("cryptographically secure random number generator not available")
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d, _ := NewD6()
+		d.Share(s)
+		d.Roll()
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library package math as well as tserr
+import (
+	"math" // math
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// maxFaces defines the maximum number of distinct results, whose occurrences are counted by a Stats
+const (
+	maxFaces int = 10000
+)
+
+// A Stats accumulates statistics of results online with Welford's algorithm. It holds the number of results,
+// the arithmetic mean, the sum of squared differences from the mean, the minimum, the maximum and the number of
+// occurrences of each result. It does not keep the results. The occurrences are counted for at most 10000 distinct
+// results, e.g., the faces of a die or the totals of a dice expression. Further distinct results are included in
+// all other statistics, but their occurrences are not counted. Therefore, its memory does not grow with the number
+// of results. The zero value is an empty Stats ready to use. A Stats is not safe for concurrent use by multiple
+// goroutines. Parallel workers each use their own Stats and combine them with Merge.
+type Stats struct {
+	n        uint64         // number of results
+	mean, m2 float64        // arithmetic mean and sum of squared differences from the mean
+	min, max int            // minimum and maximum result
+	faces    map[int]uint64 // number of occurrences of each result
+}
+
+// Add adds result v to Stats s. It returns an error, if s is nil.
+func (s *Stats) Add(v int) error {
+	// Return an error if s is nil
+	if s == nil {
+		return tserr.NilPtr()
+	}
+	// Update the minimum and the maximum
+	if s.n == 0 || v < s.min {
+		s.min = v
+	}
+	if s.n == 0 || v > s.max {
+		s.max = v
+	}
+	// Update the mean and the sum of squared differences from the mean
+	s.n++
+	d := float64(v) - s.mean
+	s.mean += d / float64(s.n)
+	s.m2 += d * (float64(v) - s.mean)
+	// Count the occurrence of v
+	s.face(v, 1)
+	// Return nil
+	return nil
+}
+
+// face adds c occurrences of result v to Stats s, if v is counted already or less than maxFaces distinct results
+// are counted.
+func (s *Stats) face(v int, c uint64) {
+	if s.faces == nil {
+		s.faces = make(map[int]uint64)
+	}
+	if _, ok := s.faces[v]; ok || len(s.faces) < maxFaces {
+		s.faces[v] += c
+	}
+}
+
+// Merge adds all results accumulated in Stats o to Stats s, e.g., to combine the Stats of parallel workers.
+// The result equals adding the results of o to s one by one up to floating-point rounding. It returns an error,
+// if s is nil.
+func (s *Stats) Merge(o *Stats) error {
+	// Return an error if s is nil
+	if s == nil {
+		return tserr.NilPtr()
+	}
+	// Return if o is nil or empty
+	if o == nil || o.n == 0 {
+		return nil
+	}
+	// Copy o, if s is empty
+	if s.n == 0 {
+		s.n, s.mean, s.m2, s.min, s.max = o.n, o.mean, o.m2, o.min, o.max
+	} else {
+		// Combine the means and the sums of squared differences of both Stats
+		n := s.n + o.n
+		d := o.mean - s.mean
+		s.mean += d * float64(o.n) / float64(n)
+		s.m2 += o.m2 + d*d*float64(s.n)*float64(o.n)/float64(n)
+		s.n = n
+		s.min, s.max = min(s.min, o.min), max(s.max, o.max)
+	}
+	// Add the occurrences of each result
+	for v, c := range o.faces {
+		s.face(v, c)
+	}
+	// Return nil
+	return nil
+}
+
+// Reset removes all results from Stats s. It returns an error, if s is nil.
+func (s *Stats) Reset() error {
+	// Return an error if s is nil
+	if s == nil {
+		return tserr.NilPtr()
+	}
+	// Remove all results
+	*s = Stats{}
+	return nil
+}
+
+// Count returns the number of results. It returns zero, if s is nil.
+func (s *Stats) Count() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.n
+}
+
+// Mean returns the arithmetic mean of the results. It returns zero and an error, if s is nil or empty.
+func (s *Stats) Mean() (float64, error) {
+	if s == nil {
+		return 0, tserr.NilPtr()
+	}
+	if s.n == 0 {
+		return 0, tserr.Empty("stats")
+	}
+	return s.mean, nil
+}
+
+// Variance returns the population variance of the results, as lpstats.Variance. It returns zero and an error, if s is
+// nil or empty.
+func (s *Stats) Variance() (float64, error) {
+	if s == nil {
+		return 0, tserr.NilPtr()
+	}
+	if s.n == 0 {
+		return 0, tserr.Empty("stats")
+	}
+	return s.m2 / float64(s.n), nil
+}
+
+// StdDev returns the population standard deviation of the results. It returns zero and an error, if s is nil or empty.
+func (s *Stats) StdDev() (float64, error) {
+	v, e := s.Variance()
+	return math.Sqrt(v), e
+}
+
+// Min returns the minimum result. It returns zero and an error, if s is nil or empty.
+func (s *Stats) Min() (int, error) {
+	if s == nil {
+		return 0, tserr.NilPtr()
+	}
+	if s.n == 0 {
+		return 0, tserr.Empty("stats")
+	}
+	return s.min, nil
+}
+
+// Max returns the maximum result. It returns zero and an error, if s is nil or empty.
+func (s *Stats) Max() (int, error) {
+	if s == nil {
+		return 0, tserr.NilPtr()
+	}
+	if s.n == 0 {
+		return 0, tserr.Empty("stats")
+	}
+	return s.max, nil
+}
+
+// Face returns the number of occurrences of result v. It returns zero, if s is nil or the occurrences of v are not
+// counted, since the first 10000 distinct results have been counted before v occurred.
+func (s *Stats) Face(v int) uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.faces[v]
+}
+
+// Faces returns a copy of the number of occurrences of each counted result, which are at most 10000 distinct results.
+// It returns nil, if s is nil.
+func (s *Stats) Faces() map[int]uint64 {
+	if s == nil {
+		return nil
+	}
+	f := make(map[int]uint64, len(s.faces))
+	for v, c := range s.faces {
+		f[v] = c
+	}
+	return f
+}
+
+// Track sets Die d to add each of its results rolled with Roll, RollInto or RollN to Stats s. Track with nil
+// stops tracking. Results of dice expressions rolled with d are not added. It returns an error, if d is nil.
+func (d *Die) Track(s *Stats) error {
+	// Return an error if d is nil
+	if d == nil {
+		return tserr.NilPtr()
+	}
+	// Set the Stats
+	d.st = s
+	// Return nil
+	return nil
+}
+
+// Track sets the dice expression x to add the total of each of its rolls to Stats s. Track with nil stops
+// tracking. It returns an error, if x is nil.
+func (x *Expr) Track(s *Stats) error {
+	// Return an error if x is nil
+	if x == nil {
+		return tserr.NilPtr()
+	}
+	// Set the Stats
+	x.st = s
+	// Return nil
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library package testing as well as lpstats and tserr
+import (
+	"testing" // testing
+
+	"github.com/thorstenrie/lpstats" // lpstats
+	"github.com/thorstenrie/tserr"   // tserr
+)
+
+// TestStats tracks the rolls of a seeded d20 and the totals of a seeded dice expression. The test fails if
+// the Stats differ from lpstats for the same results.
+func TestStats(t *testing.T) {
+	// Track the rolls of a seeded d20
+	d, _ := NewD20()
+	d.Seed(7)
+	var s Stats
+	if e := d.Track(&s); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Track", Fn: "d20", Err: e}))
+	}
+	r, _ := d.RollN(5000)
+	v, _ := d.Roll()
+	r = append(r, v)
+	testStats(t, &s, r)
+	// Track the totals of a seeded dice expression
+	x, _ := ParseExpr("3d6-2")
+	x.Seed(7)
+	var p Stats
+	x.Track(&p)
+	y := make([]int, 1000)
+	for i := range y {
+		y[i], _ = x.Roll()
+	}
+	testStats(t, &p, y)
+	// Stopping tracking does not add results
+	d.Track(nil)
+	d.Roll()
+	if s.Count() != uint64(len(r)) {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "count", Actual: int64(s.Count()), Want: int64(len(r))}))
+	}
+	// Reset removes all results
+	s.Reset()
+	if _, e := s.Mean(); e == nil || s.Count() != 0 || s.Face(1) != 0 {
+		t.Error(tserr.NilFailed("Mean"))
+	}
+	var z *Die
+	if e := z.Track(&s); e == nil {
+		t.Error(tserr.NilFailed("Track"))
+	}
+}
+
+// TestStatsMerge splits results in parts accumulated by separate Stats. The test fails if the merged Stats
+// differ from lpstats for all results.
+func TestStatsMerge(t *testing.T) {
+	d, _ := NewD12()
+	d.Seed(11)
+	r, _ := d.RollN(3000)
+	// Accumulate three parts of different lengths and an empty part
+	var a, b, c, z Stats
+	for _, v := range r[:100] {
+		a.Add(v)
+	}
+	for _, v := range r[100:2000] {
+		b.Add(v)
+	}
+	for _, v := range r[2000:] {
+		c.Add(v)
+	}
+	// Merge the parts into an empty Stats
+	z.Merge(&a)
+	z.Merge(nil)
+	z.Merge(&Stats{})
+	z.Merge(&b)
+	z.Merge(&c)
+	testStats(t, &z, r)
+}
+
+// TestStatsBounds adds more distinct results than counted and calls the methods of a nil Stats. The test fails if
+// more than maxFaces results are counted, the other statistics miss a result or a nil Stats does not return an error.
+func TestStatsBounds(t *testing.T) {
+	var s, m Stats
+	for v := 0; v <= maxFaces; v++ {
+		s.Add(v)
+	}
+	m.Merge(&s)
+	m.Add(-1)
+	for _, x := range []*Stats{&s, &m} {
+		if n := len(x.Faces()); n != maxFaces {
+			t.Error(tserr.Equal(&tserr.EqualArgs{Var: "number of faces", Actual: int64(n), Want: int64(maxFaces)}))
+		}
+		if h, _ := x.Max(); x.Face(maxFaces) != 0 || h != maxFaces {
+			t.Errorf("result %d counted %d times, maximum %d", maxFaces, x.Face(maxFaces), h)
+		}
+	}
+	if l, _ := m.Min(); l != -1 || m.Count() != uint64(maxFaces+2) || m.Face(0) != 1 {
+		t.Errorf("merged stats of %d results with minimum %d", m.Count(), l)
+	}
+	// A nil Stats returns an error
+	var z *Stats
+	if z.Add(1) == nil || z.Merge(&s) == nil || z.Reset() == nil || z.Count() != 0 || z.Face(1) != 0 || z.Faces() != nil {
+		t.Error(tserr.NilFailed("Stats"))
+	}
+	for n, f := range map[string]func() (float64, error){"Mean": z.Mean, "Variance": z.Variance, "StdDev": z.StdDev} {
+		if _, e := f(); e == nil {
+			t.Error(tserr.NilFailed(n))
+		}
+	}
+	if _, e := z.Min(); e == nil {
+		t.Error(tserr.NilFailed("Min"))
+	}
+	if _, e := z.Max(); e == nil {
+		t.Error(tserr.NilFailed("Max"))
+	}
+}
+
+// testStats compares Stats s with the statistics of results r calculated by lpstats. The test fails if
+// they differ.
+func testStats(t *testing.T, s *Stats, r []int) {
+	// The test fails if the count differs
+	if s.Count() != uint64(len(r)) {
+		t.Fatal(tserr.Equal(&tserr.EqualArgs{Var: "count", Actual: int64(s.Count()), Want: int64(len(r))}))
+	}
+	// The test fails if the mean or the variance differ
+	mw, _ := lpstats.ArithmeticMean(r)
+	vw, _ := lpstats.Variance(r)
+	m, e := s.Mean()
+	if e != nil || !lpstats.NearEqual(m, mw, 1e-9) {
+		t.Error(tserr.Equalf(&tserr.EqualfArgs{Var: "mean", Actual: m, Want: mw}))
+	}
+	v, e := s.Variance()
+	if e != nil || !lpstats.NearEqual(v, vw, 1e-9) {
+		t.Error(tserr.Equalf(&tserr.EqualfArgs{Var: "variance", Actual: v, Want: vw}))
+	}
+	// c holds the number of occurrences of each result, lo the minimum and hi the maximum
+	c := make(map[int]uint64)
+	lo, hi := r[0], r[0]
+	for _, x := range r {
+		c[x]++
+		lo, hi = min(lo, x), max(hi, x)
+	}
+	// The test fails if the minimum, the maximum or the occurrences differ
+	if l, _ := s.Min(); l != lo {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "min", Actual: int64(l), Want: int64(lo)}))
+	}
+	if h, _ := s.Max(); h != hi {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "max", Actual: int64(h), Want: int64(hi)}))
+	}
+	f := s.Faces()
+	if len(f) != len(c) {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "number of faces", Actual: int64(len(f)), Want: int64(len(c))}))
+	}
+	for x, n := range c {
+		if f[x] != n || s.Face(x) != n {
+			t.Errorf("result %d occurred %d times, counted %d", x, n, f[x])
+		}
+	}
+}
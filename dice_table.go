@@ -0,0 +1,348 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages as well as tserr and tsfio
+import (
+	"bytes"         // bytes
+	"encoding/csv"  // csv
+	"encoding/json" // json
+	"errors"        // errors
+	"io"            // io
+	"path/filepath" // filepath
+	"strconv"       // strconv
+	"strings"       // strings
+
+	"github.com/thorstenrie/tserr" // tserr
+	"github.com/thorstenrie/tsfio" // tsfio
+)
+
+// csvHeader defines the header of roll tables in CSV. Each row holds one entry of a table.
+var (
+	csvHeader = []string{"table", "dice", "range", "result", "next"}
+)
+
+// A TableEntry is an entry of a RollTable. Range holds the results of the dice selecting the entry, either a single
+// result, e.g., 7, or an inclusive range, e.g., 01-15. Result holds the text of the entry. If Next is not empty, the
+// table with name Next is rolled on after the entry is selected.
+type TableEntry struct {
+	Range  string `json:"range"`          // range of results selecting the entry
+	Result string `json:"result"`         // text of the entry
+	Next   string `json:"next,omitempty"` // name of the nested table rolled on next, if any
+}
+
+// A RollTable is a table with ranged results, e.g., d100: 01-15 goblins, 16-40 wolves. Dice holds the dice
+// expression rolled on the table, e.g., d100 or 2d6. The ranges of the entries must cover all results of the dice
+// expression exactly, without gaps or overlaps.
+type RollTable struct {
+	Name    string       `json:"name"`    // name of the table
+	Dice    string       `json:"dice"`    // dice expression rolled on the table
+	Entries []TableEntry `json:"entries"` // entries of the table
+}
+
+// A TableRoll holds the result of rolling on a single table. It contains the name of the table, the rolled
+// result and the text of the selected entry.
+type TableRoll struct {
+	Table  string `json:"table"`  // name of the table
+	Roll   int    `json:"roll"`   // rolled result
+	Result string `json:"result"` // text of the selected entry
+}
+
+// A table is a validated RollTable. It holds the parsed dice expression and the index of the selected entry
+// for each result.
+type table struct {
+	t   RollTable // roll table
+	x   *Expr     // dice expression rolled on the table
+	lo  int       // lowest result of the dice expression
+	idx []int     // index of the selected entry for each result from lo
+}
+
+// Tables holds a set of validated roll tables. Nested tables are rolled on after the entry referencing them.
+// All referenced tables exist and tables do not reference each other in a cycle. Tables is not safe for
+// concurrent use by multiple goroutines.
+type Tables struct {
+	t     map[string]*table // tables by name
+	names []string          // names of the tables in order
+}
+
+// NewTables returns a pointer to Tables holding the roll tables t. It returns nil and an error, if a name is empty or
+// a duplicate, a dice expression is invalid, the ranges of a table do not cover the results of its dice exactly, a
+// nested table does not exist or tables reference each other in a cycle.
+func NewTables(t ...RollTable) (*Tables, error) {
+	// ts holds the tables
+	ts := &Tables{t: make(map[string]*table, len(t))}
+	// Validate each table
+	for _, r := range t {
+		if r.Name == "" {
+			return nil, tserr.Empty("name of table")
+		}
+		if _, ok := ts.t[r.Name]; ok {
+			return nil, tserr.Duplicate("table " + r.Name)
+		}
+		v, e := newTable(r)
+		if e != nil {
+			return nil, e
+		}
+		ts.t[r.Name], ts.names = v, append(ts.names, r.Name)
+	}
+	// Return an error if a nested table does not exist
+	for _, n := range ts.names {
+		for _, en := range ts.t[n].t.Entries {
+			if _, ok := ts.t[en.Next]; en.Next != "" && !ok {
+				return nil, tserr.NotExistent("table " + en.Next + " nested in table " + n)
+			}
+		}
+	}
+	// Return an error if tables reference each other in a cycle
+	if e := ts.acyclic(); e != nil {
+		return nil, e
+	}
+	// Return the tables
+	return ts, nil
+}
+
+// newTable returns a pointer to the validated table of RollTable r. It returns nil and an error, if the dice
+// expression is invalid or the ranges of the entries do not cover the results of the dice expression exactly.
+func newTable(r RollTable) (*table, error) {
+	// Parse the dice expression
+	x, e := ParseExpr(r.Dice)
+	if e != nil {
+		return nil, tserr.Check(&tserr.CheckArgs{F: "dice of table " + r.Name, Err: e})
+	}
+	// Return an error if the range of results exceeds maxRange
+	lo, hi := x.bounds()
+	if hi-lo > maxRange {
+		return nil, tserr.Lower(&tserr.LowerArgs{Var: "range of results of table " + r.Name, Actual: int64(hi - lo), HigherBound: int64(maxRange + 1)})
+	}
+	// t holds the table with the index of each result initialized to no entry
+	t := &table{t: r, x: x, lo: lo, idx: make([]int, hi-lo+1)}
+	for i := range t.idx {
+		t.idx[i] = -1
+	}
+	// Index the results of each entry
+	for i, en := range r.Entries {
+		a, b, e := parseRange(en.Range)
+		if e != nil {
+			return nil, tserr.Check(&tserr.CheckArgs{F: "range " + en.Range + " of table " + r.Name, Err: e})
+		}
+		// Return an error if the range exceeds the results of the dice expression
+		if a < lo {
+			return nil, tserr.Higher(&tserr.HigherArgs{Var: "range " + en.Range + " of table " + r.Name, Actual: int64(a), LowerBound: int64(lo)})
+		}
+		if b > hi {
+			return nil, tserr.Lower(&tserr.LowerArgs{Var: "range " + en.Range + " of table " + r.Name, Actual: int64(b), HigherBound: int64(hi + 1)})
+		}
+		// Return an error if the range overlaps a previous entry
+		for v := a; v <= b; v++ {
+			if t.idx[v-lo] >= 0 {
+				return nil, tserr.Duplicate("result " + strconv.Itoa(v) + " of table " + r.Name)
+			}
+			t.idx[v-lo] = i
+		}
+	}
+	// Return an error if a result is not covered by an entry
+	for i, j := range t.idx {
+		if j < 0 {
+			return nil, tserr.NotSet("result " + strconv.Itoa(lo+i) + " of table " + r.Name)
+		}
+	}
+	// Return the table
+	return t, nil
+}
+
+// parseRange returns the lowest and highest result of range r, either a single result, e.g., 7, or an inclusive
+// range, e.g., 01-15. It returns an error, if r cannot be parsed or its lowest result exceeds its highest.
+func parseRange(r string) (int, int, error) {
+	// Split the range at the separator, a leading minus sign belongs to the lowest result
+	a, b, ok := strings.Cut(r[min(1, len(r)):], "-")
+	if ok {
+		a = r[:min(1, len(r))] + a
+	} else {
+		a, b = r, r
+	}
+	// Parse the lowest and the highest result
+	lo, e := strconv.Atoi(strings.TrimSpace(a))
+	if e != nil {
+		return 0, 0, tserr.Op(&tserr.OpArgs{Op: "Atoi", Fn: a, Err: e})
+	}
+	hi, e := strconv.Atoi(strings.TrimSpace(b))
+	if e != nil {
+		return 0, 0, tserr.Op(&tserr.OpArgs{Op: "Atoi", Fn: b, Err: e})
+	}
+	// Return an error if the lowest result exceeds the highest result
+	if lo > hi {
+		return 0, 0, tserr.Lower(&tserr.LowerArgs{Var: "lowest result", Actual: int64(lo), HigherBound: int64(hi + 1)})
+	}
+	// Return the range
+	return lo, hi, nil
+}
+
+// acyclic returns an error, if tables reference each other in a cycle. It searches the nested tables depth-first
+// and fails, if a table is reached again while its nested tables are searched.
+func (ts *Tables) acyclic() error {
+	// state holds the search state of each table, 1 while its nested tables are searched and 2 when done
+	state := make(map[string]int, len(ts.names))
+	// path holds the names of the tables currently searched
+	var path []string
+	// visit searches the nested tables of table n
+	var visit func(n string) error
+	visit = func(n string) error {
+		switch state[n] {
+		case 1:
+			// Return an error with the cycle starting at n
+			i := len(path) - 1
+			for path[i] != n {
+				i--
+			}
+			return tserr.Check(&tserr.CheckArgs{F: "table " + n, Err: errors.New("cycle " + strings.Join(append(path[i:], n), " -> "))})
+		case 2:
+			return nil
+		}
+		state[n], path = 1, append(path, n)
+		for _, en := range ts.t[n].t.Entries {
+			if en.Next != "" {
+				if e := visit(en.Next); e != nil {
+					return e
+				}
+			}
+		}
+		state[n], path = 2, path[:len(path)-1]
+		return nil
+	}
+	// Search all tables
+	for _, n := range ts.names {
+		if e := visit(n); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// ReadTablesJSON reads roll tables from r as JSON array of RollTable, e.g.,
+// [{"name":"encounter","dice":"d100","entries":[{"range":"01-15","result":"goblins"}, ...]}].
+// It returns a pointer to the validated Tables. It returns nil and an error, if any.
+func ReadTablesJSON(r io.Reader) (*Tables, error) {
+	// Return an error if r is nil
+	if r == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Decode the roll tables
+	var t []RollTable
+	if e := json.NewDecoder(r).Decode(&t); e != nil {
+		return nil, tserr.Op(&tserr.OpArgs{Op: "decode", Fn: "JSON tables", Err: e})
+	}
+	// Return the validated tables
+	return NewTables(t...)
+}
+
+// ReadTablesCSV reads roll tables from r as CSV with header table,dice,range,result,next. Each row holds one entry
+// of the table named in the first column. The dice expression must be provided with the first entry of a table
+// and may be omitted for further entries. It returns a pointer to the validated Tables. It returns nil and an error, if any.
+func ReadTablesCSV(r io.Reader) (*Tables, error) {
+	// Return an error if r is nil
+	if r == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Read all records
+	c := csv.NewReader(r)
+	c.FieldsPerRecord = len(csvHeader)
+	rec, e := c.ReadAll()
+	if e != nil {
+		return nil, tserr.Op(&tserr.OpArgs{Op: "read", Fn: "CSV tables", Err: e})
+	}
+	// Return an error if the header does not match
+	if len(rec) == 0 || strings.Join(rec[0], ",") != strings.Join(csvHeader, ",") {
+		return nil, tserr.NotExistent("CSV header " + strings.Join(csvHeader, ","))
+	}
+	// t holds the roll tables and i the index of each table by name
+	var t []RollTable
+	i := make(map[string]int)
+	// Add each entry to its table
+	for _, l := range rec[1:] {
+		j, ok := i[l[0]]
+		if !ok {
+			j, i[l[0]], t = len(t), len(t), append(t, RollTable{Name: l[0], Dice: l[1]})
+		}
+		// Return an error if the dice expression differs from the first entry
+		if l[1] != "" && l[1] != t[j].Dice {
+			return nil, tserr.EqualStr(&tserr.EqualStrArgs{Var: "dice of table " + l[0], Actual: l[1], Want: t[j].Dice})
+		}
+		t[j].Entries = append(t[j].Entries, TableEntry{Range: l[2], Result: l[3], Next: l[4]})
+	}
+	// Return the validated tables
+	return NewTables(t...)
+}
+
+// LoadTables reads roll tables from file fn. The file holds JSON as described for ReadTablesJSON, if its extension
+// is .json, and CSV as described for ReadTablesCSV otherwise. It returns a pointer to the validated Tables.
+// It returns nil and an error, if any.
+func LoadTables(fn tsfio.Filename) (*Tables, error) {
+	// Read the file
+	b, e := tsfio.ReadFile(fn)
+	if e != nil {
+		return nil, e
+	}
+	// Read the roll tables in the format of the file extension
+	if strings.EqualFold(filepath.Ext(string(fn)), ".json") {
+		return ReadTablesJSON(bytes.NewReader(b))
+	}
+	return ReadTablesCSV(bytes.NewReader(b))
+}
+
+// Names returns the names of the tables in the order they were provided.
+func (ts *Tables) Names() []string {
+	if ts == nil {
+		return nil
+	}
+	return append([]string(nil), ts.names...)
+}
+
+// Roll rolls on the table with name n with its dice expression and on each nested table of the selected entries.
+// It returns the result of each rolled table in order. It returns nil and an error, if any.
+func (ts *Tables) Roll(n string) ([]TableRoll, error) {
+	return ts.roll(n, nil)
+}
+
+// RollWith rolls on the table with name n and its nested tables as Roll, but with the random number generators of
+// Die d. If d is seeded, the tables are rolled with the seeded random number generator of d. It returns nil and an
+// error, if any.
+func (ts *Tables) RollWith(n string, d *Die) ([]TableRoll, error) {
+	// Return an error if d is nil
+	if d == nil {
+		return nil, tserr.NilPtr()
+	}
+	return ts.roll(n, d)
+}
+
+// roll rolls on the table with name n and its nested tables with Die d or with the dice expressions of the tables, if d is nil.
+func (ts *Tables) roll(n string, d *Die) ([]TableRoll, error) {
+	// Return an error if ts is nil
+	if ts == nil {
+		return nil, tserr.NilPtr()
+	}
+	// r holds the results of the rolled tables
+	var r []TableRoll
+	// Roll on the tables until the selected entry has no nested table, which terminates since the tables are acyclic
+	for n != "" {
+		t, ok := ts.t[n]
+		if !ok {
+			return nil, tserr.NotExistent("table " + n)
+		}
+		// Roll the dice expression of the table with d or its own random number generators, if d is nil
+		g := d
+		if g == nil {
+			g = t.x.d
+		}
+		p, e := t.x.RollWith(g)
+		if e != nil {
+			return nil, e
+		}
+		v := p.Total
+		// Select the entry of the result and continue with its nested table, if any
+		en := t.t.Entries[t.idx[v-t.lo]]
+		r, n = append(r, TableRoll{Table: t.t.Name, Roll: v, Result: en.Result}), en.Next
+	}
+	// Return the results
+	return r, nil
+}
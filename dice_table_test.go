@@ -0,0 +1,161 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library packages as well as tserr and tsfio
+import (
+	"os"            // os
+	"path/filepath" // filepath
+	"strings"       // strings
+	"testing"       // testing
+
+	"github.com/thorstenrie/tserr" // tserr
+	"github.com/thorstenrie/tsfio" // tsfio
+)
+
+// testTablesCSV holds an encounter table on a d100 with a nested wolves table on 2d6
+const (
+	testTablesCSV string = `table,dice,range,result,next
+encounter,d100,01-15,goblins,
+encounter,,16-40,wolves,wolves
+encounter,,41-100,nothing,
+wolves,2d6,2-6,one wolf,
+wolves,,7-11,a pack of wolves,
+wolves,,12,the alpha,
+`
+	testTablesJSON string = `[
+{"name":"encounter","dice":"d100","entries":[{"range":"01-15","result":"goblins"},{"range":"16-40","result":"wolves","next":"wolves"},{"range":"41-100","result":"nothing"}]},
+{"name":"wolves","dice":"2d6","entries":[{"range":"2-6","result":"one wolf"},{"range":"7-11","result":"a pack of wolves"},{"range":"12","result":"the alpha"}]}
+]`
+)
+
+// TestTables reads the same tables from CSV, JSON and a file and rolls on them with equally seeded dice. The test
+// fails if the tables cannot be read, the results differ or a result does not match the ranges of its table.
+func TestTables(t *testing.T) {
+	c, e := ReadTablesCSV(strings.NewReader(testTablesCSV))
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "ReadTablesCSV", Fn: "tables", Err: e}))
+	}
+	j, e := ReadTablesJSON(strings.NewReader(testTablesJSON))
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "ReadTablesJSON", Fn: "tables", Err: e}))
+	}
+	fn := filepath.Join(t.TempDir(), "tables.json")
+	if e = os.WriteFile(fn, []byte(testTablesJSON), 0o600); e != nil {
+		t.Fatal(e)
+	}
+	f, e := LoadTables(tsfio.Filename(fn))
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "LoadTables", Fn: fn, Err: e}))
+	}
+	if n := strings.Join(c.Names(), ","); n != "encounter,wolves" {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "names", Actual: n, Want: "encounter,wolves"}))
+	}
+	// Roll on the tables with equally seeded dice
+	a, _ := NewD6()
+	b, _ := NewD6()
+	d, _ := NewD6()
+	a.Seed(9)
+	b.Seed(9)
+	d.Seed(9)
+	// nested counts the rolls reaching the nested table
+	nested := 0
+	for i := 0; i < 200; i++ {
+		r, e := c.RollWith("encounter", a)
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "RollWith", Fn: "encounter", Err: e}))
+		}
+		s, _ := j.RollWith("encounter", b)
+		u, _ := f.RollWith("encounter", d)
+		if len(r) != len(s) || len(r) != len(u) || r[0] != s[0] || r[0] != u[0] {
+			t.Fatalf("results of equal tables differ: %v, %v, %v", r, s, u)
+		}
+		// The test fails if a result does not match the ranges of its table
+		v := r[0].Roll
+		want := "nothing"
+		switch {
+		case v <= 15:
+			want = "goblins"
+		case v <= 40:
+			want = "wolves"
+		}
+		if r[0].Table != "encounter" || r[0].Result != want {
+			t.Errorf("roll %d on encounter returned %q, expected %q", v, r[0].Result, want)
+		}
+		if want != "wolves" {
+			if len(r) != 1 {
+				t.Errorf("nested table rolled after %q", want)
+			}
+			continue
+		}
+		// The nested table is rolled after wolves
+		nested++
+		if len(r) != 2 || r[1].Table != "wolves" || r[1].Roll < 2 || r[1].Roll > 12 {
+			t.Errorf("nested table not rolled correctly: %v", r)
+		}
+	}
+	if nested == 0 {
+		t.Error("nested table not rolled in 200 rolls")
+	}
+	// Rolling with the own dice of the tables and a missing table
+	if r, e := c.Roll("wolves"); e != nil || len(r) != 1 {
+		t.Error(tserr.Op(&tserr.OpArgs{Op: "Roll", Fn: "wolves", Err: e}))
+	}
+	if _, e = c.Roll("dragons"); e == nil {
+		t.Error(tserr.NilFailed("Roll"))
+	}
+	var z *Tables
+	if _, e = z.Roll("wolves"); e == nil {
+		t.Error(tserr.NilFailed("Roll"))
+	}
+	if _, e = c.RollWith("wolves", nil); e == nil {
+		t.Error(tserr.NilFailed("RollWith"))
+	}
+}
+
+// TestTablesInvalid creates invalid tables. The test fails if an invalid table does not return an error.
+func TestTablesInvalid(t *testing.T) {
+	// d4 returns a d4 table with entries in ranges r, each nested in table n, if not empty
+	d4 := func(name string, n string, r ...string) RollTable {
+		t := RollTable{Name: name, Dice: "d4"}
+		for _, v := range r {
+			t.Entries = append(t.Entries, TableEntry{Range: v, Result: v, Next: n})
+		}
+		return t
+	}
+	for _, c := range []struct {
+		name string
+		t    []RollTable
+	}{
+		{"gap", []RollTable{d4("a", "", "1", "3-4")}},
+		{"overlap", []RollTable{d4("a", "", "1-2", "2-4")}},
+		{"below", []RollTable{d4("a", "", "0-4")}},
+		{"above", []RollTable{d4("a", "", "1-5")}},
+		{"reversed", []RollTable{d4("a", "", "4-1")}},
+		{"not a number", []RollTable{d4("a", "", "1-x")}},
+		{"dice", []RollTable{{Name: "a", Dice: "d1"}}},
+		{"empty name", []RollTable{d4("", "", "1-4")}},
+		{"duplicate", []RollTable{d4("a", "", "1-4"), d4("a", "", "1-4")}},
+		{"missing", []RollTable{d4("a", "b", "1-4")}},
+		{"self", []RollTable{d4("a", "a", "1-4")}},
+		{"cycle", []RollTable{d4("a", "b", "1-4"), d4("b", "c", "1-4"), d4("c", "a", "1-4")}},
+	} {
+		if _, e := NewTables(c.t...); e == nil {
+			t.Error(tserr.NilFailed("NewTables " + c.name))
+		}
+	}
+	// Negative ranges of subtracted dice are valid
+	if _, e := NewTables(RollTable{Name: "a", Dice: "d4-5", Entries: []TableEntry{{Range: "-4--2"}, {Range: "-1"}}}); e != nil {
+		t.Error(tserr.Op(&tserr.OpArgs{Op: "NewTables", Fn: "negative ranges", Err: e}))
+	}
+	// Invalid CSV headers and inconsistent dice return an error
+	for _, s := range []string{"", "a,b,c,d,e\n", "table,dice,range,result,next\na,d4,1-4,x,\na,d6,5-6,y,\n"} {
+		if _, e := ReadTablesCSV(strings.NewReader(s)); e == nil {
+			t.Error(tserr.NilFailed("ReadTablesCSV"))
+		}
+	}
+	if _, e := ReadTablesJSON(strings.NewReader("{")); e == nil {
+		t.Error(tserr.NilFailed("ReadTablesJSON"))
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package lpdice
+
+// Import standard library package math/bits
+import (
+	"math/bits" // bits
+)
+
+// xoshiro is the deterministic pseudo-random number generator xoshiro256** with 256 bits of state. It is used for
+// seeds larger than 64 bits, so that distinct seeds chosen by users do not collide. It is not safe for concurrent
+// use by multiple goroutines.
+type xoshiro struct {
+	s [4]uint64 // state, must not be all zero
+}
+
+// seed256 sets the state of x to the four 64-bit words of s. An all-zero state is replaced by the SplitMix64
+// expansion of zero, because xoshiro256** only produces zeros from an all-zero state.
+func (x *xoshiro) seed256(s [4]uint64) {
+	if s == [4]uint64{} {
+		x.Seed(0)
+		return
+	}
+	x.s = s
+}
+
+// Seed sets the state of x to the SplitMix64 expansion of s.
+func (x *xoshiro) Seed(s int64) {
+	x.s = expand(s)
+}
+
+// expand returns the first four outputs of SplitMix64 with state s. Each output is a full SplitMix64 step
+// returned by mix, after which the state is advanced by gamma to the state of the step.
+func expand(s int64) [4]uint64 {
+	// w holds the outputs and z holds the state of SplitMix64
+	var w [4]uint64
+	z := uint64(s)
+	for i := range w {
+		w[i] = mix(z)
+		z += gamma
+	}
+	return w
+}
+
+// Uint64 returns the next 64-bit value of x.
+func (x *xoshiro) Uint64() uint64 {
+	r := bits.RotateLeft64(x.s[1]*5, 7) * 9
+	t := x.s[1] << 17
+	x.s[2] ^= x.s[0]
+	x.s[3] ^= x.s[1]
+	x.s[1] ^= x.s[2]
+	x.s[0] ^= x.s[3]
+	x.s[2] ^= t
+	x.s[3] = bits.RotateLeft64(x.s[3], 45)
+	return r
+}
+
+// Int63 returns the upper 63 bits of the next 64-bit value of x.
+func (x *xoshiro) Int63() int64 {
+	return int64(x.Uint64() >> 1)
+}
@@ -0,0 +1,217 @@
+// Package limit provides token-bucket rate limits per client, e.g., per IP address or API key. A Limiter holds
+// a token bucket for each client key. Each bucket holds up to burst tokens and is refilled with rate tokens per
+// second. A request takes one or more tokens and is rejected, if the bucket does not hold enough tokens. The
+// Middleware applies a Limiter to an http.Handler and rejects requests with 429 Too Many Requests and a
+// Retry-After header.
+//
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package limit
+
+// Import standard library packages as well as tserr
+import (
+	"container/list" // list
+	"encoding/json"  // json
+	"math"           // math
+	"net"            // net
+	"net/http"       // http
+	"strconv"        // strconv
+	"sync"           // sync
+	"time"           // time
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// maxClients defines the maximum number of buckets. The buckets of the least recently seen clients are removed
+// beyond it.
+const (
+	maxClients int = 10000
+)
+
+// A bucket holds the tokens of a client, the time of its last refill and its element in the list of recently
+// seen clients
+type bucket struct {
+	tokens float64       // available tokens
+	last   time.Time     // time of the last refill
+	key    string        // client key
+	e      *list.Element // element in the list of recently seen clients
+}
+
+// A Limiter holds a token bucket for each client key. It is safe for concurrent use by multiple goroutines.
+type Limiter struct {
+	rate    float64            // tokens added per second
+	burst   float64            // maximum tokens of a bucket
+	mu      sync.Mutex         // mutex to protect buckets and lru
+	buckets map[string]*bucket // buckets by client key
+	lru     *list.List         // buckets ordered from the most to the least recently seen client
+	now     func() time.Time   // clock, replaceable in tests
+}
+
+// NewLimiter returns a pointer to a new Limiter refilling rate tokens per second up to burst tokens per
+// client. It returns nil and an error, if rate is not positive or burst is lower than one.
+func NewLimiter(rate float64, burst int) (*Limiter, error) {
+	// Return an error if rate is not positive
+	if !(rate > 0) || math.IsInf(rate, 1) {
+		return nil, tserr.Forbidden("rate " + strconv.FormatFloat(rate, 'g', -1, 64))
+	}
+	// Return an error if burst is lower than one
+	if burst < 1 {
+		return nil, tserr.Higher(&tserr.HigherArgs{Var: "burst", Actual: int64(burst), LowerBound: 1})
+	}
+	// Return the new Limiter
+	return &Limiter{rate: rate, burst: float64(burst), buckets: make(map[string]*bucket), lru: list.New(), now: time.Now}, nil
+}
+
+// Allow takes one token of client key. It returns true, if the token is available. Otherwise,
+// it returns false and the duration after which the token will be available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	return l.AllowN(key, 1)
+}
+
+// AllowN takes n tokens of client key. It returns true, if the tokens are available. Otherwise, it returns
+// false and the duration after which the tokens will be available. Requests of more than burst tokens are
+// never allowed and return false and zero.
+func (l *Limiter) AllowN(key string, n int) (bool, time.Duration) {
+	// Return false if l is nil or n exceeds burst
+	if l == nil || float64(n) > l.burst {
+		return false, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	// Retrieve the bucket of the client and mark it as most recently seen or create it
+	b, ok := l.buckets[key]
+	if ok {
+		l.lru.MoveToFront(b.e)
+	} else {
+		l.evict()
+		b = &bucket{tokens: l.burst, last: now, key: key}
+		b.e = l.lru.PushFront(b)
+		l.buckets[key] = b
+	}
+	// Refill the bucket
+	l.refill(b, now)
+	// Return the time until enough tokens are available, if the bucket does not hold enough tokens
+	if b.tokens < float64(n) {
+		return false, time.Duration((float64(n) - b.tokens) / l.rate * float64(time.Second))
+	}
+	// Take the tokens
+	b.tokens -= float64(n)
+	return true, 0
+}
+
+// refill adds the tokens to bucket b, which accumulated since its last refill, up to burst.
+func (l *Limiter) refill(b *bucket, now time.Time) {
+	if d := now.Sub(b.last); d > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+d.Seconds()*l.rate)
+		b.last = now
+	}
+}
+
+// evict removes the buckets of the least recently seen clients, until the number of buckets is lower than
+// maxClients.
+func (l *Limiter) evict() {
+	for len(l.buckets) >= maxClients {
+		b := l.lru.Remove(l.lru.Back()).(*bucket)
+		delete(l.buckets, b.key)
+	}
+}
+
+// A KeyFunc returns the client key of request r, e.g., its IP address or API key.
+type KeyFunc func(r *http.Request) string
+
+// ByIP returns the IP address of the client of request r as client key.
+func ByIP(r *http.Request) string {
+	// Return the host of the remote address
+	if h, _, e := net.SplitHostPort(r.RemoteAddr); e == nil {
+		return "ip:" + h
+	}
+	return "ip:" + r.RemoteAddr
+}
+
+// ByAPIKey returns a KeyFunc returning the API key given in header h, e.g., X-API-Key, as client key, if it is
+// one of the configured keys. Requests without API key or with an unknown API key are limited by the IP address
+// of the client, so that clients cannot bypass the rate limit with made-up API keys.
+func ByAPIKey(h string, keys ...string) KeyFunc {
+	// m holds the set of configured API keys
+	m := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		if k != "" {
+			m[k] = struct{}{}
+		}
+	}
+	return func(r *http.Request) string {
+		// Return the API key, if it is configured
+		if k := r.Header.Get(h); k != "" {
+			if _, ok := m[k]; ok {
+				return "key:" + k
+			}
+		}
+		// Return the IP address otherwise
+		return ByIP(r)
+	}
+}
+
+// Middleware returns an http.Handler, which takes one token of the client of each request from Limiter l
+// before calling next. The client is identified with key. If the client exceeds its rate limit, the request
+// is rejected with status 429 Too Many Requests, a Retry-After header in seconds and a Rejection as written by
+// TooManyRequests. If key is nil, clients are identified by their IP address.
+func (l *Limiter) Middleware(key KeyFunc, next http.Handler) http.Handler {
+	// Identify clients by IP address, if key is nil
+	if key == nil {
+		key = ByIP
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Call next, if the token is available
+		ok, d := l.Allow(key(r))
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Reject the request otherwise
+		TooManyRequests(w, d)
+	})
+}
+
+// A Rejection describes a request rejected by a Limiter. Package tserr provides no error for status 429 Too Many
+// Requests. Therefore, a Rejection is not an error of package tserr and holds no error id, so that it cannot be
+// mistaken for another error of tserr. It is encoded in the same "error" envelope as errors of package tserr with
+// status code 429, a message and the retry hint in seconds.
+type Rejection struct {
+	Code       int    `json:"code"`        // HTTP status code 429
+	Message    string `json:"message"`     // error message
+	RetryAfter int64  `json:"retry_after"` // retry hint in seconds
+}
+
+// Reject returns the Rejection of a request, which will be allowed after d. The retry hint is d rounded up to full
+// seconds, at least one second.
+func Reject(d time.Duration) *Rejection {
+	// s holds the retry hint in seconds, at least one second
+	s := int64(math.Ceil(d.Seconds()))
+	if s < 1 {
+		s = 1
+	}
+	// Return the Rejection
+	return &Rejection{Code: http.StatusTooManyRequests, Message: "rate limit exceeded, retry after " + strconv.FormatInt(s, 10) + "s", RetryAfter: s}
+}
+
+// Error returns the message of Rejection r.
+func (r *Rejection) Error() string {
+	return r.Message
+}
+
+// TooManyRequests writes status 429 Too Many Requests with a Retry-After header of d rounded up to full
+// seconds and the Rejection returned by Reject in an "error" envelope, e.g.,
+// {"error":{"code":429,"message":"rate limit exceeded, retry after 2s","retry_after":2}}, to w.
+func TooManyRequests(w http.ResponseWriter, d time.Duration) {
+	// r holds the Rejection
+	r := Reject(d)
+	// Write the response
+	w.Header().Set("Retry-After", strconv.FormatInt(r.RetryAfter, 10))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(struct {
+		Error *Rejection `json:"error"`
+	}{r})
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package limit
+
+// Import standard library packages as well as tserr
+import (
+	"encoding/json"     // json
+	"net/http"          // http
+	"net/http/httptest" // httptest
+	"strconv"           // strconv
+	"testing"           // testing
+	"time"              // time
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// clock is a manually advanced clock used for testing
+type clock struct {
+	t time.Time // current time
+}
+
+// now returns the current time of clock c.
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+// newLimiter returns a Limiter with rate r and burst b using a manually advanced clock. The test fails if
+// NewLimiter returns an error.
+func newLimiter(t *testing.T, r float64, b int) (*Limiter, *clock) {
+	t.Helper()
+	l, e := NewLimiter(r, b)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "NewLimiter", Fn: "limiter", Err: e}))
+	}
+	c := &clock{t: time.Unix(0, 0)}
+	l.now = c.now
+	return l, c
+}
+
+// TestAllow takes tokens of two clients with a burst of three and a rate of two tokens per second. The test
+// fails if a token is allowed beyond the burst, the retry hint is invalid or the bucket is not refilled.
+func TestAllow(t *testing.T) {
+	l, c := newLimiter(t, 2, 3)
+	// The burst of client a is allowed
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Errorf("token %d of burst rejected", i)
+		}
+	}
+	// The next token is rejected with a retry hint of half a second
+	if ok, d := l.Allow("a"); ok || d != 500*time.Millisecond {
+		t.Errorf("token beyond burst: allowed %v, retry after %v", ok, d)
+	}
+	// Client b has its own bucket
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("token of client b rejected")
+	}
+	// After a second, two tokens are refilled
+	c.t = c.t.Add(time.Second)
+	if ok, _ := l.AllowN("a", 2); !ok {
+		t.Error("refilled tokens rejected")
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Error("token beyond refill allowed")
+	}
+	// The bucket is not refilled beyond burst
+	c.t = c.t.Add(time.Hour)
+	if ok, _ := l.AllowN("a", 3); !ok {
+		t.Error("burst after refill rejected")
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Error("token beyond burst after refill allowed")
+	}
+	// Requests beyond burst are never allowed
+	if ok, d := l.AllowN("c", 4); ok || d != 0 {
+		t.Errorf("request beyond burst: allowed %v, retry after %v", ok, d)
+	}
+}
+
+// TestEvict fills the Limiter with maxClients clients and adds further clients. The test fails if the number of
+// buckets exceeds maxClients, the bucket of the least recently seen client is not removed or the bucket of a
+// recently seen client is removed.
+func TestEvict(t *testing.T) {
+	l, c := newLimiter(t, 1, 2)
+	// Client active has an empty bucket
+	l.AllowN("active", 2)
+	for i := 1; i < maxClients; i++ {
+		l.Allow(strconv.Itoa(i))
+	}
+	// Client active is seen again, so that client 1 is the least recently seen client
+	l.Allow("active")
+	l.Allow("new")
+	if n := len(l.buckets); n != maxClients {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "buckets", Actual: int64(n), Want: int64(maxClients)}))
+	}
+	for k, want := range map[string]bool{"1": false, "2": true, "active": true, "new": true} {
+		if _, ok := l.buckets[k]; ok != want {
+			t.Errorf("bucket of client %s exists: %v, expected %v", k, ok, want)
+		}
+	}
+	// After a second, the bucket of client active holds one token only
+	c.t = c.t.Add(time.Second)
+	if ok, _ := l.AllowN("active", 2); ok {
+		t.Error("bucket of active client removed")
+	}
+	// The number of buckets does not exceed maxClients
+	for i := 0; i < maxClients; i++ {
+		l.Allow("x" + strconv.Itoa(i))
+	}
+	if n, m := len(l.buckets), l.lru.Len(); n != maxClients || m != maxClients {
+		t.Errorf("%d buckets and %d recently seen clients, expected %d", n, m, maxClients)
+	}
+}
+
+// TestMiddleware sends requests with and without API key through the Middleware. The test fails if the
+// status codes, the Retry-After header or the error do not match or an unknown API key is not limited by IP.
+func TestMiddleware(t *testing.T) {
+	l, _ := newLimiter(t, 0.5, 1)
+	// Start the server
+	s := httptest.NewServer(l.Middleware(ByAPIKey("X-API-Key", "alice", "bob"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	defer s.Close()
+	// get sends a request with API key k and returns the response
+	get := func(k string) *http.Response {
+		t.Helper()
+		r, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+		if k != "" {
+			r.Header.Set("X-API-Key", k)
+		}
+		res, e := s.Client().Do(r)
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Get", Fn: s.URL, Err: e}))
+		}
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+	// The first request of each client is allowed
+	for _, k := range []string{"", "alice", "bob"} {
+		if res := get(k); res.StatusCode != http.StatusNoContent {
+			t.Error(tserr.Equal(&tserr.EqualArgs{Var: "status code of " + k, Actual: int64(res.StatusCode), Want: http.StatusNoContent}))
+		}
+	}
+	// The second request is rejected
+	res := get("alice")
+	if res.StatusCode != http.StatusTooManyRequests || res.Header.Get("Retry-After") != "2" {
+		t.Errorf("invalid response %v, Retry-After %v", res.Status, res.Header.Get("Retry-After"))
+	}
+	// The body holds the Rejection without error id
+	var b map[string]map[string]any
+	if e := json.NewDecoder(res.Body).Decode(&b); e != nil || b["error"]["code"] != float64(http.StatusTooManyRequests) || b["error"]["message"] == "" || b["error"]["retry_after"] != float64(2) {
+		t.Errorf("invalid error %+v: %v", b, e)
+	}
+	if _, ok := b["error"]["id"]; ok {
+		t.Errorf("rejection %+v holds an error id", b)
+	}
+	// An unknown API key is limited by the IP address, whose token is taken
+	if res := get("mallory"); res.StatusCode != http.StatusTooManyRequests {
+		t.Error(tserr.Equal(&tserr.EqualArgs{Var: "status code of unknown key", Actual: int64(res.StatusCode), Want: http.StatusTooManyRequests}))
+	}
+}
+
+// TestNewLimiter creates Limiters with invalid rates and bursts. The test fails if no error is returned.
+func TestNewLimiter(t *testing.T) {
+	for _, c := range []struct {
+		r float64
+		b int
+	}{{0, 1}, {-1, 1}, {1, 0}} {
+		if _, e := NewLimiter(c.r, c.b); e == nil {
+			t.Error(tserr.NilFailed("NewLimiter"))
+		}
+	}
+	// A nil Limiter allows nothing
+	if ok, _ := (*Limiter)(nil).Allow("a"); ok {
+		t.Error("nil Limiter allowed a token")
+	}
+}
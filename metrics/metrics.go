@@ -0,0 +1,408 @@
+// Package metrics provides counters and histograms exposed in the Prometheus text exposition format. A Registry
+// holds metrics and serves them as http.Handler, e.g., on a /metrics endpoint. Dice collects metrics of all dice
+// of package lpdice as lpdice.Observer: rolls per die size and kind of random number generator, the latency of
+// operations on dice expressions, fallbacks from the cryptographically secure random number generator and errors
+// by type.
+//
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package metrics
+
+// Import standard library packages as well as tserr
+import (
+	"fmt"      // fmt
+	"io"       // io
+	"math"     // math
+	"net/http" // http
+	"regexp"   // regexp
+	"sort"     // sort
+	"strconv"  // strconv
+	"strings"  // strings
+	"sync"     // sync
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// contentType defines the content type of the Prometheus text exposition format
+const (
+	contentType string = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// name matches valid names of metrics and labels
+var (
+	name = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// A metric is a family of counters or histograms with the same name and label names
+type metric interface {
+	write(w io.Writer) error
+}
+
+// A Registry holds metrics and writes them in the Prometheus text exposition format. It is safe
+// for concurrent use by multiple goroutines.
+type Registry struct {
+	mu      sync.Mutex        // mutex to protect metrics
+	metrics map[string]metric // metrics by name
+}
+
+// NewRegistry returns a pointer to a new empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// register adds metric m with name n and label names labels to Registry r. It returns an error, if a
+// name is invalid or a metric with the same name already exists.
+func (r *Registry) register(n string, labels []string, m metric) error {
+	// Return an error if r is nil
+	if r == nil {
+		return tserr.NilPtr()
+	}
+	// Return an error if a name is invalid
+	for _, s := range append([]string{n}, labels...) {
+		if !name.MatchString(s) || strings.HasPrefix(s, "__") || s == "le" {
+			return tserr.Forbidden("metric or label name " + s)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Return an error if the metric already exists
+	if _, ok := r.metrics[n]; ok {
+		return tserr.Duplicate("metric " + n)
+	}
+	// Register the metric
+	r.metrics[n] = m
+	return nil
+}
+
+// WriteTo writes all metrics of Registry r in ascending order of their names to w in the Prometheus
+// text exposition format. It returns the number of bytes written and an error, if any.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	// Return an error if r or w is nil
+	if r == nil || w == nil {
+		return 0, tserr.NilPtr()
+	}
+	// Retrieve the metrics in ascending order of their names
+	r.mu.Lock()
+	n := make([]string, 0, len(r.metrics))
+	for k := range r.metrics {
+		n = append(n, k)
+	}
+	sort.Strings(n)
+	m := make([]metric, len(n))
+	for i, k := range n {
+		m[i] = r.metrics[k]
+	}
+	r.mu.Unlock()
+	// Write the metrics
+	c := &counter{w: w}
+	for _, v := range m {
+		if e := v.write(c); e != nil {
+			return c.n, e
+		}
+	}
+	return c.n, nil
+}
+
+// ServeHTTP writes all metrics of Registry r in the Prometheus text exposition format to w.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// Return an error if the method is not GET or HEAD
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, tserr.TypeNotMatching(&tserr.TypeNotMatchingArgs{Act: "method " + req.Method, Want: http.MethodGet}).Error(), http.StatusMethodNotAllowed)
+		return
+	}
+	// Write the metrics
+	w.Header().Set("Content-Type", contentType)
+	r.WriteTo(w)
+}
+
+// counter counts the bytes written to w
+type counter struct {
+	w io.Writer // writer
+	n int64     // number of bytes written
+}
+
+// Write writes p to the writer of c and counts the written bytes.
+func (c *counter) Write(p []byte) (int, error) {
+	n, e := c.w.Write(p)
+	c.n += int64(n)
+	return n, e
+}
+
+// family holds the name, help and label names of a metric and the label values of its series
+type family struct {
+	name   string              // name of the metric
+	help   string              // help text of the metric
+	typ    string              // type of the metric
+	labels []string            // label names
+	mu     sync.Mutex          // mutex to protect series
+	series map[string][]string // label values by key
+}
+
+// init initializes family f with name n, help text h, type t and label names labels.
+func (f *family) init(n, h, t string, labels []string) {
+	f.name, f.help, f.typ, f.labels, f.series = n, h, t, append([]string{}, labels...), make(map[string][]string)
+}
+
+// key returns the key of label values v. It returns an error, if the number of
+// values does not equal the number of label names. f.mu must be held.
+func (f *family) key(v []string) (string, error) {
+	// Return an error if the number of values does not match
+	if len(v) != len(f.labels) {
+		return "", tserr.Equal(&tserr.EqualArgs{Var: "number of label values of " + f.name, Actual: int64(len(v)), Want: int64(len(f.labels))})
+	}
+	// Register and return the key
+	k := strings.Join(v, "\xff")
+	if _, ok := f.series[k]; !ok {
+		f.series[k] = append([]string{}, v...)
+	}
+	return k, nil
+}
+
+// keys returns the keys of all series in ascending order. f.mu must be held.
+func (f *family) keys() []string {
+	k := make([]string, 0, len(f.series))
+	for s := range f.series {
+		k = append(k, s)
+	}
+	sort.Strings(k)
+	return k
+}
+
+// header writes the help text and type of f to w.
+func (f *family) header(w io.Writer) error {
+	_, e := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escape(f.help, false), f.name, f.typ)
+	return e
+}
+
+// labelString returns the label set of label values v with additional label pairs extra, e.g., {sides="6"}.
+func (f *family) labelString(v []string, extra ...string) string {
+	// p holds the label pairs
+	var p []string
+	for i, l := range f.labels {
+		p = append(p, l+`="`+escape(v[i], true)+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		p = append(p, extra[i]+`="`+escape(extra[i+1], true)+`"`)
+	}
+	// Return an empty string if there are no labels
+	if len(p) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(p, ",") + "}"
+}
+
+// escape escapes backslashes and line feeds in s and double quotes, if quote is true.
+func escape(s string, quote bool) string {
+	s = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+	if quote {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
+
+// format returns the text representation of float v.
+func format(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// A CounterVec is a family of counters partitioned by label values, e.g., rolls by number of sides.
+type CounterVec struct {
+	family
+	values map[string]float64 // values by key
+}
+
+// NewCounterVec registers a new CounterVec with name n, help text h and label names labels in Registry r.
+// It returns nil and an error, if a name is invalid or the metric already exists.
+func NewCounterVec(r *Registry, n, h string, labels ...string) (*CounterVec, error) {
+	// c holds the new CounterVec
+	c := &CounterVec{values: make(map[string]float64)}
+	c.init(n, h, "counter", labels)
+	// Register the CounterVec
+	if e := r.register(n, labels, c); e != nil {
+		return nil, e
+	}
+	return c, nil
+}
+
+// Add adds v to the counter with label values lv. It returns an error, if v is negative or
+// the number of label values does not match.
+func (c *CounterVec) Add(v float64, lv ...string) error {
+	// Return an error if c is nil
+	if c == nil {
+		return tserr.NilPtr()
+	}
+	// Return an error if v is negative
+	if v < 0 || math.IsNaN(v) {
+		return tserr.Forbidden("negative increment of counter " + c.name)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Retrieve the key of the label values
+	k, e := c.key(lv)
+	if e != nil {
+		return e
+	}
+	// Add v to the counter
+	c.values[k] += v
+	return nil
+}
+
+// Inc increments the counter with label values lv by one. It returns an error, if the number of label values does not match.
+func (c *CounterVec) Inc(lv ...string) error {
+	return c.Add(1, lv...)
+}
+
+// Value returns the value of the counter with label values lv. It returns zero, if the counter does not exist.
+func (c *CounterVec) Value(lv ...string) float64 {
+	// Return zero if c is nil
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[strings.Join(lv, "\xff")]
+}
+
+// write writes all counters of c to w.
+func (c *CounterVec) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Write help and type
+	if e := c.header(w); e != nil {
+		return e
+	}
+	// Write each counter
+	for _, k := range c.keys() {
+		if _, e := fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelString(c.series[k]), format(c.values[k])); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// histogram holds the observations of a single histogram
+type histogram struct {
+	counts []uint64 // count of observations per bucket, not cumulative
+	sum    float64  // sum of observations
+	count  uint64   // count of observations
+}
+
+// A HistogramVec is a family of histograms partitioned by label values, e.g., latency by operation.
+type HistogramVec struct {
+	family
+	buckets []float64             // upper bounds of buckets in ascending order
+	values  map[string]*histogram // histograms by key
+}
+
+// DefaultBuckets defines default upper bounds of buckets for latencies in seconds
+var (
+	DefaultBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+)
+
+// NewHistogramVec registers a new HistogramVec with name n, help text h, upper bounds of buckets b and label
+// names labels in Registry r. If b is empty, DefaultBuckets are used. It returns nil and an error, if a name is
+// invalid, the buckets are not in ascending order or the metric already exists.
+func NewHistogramVec(r *Registry, n, h string, b []float64, labels ...string) (*HistogramVec, error) {
+	// Use DefaultBuckets if b is empty
+	if len(b) == 0 {
+		b = DefaultBuckets
+	}
+	// Return an error if the buckets are not in strictly ascending order
+	for i := 1; i < len(b); i++ {
+		if b[i] <= b[i-1] {
+			return nil, tserr.Forbidden("buckets of histogram " + n + " not in ascending order")
+		}
+	}
+	// c holds the new HistogramVec
+	c := &HistogramVec{buckets: append([]float64{}, b...), values: make(map[string]*histogram)}
+	c.init(n, h, "histogram", labels)
+	// Register the HistogramVec
+	if e := r.register(n, labels, c); e != nil {
+		return nil, e
+	}
+	return c, nil
+}
+
+// Observe adds observation v to the histogram with label values lv. It returns an error,
+// if the number of label values does not match.
+func (c *HistogramVec) Observe(v float64, lv ...string) error {
+	// Return an error if c is nil
+	if c == nil {
+		return tserr.NilPtr()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Retrieve the key of the label values
+	k, e := c.key(lv)
+	if e != nil {
+		return e
+	}
+	// Retrieve the histogram
+	h, ok := c.values[k]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(c.buckets))}
+		c.values[k] = h
+	}
+	// Add the observation to the first bucket with an upper bound of at least v
+	if i := sort.SearchFloat64s(c.buckets, v); i < len(c.buckets) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+	return nil
+}
+
+// Count returns the count of observations of the histogram with label values lv. It returns zero,
+// if the histogram does not exist.
+func (c *HistogramVec) Count(lv ...string) uint64 {
+	// Return zero if c is nil
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Return the count, if the histogram exists
+	if h, ok := c.values[strings.Join(lv, "\xff")]; ok {
+		return h.count
+	}
+	return 0
+}
+
+// write writes all histograms of c with cumulative buckets, sum and count to w.
+func (c *HistogramVec) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Write help and type
+	if e := c.header(w); e != nil {
+		return e
+	}
+	// Write each histogram
+	for _, k := range c.keys() {
+		h, lv := c.values[k], c.series[k]
+		// Write the cumulative buckets
+		cum := uint64(0)
+		for i, b := range c.buckets {
+			cum += h.counts[i]
+			if _, e := fmt.Fprintf(w, "%s_bucket%s %d\n", c.name, c.labelString(lv, "le", format(b)), cum); e != nil {
+				return e
+			}
+		}
+		// Write the +Inf bucket, sum and count
+		if _, e := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			c.name, c.labelString(lv, "le", "+Inf"), h.count,
+			c.name, c.labelString(lv), format(h.sum),
+			c.name, c.labelString(lv), h.count); e != nil {
+			return e
+		}
+	}
+	return nil
+}
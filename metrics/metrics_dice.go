@@ -0,0 +1,111 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package metrics
+
+// Import standard library packages as well as lpdice
+import (
+	"fmt"     // fmt
+	"strconv" // strconv
+	"time"    // time
+
+	"github.com/thorstenrie/lpdice" // lpdice
+)
+
+// errorTypes holds the names of error types by the id of errors of package tserr
+var (
+	errorTypes = map[int]string{
+		0: "nil_ptr", 1: "check", 2: "not_existent", 3: "op", 4: "nil_failed", 5: "empty", 6: "equal_str",
+		7: "type_not_matching", 8: "forbidden", 9: "return", 10: "higher", 11: "equal", 12: "lower", 14: "not_set",
+		15: "not_available", 16: "equalf", 17: "non_printable", 18: "not_equal", 19: "duplicate", 20: "locked",
+	}
+)
+
+// Dice collects metrics of all dice of package lpdice. It implements lpdice.Observer and is
+// enabled with lpdice.SetObserver. The metrics are registered in a Registry:
+//
+//   - lpdice_rolls_total{sides,source}: rolled dice per number of sides and kind of random number generator
+//   - lpdice_expression_duration_seconds{op}: latency of operations on dice expressions
+//   - lpdice_crypto_fallbacks_total: fallbacks from the cryptographically secure random number generator
+//   - lpdice_errors_total{op,type}: errors per operation and type of error
+type Dice struct {
+	rolls     *CounterVec   // rolled dice
+	latency   *HistogramVec // latency of operations on dice expressions
+	fallbacks *CounterVec   // fallbacks from the cryptographically secure random number generator
+	errors    *CounterVec   // errors
+}
+
+// NewDice registers the metrics of dice in Registry r and returns a pointer to the new Dice. It returns nil
+// and an error, if the metrics cannot be registered, e.g., if they are already registered in r.
+func NewDice(r *Registry) (*Dice, error) {
+	// d holds the new Dice
+	d := &Dice{}
+	// e holds the error of registering a metric, if any
+	var e error
+	if d.rolls, e = NewCounterVec(r, "lpdice_rolls_total", "Number of rolled dice by number of sides and kind of random number generator.", "sides", "source"); e != nil {
+		return nil, e
+	}
+	if d.latency, e = NewHistogramVec(r, "lpdice_expression_duration_seconds", "Latency of operations on dice expressions in seconds.", nil, "op"); e != nil {
+		return nil, e
+	}
+	if d.fallbacks, e = NewCounterVec(r, "lpdice_crypto_fallbacks_total", "Number of dice using a pseudo-random number generator, because the cryptographically secure random number generator is not available."); e != nil {
+		return nil, e
+	}
+	if d.errors, e = NewCounterVec(r, "lpdice_errors_total", "Number of errors by operation and type.", "op", "type"); e != nil {
+		return nil, e
+	}
+	// Return the new Dice
+	return d, nil
+}
+
+// Rolled counts a rolled die with sides and kind of random number generator src.
+func (d *Dice) Rolled(sides int, src lpdice.Source) {
+	if d != nil {
+		d.rolls.Inc(strconv.Itoa(sides), src.String())
+	}
+}
+
+// Fallback counts a fallback from the cryptographically secure random number generator.
+func (d *Dice) Fallback(err error) {
+	if d != nil {
+		d.fallbacks.Inc()
+		d.Error("init", err)
+	}
+}
+
+// Evaluated observes the duration t of operation op on a dice expression and counts error err, if any.
+func (d *Dice) Evaluated(op string, t time.Duration, err error) {
+	if d != nil {
+		d.latency.Observe(t.Seconds(), op)
+		d.Error(op, err)
+	}
+}
+
+// Error counts error err of operation op by its type, e.g., check for tserr.Check. Errors of other packages
+// than tserr are counted as type other. Error can be called by services to count their own errors.
+func (d *Dice) Error(op string, err error) {
+	// Return if d or err is nil
+	if d == nil || err == nil {
+		return
+	}
+	// Count the error by type
+	d.errors.Inc(op, ErrorType(err))
+}
+
+// ErrorType returns the type of error err of package tserr, e.g., not_existent for tserr.NotExistent.
+// It returns other, if err is not an error of package tserr.
+func ErrorType(err error) string {
+	// Retrieve the id of the error
+	var id int
+	if err == nil {
+		return "other"
+	}
+	if n, e := fmt.Sscanf(err.Error(), `{"error":{"id":%d,`, &id); n != 1 || e != nil {
+		return "other"
+	}
+	// Return the type of the error
+	if t, ok := errorTypes[id]; ok {
+		return t
+	}
+	return "other"
+}
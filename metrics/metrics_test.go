@@ -0,0 +1,151 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package metrics
+
+// Import standard library packages as well as lpdice and tserr
+import (
+	"net/http"          // http
+	"net/http/httptest" // httptest
+	"strings"           // strings
+	"testing"           // testing
+	"time"              // time
+
+	"github.com/thorstenrie/lpdice" // lpdice
+	"github.com/thorstenrie/tserr"  // tserr
+)
+
+// TestRegistry writes a counter and a histogram. The test fails if the output does not
+// match the Prometheus text exposition format.
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	// Register a counter and a histogram
+	c, e := NewCounterVec(r, "test_total", "Test \"counter\".", "kind")
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "NewCounterVec", Fn: "test_total", Err: e}))
+	}
+	h, e := NewHistogramVec(r, "test_seconds", "Test histogram.", []float64{0.1, 1})
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "NewHistogramVec", Fn: "test_seconds", Err: e}))
+	}
+	// Record values
+	c.Inc("b")
+	c.Add(2.5, `a"\`)
+	for _, v := range []float64{0.05, 0.5, 2} {
+		h.Observe(v)
+	}
+	// want holds the expected output
+	want := `# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 1
+test_seconds_bucket{le="1"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 2.55
+test_seconds_count 3
+# HELP test_total Test "counter".
+# TYPE test_total counter
+test_total{kind="a\"\\"} 2.5
+test_total{kind="b"} 1
+`
+	// Write the metrics
+	var b strings.Builder
+	if _, e = r.WriteTo(&b); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "WriteTo", Fn: "Registry", Err: e}))
+	}
+	if b.String() != want {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "metrics", Actual: b.String(), Want: want}))
+	}
+}
+
+// TestRegistryErrors registers invalid metrics and records invalid values. The test fails if no error is returned.
+func TestRegistryErrors(t *testing.T) {
+	r := NewRegistry()
+	c, _ := NewCounterVec(r, "test_total", "Test.", "kind")
+	// f holds functions expected to return an error
+	f := map[string]func() error{
+		"duplicate":        func() error { _, e := NewCounterVec(r, "test_total", "Test."); return e },
+		"invalid name":     func() error { _, e := NewCounterVec(r, "1test", "Test."); return e },
+		"reserved label":   func() error { _, e := NewHistogramVec(r, "test_h", "Test.", nil, "le"); return e },
+		"buckets":          func() error { _, e := NewHistogramVec(r, "test_b", "Test.", []float64{1, 1}); return e },
+		"negative":         func() error { return c.Add(-1, "a") },
+		"number of labels": func() error { return c.Inc() },
+	}
+	// Iterate all functions
+	for n, g := range f {
+		if g() == nil {
+			t.Error(tserr.NilFailed(n))
+		}
+	}
+}
+
+// TestDice observes rolls and expressions with Dice. The test fails if the metrics do not match
+// the rolled dice and errors or the handler does not serve the metrics.
+func TestDice(t *testing.T) {
+	// Register Dice as Observer
+	r := NewRegistry()
+	d, e := NewDice(r)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "NewDice", Fn: "Registry", Err: e}))
+	}
+	lpdice.SetObserver(d)
+	defer lpdice.SetObserver(nil)
+	// Roll a seeded d20 three times and a non-seeded 2d6 once
+	d20, _ := lpdice.NewD20()
+	d20.Seed(1)
+	for i := 0; i < 3; i++ {
+		d20.Roll()
+	}
+	x, _ := lpdice.ParseExpr("2d6")
+	x.Roll()
+	// Fail to parse an expression
+	lpdice.ParseExpr("2x6")
+	// The test fails if the metrics do not match
+	for _, c := range []struct {
+		got, want float64
+		n         string
+	}{
+		{d.rolls.Value("20", "deterministic"), 3, "seeded d20"},
+		{d.rolls.Value("6", lpdice.SourceCrypto.String()) + d.rolls.Value("6", lpdice.SourcePseudo.String()), 2, "2d6"},
+		{d.errors.Value(lpdice.OpParse, "check"), 1, "parse errors"},
+		{float64(d.latency.Count(lpdice.OpParse)), 2, "parse latency count"},
+		{float64(d.latency.Count(lpdice.OpRoll)), 1, "roll latency count"},
+	} {
+		if c.got != c.want {
+			t.Error(tserr.Equalf(&tserr.EqualfArgs{Var: c.n, Actual: c.got, Want: c.want}))
+		}
+	}
+	// Fallbacks are counted
+	d.Fallback(tserr.NotAvailable(&tserr.NotAvailableArgs{S: "crypto", Err: http.ErrNotSupported}))
+	d.Evaluated(lpdice.OpRoll, time.Millisecond, nil)
+	if v := d.fallbacks.Value(); v != 1 {
+		t.Error(tserr.Equalf(&tserr.EqualfArgs{Var: "fallbacks", Actual: v, Want: 1}))
+	}
+	// The handler serves the metrics
+	s := httptest.NewServer(r)
+	defer s.Close()
+	res, e := http.Get(s.URL + "/metrics")
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Get", Fn: "/metrics", Err: e}))
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Type") != contentType {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "Content-Type", Actual: res.Header.Get("Content-Type"), Want: contentType}))
+	}
+}
+
+// TestErrorType retrieves the type of errors. The test fails if the type does not match.
+func TestErrorType(t *testing.T) {
+	for _, c := range []struct {
+		e    error
+		want string
+	}{
+		{tserr.NilPtr(), "nil_ptr"},
+		{tserr.NotExistent("x"), "not_existent"},
+		{http.ErrNotSupported, "other"},
+		{nil, "other"},
+	} {
+		if got := ErrorType(c.e); got != c.want {
+			t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "error type", Actual: got, Want: c.want}))
+		}
+	}
+}
@@ -0,0 +1,577 @@
+// Package runner provides a simple framework for line-based command interpreters, e.g., an interactive
+// read-eval-print loop or the execution of command scripts. A Runner is retrieved with New and reads
+// lines from an io.Reader and writes output to an io.Writer. Each line consists of a command key
+// followed by arguments. Arguments are separated by whitespace and may be quoted with single or double
+// quotes to contain whitespace or escaped with a backslash. Commands are registered per Runner with Add
+// and may hold subcommands. A command may declare typed positional arguments and --flag=value flags,
+// which are validated before the command is called.
+//
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package runner
+
+// Import standard library packages as well as tserr, tsfio and tstable
+import (
+	"bufio"     // bufio
+	"context"   // context
+	"fmt"       // fmt
+	"io"        // io
+	"os"        // os
+	"os/signal" // os/signal
+	"strings"   // strings
+	"sync"      // sync
+	"unicode"   // unicode
+
+	"github.com/thorstenrie/tserr"   // tserr
+	"github.com/thorstenrie/tsfio"   // tsfio
+	"github.com/thorstenrie/tstable" // tstable
+)
+
+// tab defines the indentation of the help text
+const (
+	tab string = "  "
+)
+
+// ctxKey is the type of context keys of the package
+type ctxKey int
+
+// outKey is the context key of the output of a Runner and sessionKey is the context key of the session state
+const (
+	outKey ctxKey = iota
+	sessionKey
+)
+
+// A Runner reads lines from input in and calls the registered command for each line. Output is
+// written to out and errors are written to errs. It holds the registry of commands in cmds and the exit command in exit, which is called
+// when the input ends. If prompt is true, prompts are printed before and after reading a line. Lines are read from in
+// by a single goroutine, which is started once and sends the lines to lines.
+type Runner struct {
+	app     string              // name of the application
+	help    string              // help text
+	version string              // version of the application
+	cmds    map[string]*Command // registered commands
+	exit    *Command            // exit command
+	in      io.Reader           // input
+	out     io.Writer           // output
+	errs    io.Writer           // output of errors
+	prompt  bool                // print prompts
+	once    sync.Once           // starts the reading goroutine once
+	lines   chan string         // lines read from in, closed when in ends
+	rerr    error               // read error, set before lines is closed
+}
+
+// New returns a pointer to a new Runner reading from in and writing to out. Prompts are printed if in is
+// a terminal. It returns nil and an error, if in or out is nil.
+func New(in io.Reader, out io.Writer) (*Runner, error) {
+	// Return an error if in or out is nil
+	if in == nil || out == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Return the new Runner
+	return &Runner{cmds: make(map[string]*Command), in: in, out: out, errs: out, prompt: isTerminal(in)}, nil
+}
+
+// Output returns the output of the Runner calling a command with context ctx. It returns os.Stdout, if
+// the context does not belong to a Runner.
+func Output(ctx context.Context) io.Writer {
+	// Return the output of the Runner, if it exists in ctx
+	if w, ok := ctx.Value(outKey).(io.Writer); ok {
+		return w
+	}
+	// Return os.Stdout otherwise
+	return os.Stdout
+}
+
+// printable returns an error if text contains non-printable runes. Argument f names the text.
+func printable(text, f string) error {
+	// Return an error if text contains non-printable runes
+	if text != tsfio.Printable(text) {
+		return tserr.NonPrintable(f)
+	}
+	// Return nil
+	return nil
+}
+
+// HelpText sets the help text of Runner r. It returns an error, if text contains non-printable runes.
+func (r *Runner) HelpText(text string) error {
+	// Return an error if r is nil
+	if r == nil {
+		return tserr.NilPtr()
+	}
+	// Set the help text, if it is printable
+	if e := printable(text, "help text"); e != nil {
+		return e
+	}
+	r.help = text
+	return nil
+}
+
+// Version sets the version of Runner r. It returns an error, if text contains non-printable runes.
+func (r *Runner) Version(text string) error {
+	// Return an error if r is nil
+	if r == nil {
+		return tserr.NilPtr()
+	}
+	// Set the version, if it is printable
+	if e := printable(text, "version"); e != nil {
+		return e
+	}
+	r.version = text
+	return nil
+}
+
+// AppName sets the application name of Runner r. It returns an error, if text contains non-printable runes.
+func (r *Runner) AppName(text string) error {
+	// Return an error if r is nil
+	if r == nil {
+		return tserr.NilPtr()
+	}
+	// Set the application name, if it is printable
+	if e := printable(text, "app name"); e != nil {
+		return e
+	}
+	r.app = text
+	return nil
+}
+
+// SetPrompt enables prompts of Runner r, if p is true, and disables them otherwise.
+func (r *Runner) SetPrompt(p bool) error {
+	// Return an error if r is nil
+	if r == nil {
+		return tserr.NilPtr()
+	}
+	// Set prompt
+	r.prompt = p
+	return nil
+}
+
+// SetErrOutput sets the output of errors of Runner r to w. By default, errors are written to the output of r.
+func (r *Runner) SetErrOutput(w io.Writer) error {
+	// Return an error if r or w is nil
+	if r == nil || w == nil {
+		return tserr.NilPtr()
+	}
+	// Set the output of errors
+	r.errs = w
+	return nil
+}
+
+// HelpCommand registers the help command with key c. The help command prints the help text and all available
+// commands. Called with the key of a command as argument, it prints the usage of the command and its subcommands.
+func (r *Runner) HelpCommand(c string) error {
+	// Return an error if r is nil
+	if r == nil {
+		return tserr.NilPtr()
+	}
+	// Register the help command
+	return r.Add(&Command{Key: c, Function: r.printHelp, Help: "Print usage statement", Usage: "[command]"})
+}
+
+// printHelp prints the help text and all available commands of Runner r. If args contain a command
+// key, it prints the usage of the command and its subcommands.
+func (r *Runner) printHelp(ctx context.Context, args []string) error {
+	// w holds the output
+	w := Output(ctx)
+	// Print the usage of a single command, if requested
+	if len(args) > 0 {
+		// Retrieve the command
+		c, e := r.find(args[0])
+		if e != nil {
+			return e
+		}
+		// Retrieve the subcommand, if requested
+		s, a := c.resolve(args[1:])
+		if len(a) > 0 {
+			return tserr.NotExistent("subcommand " + a[0])
+		}
+		// Print the usage and help of the command
+		fmt.Fprintf(w, "Usage: %s\n", strings.TrimSpace(strings.Join(args, " ")+" "+s.usageText()))
+		if s.Help != "" {
+			fmt.Fprintf(w, "%s%s\n", tab, s.Help)
+		}
+		// Print the flags, if any
+		for _, f := range s.Flags {
+			fmt.Fprintf(w, "%s--%s=%s %s (default %q)\n", tab, f.Name, f.Type, f.Help, f.Default)
+		}
+		// Print the subcommands, if any
+		if len(s.subs) > 0 {
+			fmt.Fprint(w, "\nAvailable subcommands:\n")
+			return printCommands(w, s.subs)
+		}
+		return nil
+	}
+	// text holds the help text
+	text := ""
+	if r.app != "" {
+		text += strings.TrimSpace(r.app+" "+r.version) + "\n"
+	}
+	if r.help != "" {
+		text += fmt.Sprintf("%s\n", r.help)
+	}
+	text += tab + "\nUsage:\n\n" + tab + "[command] [arguments]\n"
+	if len(r.cmds) == 0 {
+		fmt.Fprintln(w, text)
+		return nil
+	}
+	text += "\nAvailable commands:\n"
+	fmt.Fprint(w, text)
+	return printCommands(w, r.cmds)
+}
+
+// printCommands prints a table of commands cmds with their usage and help to w.
+func printCommands(w io.Writer, cmds map[string]*Command) error {
+	// Create the table
+	t, e := tstable.New([]string{"[command]", "[usage]"})
+	if e != nil {
+		return e
+	}
+	// Add a row for each command in ascending order
+	for _, k := range keys(cmds) {
+		t.AddRow([]string{strings.TrimSpace(k + " " + cmds[k].usageText()), cmds[k].Help})
+	}
+	t.SetGrid(&tstable.EmptyGrid)
+	// Print the table
+	ts, e := t.Print()
+	if e != nil {
+		return e
+	}
+	fmt.Fprint(w, ts)
+	return nil
+}
+
+// Add registers Command cmd in Runner r. It returns an error, if the command or
+// one of its subcommands is invalid or the command already exists.
+func (r *Runner) Add(cmd *Command) error {
+	// Return an error if r is nil
+	if r == nil {
+		return tserr.NilPtr()
+	}
+	// Return an error if the command is invalid
+	if e := cmd.check(); e != nil {
+		return e
+	}
+	// Return an error if the command already exists
+	if _, e := r.find(cmd.Key); e == nil {
+		return tserr.Duplicate("command " + cmd.Key)
+	}
+	// Register the command
+	r.cmds[cmd.Key] = cmd
+	return nil
+}
+
+// find returns the registered command with key cmd. It returns nil and an error, if the command does not exist.
+func (r *Runner) find(cmd string) (*Command, error) {
+	// Return the command, if it exists
+	if f, ok := r.cmds[cmd]; ok {
+		return f, nil
+	}
+	// Return an error otherwise
+	return nil, tserr.NotExistent("command " + cmd)
+}
+
+// SetExit sets the registered command with key cmd as exit command of Runner r. The exit command ends Run and
+// is called, if the input ends or the context is cancelled. It returns an error, if the command does not exist.
+func (r *Runner) SetExit(cmd string) error {
+	// Return an error if r is nil
+	if r == nil {
+		return tserr.NilPtr()
+	}
+	// Retrieve the command
+	c, e := r.find(cmd)
+	if e != nil {
+		return e
+	}
+	// Set the exit command
+	r.exit = c
+	return nil
+}
+
+// callExit calls the exit command of Runner r with a context, which is not cancelled when ctx is
+// cancelled. It returns an error, if the exit command is not set.
+func (r *Runner) callExit(ctx context.Context) error {
+	// Return an error if the exit command is not set
+	if r.exit == nil {
+		return tserr.NotSet("exit command")
+	}
+	// Call the exit command
+	return r.exit.call(context.WithValue(context.WithoutCancel(ctx), outKey, r.out), nil)
+}
+
+// Exec executes line l with Runner r. It returns the called command and the error of the command, if any.
+// It returns nil and an error, if the line cannot be parsed or the command does not exist.
+func (r *Runner) Exec(ctx context.Context, l string) (*Command, error) {
+	// Return an error if r is nil
+	if r == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Split the line into command key and arguments
+	cmd, args, e := split(l)
+	if e != nil {
+		return nil, e
+	}
+	// Retrieve the command
+	c, e := r.find(cmd)
+	if e != nil {
+		return nil, e
+	}
+	// Call the command with the output of the Runner in the context
+	return c, c.call(context.WithValue(ctx, outKey, r.out), args)
+}
+
+// errorf prints error e to the output of errors of Runner r.
+func (r *Runner) errorf(e error) {
+	fmt.Fprintf(r.errs, "Error: %s\n", e)
+}
+
+// isComment returns true, if line l is a comment starting with #.
+func isComment(l string) bool {
+	return strings.HasPrefix(strings.TrimSpace(l), "#")
+}
+
+// read starts the reading goroutine of Runner r, if not started yet, and returns the channel of read lines.
+func (r *Runner) read() <-chan string {
+	r.once.Do(func() {
+		r.lines = make(chan string)
+		go r.input()
+	})
+	return r.lines
+}
+
+// input reads lines from the input of Runner r and sends them to r.lines until the input ends. A line read while
+// Run is not running is kept until the next call of Run. When the input ends, it sets the read error, if any, and
+// closes r.lines.
+func (r *Runner) input() {
+	s := bufio.NewScanner(r.in)
+	for s.Scan() {
+		r.lines <- s.Text()
+	}
+	r.rerr = s.Err()
+	close(r.lines)
+}
+
+// Run reads and executes lines from the input of Runner r until the exit command is called, the input
+// ends or ctx is cancelled. If the input ends or ctx is cancelled, the exit command is called with a context,
+// which is not cancelled, so that it can always print its summary. Commands are called with a context, which
+// is cancelled when ctx is cancelled or Run returns. Comments starting with # are ignored. Errors of commands
+// are printed to the output of errors. Lines are read by a single goroutine owned by r, which is started with the
+// first call of Run and ends when the input ends. It is reused by later calls of Run, so that Run leaves no goroutine
+// behind and no line is lost between calls, even if the input blocks, e.g., os.Stdin connected to a terminal. Run
+// must not be called concurrently on the same Runner. It returns an error, if any.
+func (r *Runner) Run(ctx context.Context) error {
+	// Return an error if r is nil
+	if r == nil {
+		return tserr.NilPtr()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Retrieve the lines of the reading goroutine
+	ch := r.read()
+	for {
+		if r.prompt {
+			fmt.Fprint(r.out, "< ")
+		}
+		select {
+		case i, ok := <-ch:
+			if r.prompt {
+				fmt.Fprint(r.out, "> ")
+			}
+			if !ok {
+				// Print the read error, if any, and call the exit command
+				if r.rerr != nil {
+					r.errorf(tserr.Op(&tserr.OpArgs{Op: "read", Fn: "input", Err: r.rerr}))
+				}
+				return r.callExit(ctx)
+			}
+			if isComment(i) {
+				continue
+			}
+			c, err := r.Exec(ctx, i)
+			if err != nil {
+				r.errorf(err)
+			}
+			if c != nil && c == r.exit {
+				return nil
+			}
+		case <-ctx.Done():
+			if r.prompt {
+				fmt.Fprint(r.out, "\n> ")
+			}
+			return r.callExit(ctx)
+		}
+	}
+}
+
+// RunSignals calls Run with a context, which is cancelled when ctx is cancelled or one of the signals sig
+// is received, e.g., os.Interrupt. Therefore, the exit command is called on the signal. If no signals are
+// provided, os.Interrupt is used. After the first signal, the default behavior of the signals is restored.
+func (r *Runner) RunSignals(ctx context.Context, sig ...os.Signal) error {
+	// Use os.Interrupt if no signals are provided
+	if len(sig) == 0 {
+		sig = []os.Signal{os.Interrupt}
+	}
+	// Retrieve a context cancelled on the signals
+	ctx, stop := signal.NotifyContext(ctx, sig...)
+	defer stop()
+	// Restore the default behavior of the signals after the first signal
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	// Run the Runner
+	return r.Run(ctx)
+}
+
+// RunScript executes the lines read from in without prompts. Argument name names the script in error messages.
+// Empty lines and comments starting with # are ignored. Execution ends when the exit command is called, the input
+// ends or ctx is cancelled. If cont is false, execution stops at the first error. Otherwise it continues with the
+// next line. If the script does not call the exit command, it is called when execution ends. Errors are printed to
+// the output of errors. It returns an error, if any line failed or ctx is cancelled.
+func (r *Runner) RunScript(ctx context.Context, in io.Reader, name string, cont bool) error {
+	// Return an error if r or in is nil
+	if r == nil || in == nil {
+		return tserr.NilPtr()
+	}
+	// Execute the script
+	exited, err := r.script(ctx, in, name, cont)
+	// Call the exit command, if the script did not call it
+	if !exited && r.exit != nil {
+		if e := r.callExit(ctx); e != nil {
+			r.errorf(e)
+		}
+	}
+	// Return the error of the script, if any
+	return err
+}
+
+// script executes the lines read from in. It returns true, if the exit command was called, and an error, if any.
+func (r *Runner) script(ctx context.Context, in io.Reader, name string, cont bool) (bool, error) {
+	// failed holds the number of failed lines
+	failed := 0
+	s := bufio.NewScanner(in)
+	for n := 1; s.Scan(); n++ {
+		// Return an error if the context is cancelled
+		if ctx.Err() != nil {
+			return false, tserr.Op(&tserr.OpArgs{Op: "run", Fn: name, Err: ctx.Err()})
+		}
+		// Skip empty lines and comments
+		l := s.Text()
+		if isComment(l) || strings.TrimSpace(l) == "" {
+			continue
+		}
+		// Execute the line
+		c, err := r.Exec(ctx, l)
+		if err != nil {
+			r.errorf(fmt.Errorf("%s:%d: %w", name, n, err))
+			failed++
+			if !cont {
+				return c != nil && c == r.exit, tserr.Op(&tserr.OpArgs{Op: "run", Fn: fmt.Sprintf("%s:%d", name, n), Err: err})
+			}
+		}
+		// End execution if the exit command was called
+		if c != nil && c == r.exit {
+			return true, failedLines(name, failed)
+		}
+	}
+	// Return an error if reading the script failed
+	if err := s.Err(); err != nil {
+		return false, tserr.Op(&tserr.OpArgs{Op: "read", Fn: name, Err: err})
+	}
+	// Return an error if any line failed
+	return false, failedLines(name, failed)
+}
+
+// failedLines returns an error, if n lines of script name failed.
+func failedLines(name string, n int) error {
+	// Return nil if no line failed
+	if n == 0 {
+		return nil
+	}
+	// Return an error with the number of failed lines
+	return tserr.Check(&tserr.CheckArgs{F: name, Err: fmt.Errorf("%d lines failed", n)})
+}
+
+// isTerminal returns true, if in is a terminal.
+func isTerminal(in io.Reader) bool {
+	// Only files can be terminals
+	f, ok := in.(*os.File)
+	if !ok {
+		return false
+	}
+	// Retrieve the file mode
+	fi, e := f.Stat()
+	if e != nil {
+		return false
+	}
+	// Return true for character devices
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// split splits line l into the command key and its arguments like a shell. Arguments are separated by whitespace.
+// Single quotes enclose arguments containing whitespace literally. Double quotes enclose arguments containing
+// whitespace, in which a backslash escapes a double quote or a backslash. Outside of quotes, a backslash escapes
+// any rune. Non-printable runes are dropped. It returns an error, if the line is empty or a quote or escape is not terminated.
+func split(l string) (string, []string, error) {
+	// a holds the arguments
+	var a []string
+	// b holds the current argument, q holds the current quote, if any, esc is true after a backslash
+	// and arg is true, if the current argument has started
+	var (
+		b   strings.Builder
+		q   rune
+		esc bool
+		arg bool
+	)
+	// Iterate all runes of the line
+	for _, c := range l {
+		switch {
+		case esc:
+			// Add the escaped rune to the argument, inside double quotes only " and \ are escaped
+			if q == '"' && c != '"' && c != '\\' {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(c)
+			esc = false
+		case c == '\\' && q != '\'':
+			// Escape the next rune
+			esc, arg = true, true
+		case q != 0 && c == q:
+			// End the quote
+			q = 0
+		case q != 0:
+			// Add quoted runes to the argument
+			b.WriteRune(c)
+		case c == '"' || c == '\'':
+			// Start a quote
+			q, arg = c, true
+		case unicode.IsSpace(c):
+			// End the argument
+			if arg {
+				a, arg = append(a, tsfio.Printable(b.String())), false
+				b.Reset()
+			}
+		default:
+			// Add the rune to the argument
+			b.WriteRune(c)
+			arg = true
+		}
+	}
+	// Return an error if the escape is not terminated
+	if esc {
+		return "", nil, tserr.NotSet("escaped rune")
+	}
+	// Return an error if the quote is not terminated
+	if q != 0 {
+		return "", nil, tserr.NotSet("terminating quote " + string(q))
+	}
+	// Add the last argument
+	if arg {
+		a = append(a, tsfio.Printable(b.String()))
+	}
+	// Return an error if the line is empty
+	if len(a) == 0 {
+		return "", nil, tserr.Empty("line")
+	}
+	// Return the command key and the arguments
+	return a[0], a[1:], nil
+}
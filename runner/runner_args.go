@@ -0,0 +1,314 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package runner
+
+// Import standard library packages context, slices, strconv and strings as well as tserr and tsfio
+import (
+	"context" // context
+	"slices"  // slices
+	"strconv" // strconv
+	"strings" // strings
+
+	"github.com/thorstenrie/tserr" // tserr
+	"github.com/thorstenrie/tsfio" // tsfio
+)
+
+// An ArgType defines the type of an argument or flag value.
+type ArgType int
+
+// Available types of arguments and flags
+const (
+	String ArgType = iota // any string
+	Int                   // 64-bit integer
+	Bool                  // boolean, flags of type Bool may be set without value, e.g., --verbose
+)
+
+// zero holds the zero value of each ArgType
+var (
+	zero = map[ArgType]string{String: "", Int: "0", Bool: "false"}
+)
+
+// String returns the name of ArgType t.
+func (t ArgType) String() string {
+	switch t {
+	case Int:
+		return "int"
+	case Bool:
+		return "bool"
+	default:
+		return "string"
+	}
+}
+
+// An Arg declares a positional argument of a Command. Name names the argument in usage texts and error
+// messages and is used to retrieve its value. If Optional is true, the argument may be omitted. Optional
+// arguments must follow required arguments. If Rest is true, the argument consumes all remaining arguments
+// joined by single spaces, e.g., an expression 2d6 + 3. Only the last argument may be Rest. If Choices are
+// provided, the value must equal one of the choices.
+type Arg struct {
+	Name     string   // name of the argument
+	Type     ArgType  // type of the argument
+	Optional bool     // argument may be omitted
+	Rest     bool     // argument consumes all remaining arguments
+	Choices  []string // valid values of the argument, if any
+}
+
+// A Flag declares a named option of a Command given as --name=value anywhere in the arguments. A Flag of
+// type Bool may be given as --name. Default is the value of the flag, if it is not given.
+type Flag struct {
+	Name    string  // name of the flag
+	Type    ArgType // type of the flag
+	Default string  // default value of the flag
+	Help    string  // description of the flag
+}
+
+// An ArgsFunc implements a command with declared arguments and flags. It is called with the
+// context of the Runner and the validated values of the arguments and flags.
+type ArgsFunc func(context.Context, *Values) error
+
+// Values holds the validated values of the arguments and flags of a command call.
+type Values struct {
+	v map[string]string // values by name
+}
+
+// Has returns true, if the argument or flag name has a value. Flags always have a value.
+func (v *Values) Has(name string) bool {
+	// Return false if v is nil
+	if v == nil {
+		return false
+	}
+	// Return true if the value exists
+	_, ok := v.v[name]
+	return ok
+}
+
+// String returns the value of the argument or flag name. It returns an empty string, if it has no value.
+func (v *Values) String(name string) string {
+	// Return an empty string if v is nil
+	if v == nil {
+		return ""
+	}
+	// Return the value
+	return v.v[name]
+}
+
+// Int64 returns the value of the argument or flag name of type Int. It returns zero, if it has no value.
+func (v *Values) Int64(name string) int64 {
+	// Parse the value, which has been validated before
+	i, _ := strconv.ParseInt(v.String(name), 10, 64)
+	return i
+}
+
+// Int returns the value of the argument or flag name of type Int. It returns zero, if it has no value.
+func (v *Values) Int(name string) int {
+	return int(v.Int64(name))
+}
+
+// Bool returns the value of the argument or flag name of type Bool. It returns false, if it has no value.
+func (v *Values) Bool(name string) bool {
+	// Parse the value, which has been validated before
+	b, _ := strconv.ParseBool(v.String(name))
+	return b
+}
+
+// checkArgs validates the declared arguments and flags of Command c. It returns an error, if any.
+func (c *Command) checkArgs() error {
+	// names holds the names of all arguments and flags
+	names := make(map[string]bool)
+	// opt is true after the first optional argument
+	opt := false
+	// Iterate all arguments
+	for i, a := range c.Args {
+		// Return an error if the name is empty, not printable or a duplicate
+		if e := checkName(a.Name, names); e != nil {
+			return e
+		}
+		// Return an error if a required argument follows an optional argument
+		if opt && !a.Optional {
+			return tserr.Forbidden("required argument " + a.Name + " after optional argument")
+		}
+		opt = opt || a.Optional
+		// Return an error if a Rest argument is not the last argument
+		if a.Rest && i != len(c.Args)-1 {
+			return tserr.Forbidden("rest argument " + a.Name + " before last argument")
+		}
+		// Return an error if a choice does not match the type
+		for _, ch := range a.Choices {
+			if e := checkType(a.Name, a.Type, ch); e != nil {
+				return e
+			}
+		}
+	}
+	// Iterate all flags
+	for _, f := range c.Flags {
+		// Return an error if the name is empty, not printable or a duplicate
+		if e := checkName(f.Name, names); e != nil {
+			return e
+		}
+		// Return an error if the default value does not match the type
+		if f.Default != "" {
+			if e := checkType(f.Name, f.Type, f.Default); e != nil {
+				return e
+			}
+		}
+	}
+	// Return nil
+	return nil
+}
+
+// checkName returns an error, if name n is empty, contains non-printable runes or whitespace or exists in names.
+// Otherwise, it adds n to names.
+func checkName(n string, names map[string]bool) error {
+	// Return an error if n is empty
+	if n == "" {
+		return tserr.Empty("argument name")
+	}
+	// Return an error if n is not printable or contains whitespace
+	if n != tsfio.Printable(n) || len(strings.Fields(n)) != 1 {
+		return tserr.NonPrintable("argument name " + n)
+	}
+	// Return an error if n is a duplicate
+	if names[n] {
+		return tserr.Duplicate("argument name " + n)
+	}
+	names[n] = true
+	// Return nil
+	return nil
+}
+
+// checkType returns an error, if value v of argument or flag n does not match ArgType t.
+func checkType(n string, t ArgType, v string) error {
+	// e holds the parsing error, if any
+	var e error
+	// Parse the value according to its type
+	switch t {
+	case Int:
+		_, e = strconv.ParseInt(v, 10, 64)
+	case Bool:
+		_, e = strconv.ParseBool(v)
+	}
+	// Return an error if the value does not match the type
+	if e != nil {
+		return tserr.TypeNotMatching(&tserr.TypeNotMatchingArgs{Act: "value " + v + " of " + n, Want: t.String()})
+	}
+	// Return nil
+	return nil
+}
+
+// parseArgs validates args against the declared arguments and flags of Command c. It returns the values and
+// an error, if any argument is missing, unexpected, does not match its type or is not one of its choices.
+func (c *Command) parseArgs(args []string) (*Values, error) {
+	// v holds the values
+	v := &Values{v: make(map[string]string)}
+	// Set the default values of flags
+	flags := make(map[string]*Flag, len(c.Flags))
+	for i := range c.Flags {
+		f := &c.Flags[i]
+		flags[f.Name] = f
+		v.v[f.Name] = f.Default
+		if f.Default == "" {
+			v.v[f.Name] = zero[f.Type]
+		}
+	}
+	// pos holds positional arguments
+	var pos []string
+	// Separate flags from positional arguments
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		// All arguments after -- are positional arguments
+		if a == "--" {
+			pos = append(pos, args[i+1:]...)
+			break
+		}
+		// Arguments not starting with -- are positional arguments
+		if !strings.HasPrefix(a, "--") {
+			pos = append(pos, a)
+			continue
+		}
+		// Split the flag into name and value
+		n, val, ok := strings.Cut(a[2:], "=")
+		f, exists := flags[n]
+		// Return an error if the flag does not exist
+		if !exists {
+			return nil, tserr.NotExistent("flag --" + n)
+		}
+		// Flags of type Bool may be given without value
+		if !ok {
+			if f.Type != Bool {
+				return nil, tserr.NotSet("value of flag --" + n)
+			}
+			val = "true"
+		}
+		// Return an error if the value does not match the type
+		if e := checkType("flag --"+n, f.Type, val); e != nil {
+			return nil, e
+		}
+		v.v[n] = val
+	}
+	// Assign positional arguments
+	for i, a := range c.Args {
+		// Return an error if a required argument is missing
+		if i >= len(pos) {
+			if !a.Optional {
+				return nil, tserr.NotSet("argument " + a.Name)
+			}
+			break
+		}
+		// Retrieve the value of the argument
+		val := pos[i]
+		if a.Rest {
+			val = strings.Join(pos[i:], " ")
+		}
+		// Return an error if the value does not match the type
+		if e := checkType("argument "+a.Name, a.Type, val); e != nil {
+			return nil, e
+		}
+		// Return an error if the value is not one of the choices
+		if len(a.Choices) > 0 && !slices.Contains(a.Choices, val) {
+			return nil, tserr.NotExistent("value " + val + " of argument " + a.Name)
+		}
+		v.v[a.Name] = val
+	}
+	// Return an error if there are unexpected arguments
+	if n := len(c.Args); len(pos) > n && (n == 0 || !c.Args[n-1].Rest) {
+		return nil, tserr.Lower(&tserr.LowerArgs{Var: "number of arguments", Actual: int64(len(pos)), HigherBound: int64(n + 1)})
+	}
+	// Return the values
+	return v, nil
+}
+
+// usage returns the usage text generated from the declared arguments and flags of Command c,
+// e.g., <sides:int> [expression...] [--width=int].
+func (c *Command) usage() string {
+	// u holds the parts of the usage text
+	var u []string
+	// Add each argument
+	for _, a := range c.Args {
+		s := a.Name
+		if a.Type != String {
+			s += ":" + a.Type.String()
+		}
+		if len(a.Choices) > 0 {
+			s = a.Name + ":{" + strings.Join(a.Choices, ",") + "}"
+		}
+		if a.Rest {
+			s += "..."
+		}
+		if a.Optional {
+			u = append(u, "["+s+"]")
+		} else {
+			u = append(u, "<"+s+">")
+		}
+	}
+	// Add each flag
+	for _, f := range c.Flags {
+		if f.Type == Bool {
+			u = append(u, "[--"+f.Name+"]")
+		} else {
+			u = append(u, "[--"+f.Name+"="+f.Type.String()+"]")
+		}
+	}
+	// Return the usage text
+	return strings.Join(u, " ")
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package runner
+
+// Import standard library packages context, sort and strings as well as tserr and tsfio
+import (
+	"context" // context
+	"sort"    // sort
+	"strings" // strings
+
+	"github.com/thorstenrie/tserr" // tserr
+	"github.com/thorstenrie/tsfio" // tsfio
+)
+
+// A CommandFunc implements a command. It is called with the context of the Runner and the
+// arguments following the command key. Output should be written to Output(ctx).
+type CommandFunc func(context.Context, []string) error
+
+// A Command is identified by its Key, which is typed to call the command. Help is a short description printed
+// in the list of available commands and Usage describes the arguments of the command, e.g., [expression].
+// Function implements the command and receives the raw arguments. Alternatively, Handler implements the command
+// and receives the values of the arguments and flags declared in Args and Flags, which are validated before the
+// Handler is called. If Usage is empty, it is generated from Args and Flags. A command may hold subcommands in Sub.
+// If the first argument of a command matches the key of a subcommand, the subcommand is called with the remaining
+// arguments. Function and Handler may be nil if the command holds subcommands.
+type Command struct {
+	Key      string      // key to call the command
+	Help     string      // short description of the command
+	Usage    string      // usage of the arguments of the command
+	Function CommandFunc // implementation of the command with raw arguments
+	Handler  ArgsFunc    // implementation of the command with declared arguments
+	Args     []Arg       // declared positional arguments
+	Flags    []Flag      // declared flags
+	Sub      []*Command  // subcommands
+	subs     map[string]*Command
+}
+
+// check validates Command c and its subcommands and builds the registry of subcommands.
+// It returns an error, if any.
+func (c *Command) check() error {
+	// Return an error if c is nil
+	if c == nil {
+		return tserr.NilPtr()
+	}
+	// Return an error if the key is empty
+	if c.Key == "" {
+		return tserr.Empty("command key")
+	}
+	// Return an error if the key, help or usage contain non-printable runes or the key contains a space
+	for _, s := range []string{c.Key, c.Help, c.Usage} {
+		if s != tsfio.Printable(s) {
+			return tserr.NonPrintable(c.Key)
+		}
+	}
+	if _, a, e := split(c.Key); e != nil || len(a) > 0 {
+		return tserr.Forbidden("command key " + c.Key)
+	}
+	// Return an error if the command cannot be executed
+	if c.Function == nil && c.Handler == nil && len(c.Sub) == 0 {
+		return tserr.NotSet("function of command " + c.Key)
+	}
+	// Return an error if the command has both a Function and a Handler
+	if c.Function != nil && c.Handler != nil {
+		return tserr.Duplicate("function of command " + c.Key)
+	}
+	// Return an error if the declared arguments or flags are invalid
+	if e := c.checkArgs(); e != nil {
+		return tserr.Check(&tserr.CheckArgs{F: "arguments of command " + c.Key, Err: e})
+	}
+	// Build the registry of subcommands
+	c.subs = make(map[string]*Command, len(c.Sub))
+	for _, s := range c.Sub {
+		// Return an error if the subcommand is invalid
+		if e := s.check(); e != nil {
+			return e
+		}
+		// Return an error if the subcommand already exists
+		if _, ok := c.subs[s.Key]; ok {
+			return tserr.Duplicate("subcommand " + s.Key)
+		}
+		c.subs[s.Key] = s
+	}
+	// Return nil
+	return nil
+}
+
+// resolve returns the command or subcommand to be called with args together with the remaining arguments.
+func (c *Command) resolve(args []string) (*Command, []string) {
+	// Descend into subcommands as long as the first argument matches a subcommand
+	for len(args) > 0 {
+		s, ok := c.subs[args[0]]
+		if !ok {
+			break
+		}
+		c, args = s, args[1:]
+	}
+	// Return the command and the remaining arguments
+	return c, args
+}
+
+// call calls Command c with context ctx and arguments args. If args start with the key of a
+// subcommand, the subcommand is called. It returns an error, if any.
+func (c *Command) call(ctx context.Context, args []string) error {
+	// Retrieve the command or subcommand to be called
+	s, a := c.resolve(args)
+	// Call the Handler with the validated values of the arguments
+	if s.Handler != nil {
+		v, e := s.parseArgs(a)
+		if e != nil {
+			return tserr.Check(&tserr.CheckArgs{F: "usage " + strings.TrimSpace(s.Key+" "+s.usageText()), Err: e})
+		}
+		return s.Handler(ctx, v)
+	}
+	// Return an error if the command has no function
+	if s.Function == nil {
+		// Report the missing subcommand or the unknown subcommand
+		if len(a) == 0 {
+			return tserr.NotSet("subcommand of " + s.Key)
+		}
+		return tserr.NotExistent("subcommand " + a[0])
+	}
+	// Call the command
+	return s.Function(ctx, a)
+}
+
+// usageText returns the usage of Command c. If Usage is empty, it is generated from the declared arguments and flags.
+func (c *Command) usageText() string {
+	// Return Usage, if provided
+	if c.Usage != "" {
+		return c.Usage
+	}
+	// Return the generated usage
+	return c.usage()
+}
+
+// keys returns the keys of commands in cmds in ascending order.
+func keys(cmds map[string]*Command) []string {
+	// k holds the keys
+	k := make([]string, 0, len(cmds))
+	for c := range cmds {
+		k = append(k, c)
+	}
+	// Sort and return the keys
+	sort.Strings(k)
+	return k
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package runner
+
+// Import standard library packages context, errors, io, net and sync as well as tserr
+import (
+	"context" // context
+	"errors"  // errors
+	"io"      // io
+	"net"     // net
+	"sync"    // sync
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// A SessionFunc returns the state of a new session, e.g., a die and the history of results. It is called
+// for each connection served by Serve.
+type SessionFunc func() any
+
+// WithSession returns a copy of ctx holding the session state s. Commands retrieve the state with Session.
+func WithSession(ctx context.Context, s any) context.Context {
+	return context.WithValue(ctx, sessionKey, s)
+}
+
+// Session returns the session state of the Runner calling a command with context ctx. It returns nil,
+// if the context does not hold a session state.
+func Session(ctx context.Context) any {
+	return ctx.Value(sessionKey)
+}
+
+// Clone returns a copy of Runner r reading from in and writing output and errors to out. The copy shares the
+// registered commands and the exit command with r and uses the prompt setting of r. It returns nil and an error,
+// if r, in or out is nil.
+func (r *Runner) Clone(in io.Reader, out io.Writer) (*Runner, error) {
+	// Return an error if r, in or out is nil
+	if r == nil || in == nil || out == nil {
+		return nil, tserr.NilPtr()
+	}
+	// Return the copy
+	return &Runner{app: r.app, help: r.help, version: r.version, cmds: r.cmds, exit: r.exit, in: in, out: out, errs: out, prompt: r.prompt}, nil
+}
+
+// Serve accepts connections on listener l, e.g., a TCP or Unix socket listener, and runs the registered
+// commands of Runner r for each connection concurrently as with Run. Each connection reads lines from and
+// writes its output and errors to the connection. If session is not nil, each connection holds its own
+// session state retrieved from session, which commands retrieve with Session. Connections use the prompt
+// setting of r. Commands must not be added while serving. When ctx is cancelled, Serve closes l, calls the
+// exit command of each connection and waits for all connections to end. It returns nil, if ctx is cancelled,
+// and an error, if accepting a connection fails.
+func (r *Runner) Serve(ctx context.Context, l net.Listener, session SessionFunc) error {
+	// Return an error if r or l is nil
+	if r == nil || l == nil {
+		return tserr.NilPtr()
+	}
+	// Close the listener when ctx is cancelled or Serve returns
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	// wg holds the running connections
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		// Accept the next connection
+		c, err := l.Accept()
+		if err != nil {
+			// Return nil if ctx is cancelled
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return tserr.Op(&tserr.OpArgs{Op: "accept", Fn: l.Addr().String(), Err: err})
+		}
+		// Run the commands of the connection with its own session state
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer c.Close()
+			cctx := ctx
+			if session != nil {
+				cctx = WithSession(ctx, session())
+			}
+			cr, _ := r.Clone(c, c)
+			if e := cr.Run(cctx); e != nil {
+				cr.errorf(e)
+			}
+		}()
+	}
+}
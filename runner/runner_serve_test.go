@@ -0,0 +1,90 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package runner
+
+// Import standard library packages as well as tserr
+import (
+	"bufio"         // bufio
+	"context"       // context
+	"fmt"           // fmt
+	"net"           // net
+	"path/filepath" // filepath
+	"strings"       // strings
+	"testing"       // testing
+	"time"          // time
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// TestServe serves a Runner over TCP and Unix sockets to two concurrent clients. Each client increments
+// a counter held in its session state. The test fails if the clients share the counter, the exit command
+// does not end a connection or cancelling the context does not end Serve and the remaining connection.
+func TestServe(t *testing.T) {
+	// Iterate TCP and Unix sockets
+	for _, n := range []struct{ network, addr string }{{"tcp", "127.0.0.1:0"}, {"unix", filepath.Join(t.TempDir(), "dice.sock")}} {
+		// Create the Runner with a command incrementing the counter of the session
+		r := testRunner(t, "", &strings.Builder{})
+		if e := r.Add(&Command{Key: "count", Function: func(ctx context.Context, args []string) error {
+			c := Session(ctx).(*int)
+			*c++
+			fmt.Fprintln(Output(ctx), *c)
+			return nil
+		}}); e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Add", Fn: "count", Err: e}))
+		}
+		// Listen on the socket
+		l, e := net.Listen(n.network, n.addr)
+		if e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Listen", Fn: n.network, Err: e}))
+		}
+		// Serve the Runner
+		ctx, cancel := context.WithCancel(context.Background())
+		errc := make(chan error, 1)
+		go func() { errc <- r.Serve(ctx, l, func() any { return new(int) }) }()
+		// Connect two clients
+		var (
+			c [2]net.Conn
+			s [2]*bufio.Scanner
+		)
+		for i := range c {
+			if c[i], e = net.Dial(n.network, l.Addr().String()); e != nil {
+				t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Dial", Fn: n.network, Err: e}))
+			}
+			defer c[i].Close()
+			c[i].SetDeadline(time.Now().Add(5 * time.Second))
+			s[i] = bufio.NewScanner(c[i])
+		}
+		// expect sends line l with client i. The test fails if the response is not want.
+		expect := func(i int, l, want string) {
+			t.Helper()
+			fmt.Fprintln(c[i], l)
+			if !s[i].Scan() || s[i].Text() != want {
+				t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: fmt.Sprintf("%s response of client %d", n.network, i), Actual: s[i].Text(), Want: want}))
+			}
+		}
+		// The clients hold their own counters
+		expect(0, "count", "1")
+		expect(0, "count", "2")
+		expect(1, "count", "1")
+		expect(0, "count", "3")
+		// The exit command ends the first connection
+		expect(0, "stop", "bye")
+		if s[0].Scan() {
+			t.Errorf("connection not closed after exit command, received %q", s[0].Text())
+		}
+		// Cancelling the context calls the exit command of the second connection and ends Serve
+		cancel()
+		if !s[1].Scan() || s[1].Text() != "bye" {
+			t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: n.network + " exit on cancel", Actual: s[1].Text(), Want: "bye"}))
+		}
+		select {
+		case e = <-errc:
+			if e != nil {
+				t.Error(tserr.Op(&tserr.OpArgs{Op: "Serve", Fn: n.network, Err: e}))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal(tserr.NotAvailable(&tserr.NotAvailableArgs{S: "Serve", Err: context.DeadlineExceeded}))
+		}
+	}
+}
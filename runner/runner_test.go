@@ -0,0 +1,469 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package runner
+
+// Import standard library packages as well as tserr
+import (
+	"context"   // context
+	"fmt"       // fmt
+	"os"        // os
+	"os/signal" // os/signal
+	"runtime"   // runtime
+	"strings"   // strings
+	"testing"   // testing
+	"time"      // time
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// TestSplit splits lines into command keys and arguments. The test fails if the
+// result does not match the expected arguments or an invalid line does not return an error.
+func TestSplit(t *testing.T) {
+	// tc holds lines and the expected command key and arguments
+	tc := []struct {
+		l    string
+		want []string
+	}{
+		{"roll", []string{"roll"}},
+		{"  roll   2d6 ", []string{"roll", "2d6"}},
+		{`roll "2d6 + 3"`, []string{"roll", "2d6 + 3"}},
+		{`say 'it''s' "a 'b'" ""`, []string{"say", "its", "a 'b'", ""}},
+		{"a\tb", []string{"a", "b"}},
+		{`say a\ b \"c\" "d \"e\" \x" 'f\g'`, []string{"say", "a b", `"c"`, `d "e" \x`, `f\g`}},
+		{"chart --width=20 2d6", []string{"chart", "--width=20", "2d6"}},
+	}
+	// Iterate all lines
+	for _, c := range tc {
+		k, a, e := split(c.l)
+		// The test fails if split returns an error
+		if e != nil {
+			t.Error(tserr.Op(&tserr.OpArgs{Op: "split", Fn: c.l, Err: e}))
+			continue
+		}
+		// The test fails if the key or the arguments do not match
+		got := fmt.Sprintf("%q", append([]string{k}, a...))
+		if want := fmt.Sprintf("%q", c.want); got != want {
+			t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: c.l, Actual: got, Want: want}))
+		}
+	}
+	// The test fails if invalid lines do not return an error
+	for _, l := range []string{"", "   ", `roll "2d6`, `roll \`} {
+		if _, _, e := split(l); e == nil {
+			t.Error(tserr.NilFailed("split " + l))
+		}
+	}
+}
+
+// testRunner returns a new Runner reading from in and writing to out with an echo command, a
+// stop command with a subcommand and a help command.
+func testRunner(t *testing.T, in string, out *strings.Builder) *Runner {
+	// Create the Runner
+	r, e := New(strings.NewReader(in), out)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "New", Fn: "Runner", Err: e}))
+	}
+	// echo prints its arguments
+	echo := func(ctx context.Context, args []string) error {
+		fmt.Fprintln(Output(ctx), strings.Join(args, "|"))
+		return nil
+	}
+	// fail always returns an error
+	fail := func(ctx context.Context, args []string) error {
+		return tserr.Forbidden("fail")
+	}
+	// Register the commands
+	for _, c := range []*Command{
+		{Key: "echo", Function: echo, Usage: "[text]", Help: "Print text"},
+		{Key: "fail", Function: fail, Help: "Fail"},
+		{Key: "deck", Help: "Deck", Sub: []*Command{
+			{Key: "draw", Function: echo, Usage: "[n]", Help: "Draw cards"},
+		}},
+		{Key: "stop", Function: func(ctx context.Context, args []string) error {
+			fmt.Fprintln(Output(ctx), "bye")
+			return nil
+		}},
+	} {
+		if e := r.Add(c); e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Add", Fn: c.Key, Err: e}))
+		}
+	}
+	r.HelpCommand("help")
+	r.SetExit("stop")
+	// Return the Runner
+	return r
+}
+
+// TestRun runs commands read from the input. The test fails if the output does not match.
+func TestRun(t *testing.T) {
+	// out holds the output
+	var out strings.Builder
+	// Create the Runner
+	r := testRunner(t, "echo 'a b' c\n# comment\ndeck draw 3\ndeck\nunknown\n", &out)
+	// Run the Runner
+	if e := r.Run(context.Background()); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Run", Fn: "r", Err: e}))
+	}
+	// The test fails if the output does not match
+	got := out.String()
+	for _, want := range []string{"a b|c\n", "3\n", "Error: ", "subcommand of deck not set", "command unknown does not exist", "bye\n"} {
+		if !strings.Contains(got, want) {
+			t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "output", Actual: got, Want: want}))
+		}
+	}
+	// The test fails if prompts are printed
+	if strings.Contains(got, "< ") {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "output", Actual: got, Want: "no prompts"}))
+	}
+}
+
+// TestRunScript runs a script with and without stopping on errors. The test fails if the
+// execution does not stop or continue as expected.
+func TestRunScript(t *testing.T) {
+	// s holds the script
+	s := "echo 1\nfail\n\necho 2\nstop\necho 3\n"
+	// out holds the output
+	var out strings.Builder
+	// Run the script stopping on errors
+	r := testRunner(t, "", &out)
+	// The test fails if RunScript returns nil
+	if e := r.RunScript(context.Background(), strings.NewReader(s), "test", false); e == nil {
+		t.Error(tserr.NilFailed("RunScript"))
+	}
+	// The test fails if the script did not stop at line 2
+	if got := out.String(); !strings.Contains(got, "test:2:") || strings.Contains(got, "2\n") {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "output", Actual: got, Want: "stop at line 2"}))
+	}
+	// Run the script continuing on errors
+	out.Reset()
+	// The test fails if RunScript returns nil
+	if e := r.RunScript(context.Background(), strings.NewReader(s), "test", true); e == nil {
+		t.Error(tserr.NilFailed("RunScript"))
+	}
+	// The test fails if the script did not continue until the exit command
+	if got := out.String(); !strings.Contains(got, "2\nbye\n") || strings.Contains(got, "3\n") {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "output", Actual: got, Want: "continue until stop"}))
+	}
+}
+
+// TestHelp prints the help of all commands and of a subcommand. The test fails if the usage is missing.
+func TestHelp(t *testing.T) {
+	// out holds the output
+	var out strings.Builder
+	// Create the Runner
+	r := testRunner(t, "", &out)
+	// Print help of all commands
+	if _, e := r.Exec(context.Background(), "help"); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Exec", Fn: "help", Err: e}))
+	}
+	// The test fails if the usage of echo is missing
+	if got := out.String(); !strings.Contains(got, "echo [text]") {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "help", Actual: got, Want: "echo [text]"}))
+	}
+	// Print help of a subcommand
+	out.Reset()
+	if _, e := r.Exec(context.Background(), "help deck draw"); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Exec", Fn: "help deck draw", Err: e}))
+	}
+	// The test fails if the usage of the subcommand is missing
+	if got := out.String(); !strings.HasPrefix(got, "Usage: deck draw [n]\n") {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "help deck draw", Actual: got, Want: "Usage: deck draw [n]"}))
+	}
+}
+
+// TestAdd adds invalid commands. The test fails if Add returns nil.
+func TestAdd(t *testing.T) {
+	// Create the Runner
+	var out strings.Builder
+	r := testRunner(t, "", &out)
+	// f is a command function
+	f := func(context.Context, []string) error { return nil }
+	// Iterate all invalid commands
+	for _, c := range []*Command{
+		nil,
+		{Key: "", Function: f},
+		{Key: "a b", Function: f},
+		{Key: "nofunc"},
+		{Key: "echo", Function: f},
+		{Key: "dup", Sub: []*Command{{Key: "x", Function: f}, {Key: "x", Function: f}}},
+	} {
+		// The test fails if Add returns nil
+		if e := r.Add(c); e == nil {
+			t.Error(tserr.NilFailed("Add"))
+		}
+	}
+	// The test fails if SetExit returns nil for a missing command
+	if e := r.SetExit("missing"); e == nil {
+		t.Error(tserr.NilFailed("SetExit"))
+	}
+}
+
+// TestArgs calls a command with declared arguments and flags. The test fails if valid arguments are
+// not validated as expected or invalid arguments do not return an error.
+func TestArgs(t *testing.T) {
+	// out holds the output
+	var out strings.Builder
+	// Create the Runner
+	r, _ := New(strings.NewReader(""), &out)
+	// cmd declares a required integer, an optional rest argument and two flags
+	cmd := &Command{Key: "cmd",
+		Args:  []Arg{{Name: "n", Type: Int, Choices: []string{"4", "6"}}, {Name: "expr", Optional: true, Rest: true}},
+		Flags: []Flag{{Name: "width", Type: Int, Default: "40"}, {Name: "verbose", Type: Bool}},
+		Handler: func(ctx context.Context, v *Values) error {
+			fmt.Fprintf(Output(ctx), "%d|%v|%s|%d|%v", v.Int("n"), v.Has("expr"), v.String("expr"), v.Int("width"), v.Bool("verbose"))
+			return nil
+		}}
+	// The test fails if Add returns an error
+	if e := r.Add(cmd); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Add", Fn: "cmd", Err: e}))
+	}
+	// The test fails if the usage does not match
+	if u, want := cmd.usageText(), "<n:{4,6}> [expr...] [--width=int] [--verbose]"; u != want {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "usage", Actual: u, Want: want}))
+	}
+	// tc holds valid lines and the expected output
+	tc := []struct{ l, want string }{
+		{"cmd 4", "4|false||40|false"},
+		{"cmd 6 2d6 + 3 --verbose", "6|true|2d6 + 3|40|true"},
+		{"cmd --width=10 4 -- --x", "4|true|--x|10|false"},
+	}
+	// Iterate all valid lines
+	for _, c := range tc {
+		out.Reset()
+		// The test fails if Exec returns an error or the output does not match
+		if _, e := r.Exec(context.Background(), c.l); e != nil {
+			t.Error(tserr.Op(&tserr.OpArgs{Op: "Exec", Fn: c.l, Err: e}))
+		} else if out.String() != c.want {
+			t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: c.l, Actual: out.String(), Want: c.want}))
+		}
+	}
+	// Iterate all invalid lines
+	for _, l := range []string{"cmd", "cmd x", "cmd 5", "cmd 4 --width", "cmd 4 --width=x", "cmd 4 --other=1", "cmd 4 --verbose=maybe"} {
+		// The test fails if Exec returns nil
+		if _, e := r.Exec(context.Background(), l); e == nil {
+			t.Error(tserr.NilFailed("Exec " + l))
+		}
+	}
+	// Iterate invalid declarations
+	for _, c := range []*Command{
+		{Key: "a", Handler: cmd.Handler, Args: []Arg{{Name: "x", Optional: true}, {Name: "y"}}},
+		{Key: "b", Handler: cmd.Handler, Args: []Arg{{Name: "x", Rest: true}, {Name: "y"}}},
+		{Key: "c", Handler: cmd.Handler, Args: []Arg{{Name: "x"}}, Flags: []Flag{{Name: "x"}}},
+		{Key: "d", Handler: cmd.Handler, Flags: []Flag{{Name: "x", Type: Int, Default: "y"}}},
+		{Key: "e", Handler: cmd.Handler, Function: func(context.Context, []string) error { return nil }},
+	} {
+		// The test fails if Add returns nil
+		if e := r.Add(c); e == nil {
+			t.Error(tserr.NilFailed("Add " + c.Key))
+		}
+	}
+}
+
+// blockingReader is an input without read deadlines, which blocks reads until lines are sent to data.
+type blockingReader struct {
+	data chan string // lines to be read
+}
+
+// newBlockingReader returns a pointer to a new blockingReader.
+func newBlockingReader() *blockingReader {
+	return &blockingReader{data: make(chan string)}
+}
+
+// Read blocks until a line is sent to data.
+func (b *blockingReader) Read(p []byte) (int, error) {
+	return copy(p, <-b.data+"\n"), nil
+}
+
+// TestRunCancel cancels the context of Run while a command is running. The test fails if the command
+// does not receive the cancellation or the exit command is not called.
+func TestRunCancel(t *testing.T) {
+	// in blocks reads until a line is sent
+	in := newBlockingReader()
+	// out holds the output
+	var out strings.Builder
+	// Create the Runner
+	r, _ := New(in, &out)
+	// started is closed when the wait command has started
+	started := make(chan struct{})
+	// Register a command waiting for cancellation and an exit command
+	r.Add(&Command{Key: "wait", Function: func(ctx context.Context, args []string) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	r.Add(&Command{Key: "stop", Function: func(ctx context.Context, args []string) error {
+		// The exit command receives a context, which is not cancelled
+		fmt.Fprintf(Output(ctx), "bye %v\n", ctx.Err())
+		return nil
+	}})
+	r.SetExit("stop")
+	// Run the Runner
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+	// Start the wait command and cancel the context
+	in.data <- "wait"
+	<-started
+	cancel()
+	// The test fails if Run does not return in time
+	select {
+	case e := <-done:
+		if e != nil {
+			t.Error(tserr.Op(&tserr.OpArgs{Op: "Run", Fn: "r", Err: e}))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal(tserr.NotAvailable(&tserr.NotAvailableArgs{S: "Run", Err: context.DeadlineExceeded}))
+	}
+	// The test fails if the command did not receive the cancellation or the exit command was not called
+	if got, want := out.String(), "context canceled\nbye <nil>\n"; !strings.HasSuffix(got, want) {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "output", Actual: got, Want: want}))
+	}
+}
+
+// TestRunGoroutines runs a Runner reading from an input without read deadlines repeatedly and cancels each Run while
+// it is blocked in a read. The test fails if the calls of Run leave goroutines behind or a line sent after a
+// cancelled Run is not executed by the next Run.
+func TestRunGoroutines(t *testing.T) {
+	in := newBlockingReader()
+	var out strings.Builder
+	r, _ := New(in, &out)
+	r.Add(&Command{Key: "echo", Function: func(ctx context.Context, args []string) error {
+		fmt.Fprintln(Output(ctx), strings.Join(args, " "))
+		return nil
+	}})
+	r.Add(&Command{Key: "stop", Function: func(ctx context.Context, args []string) error { return nil }})
+	r.SetExit("stop")
+	// run calls Run with a cancelled context
+	run := func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if e := r.Run(ctx); e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Run", Fn: "r", Err: e}))
+		}
+	}
+	// The first Run starts the reading goroutine
+	run()
+	n := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		run()
+	}
+	if m := runtime.NumGoroutine(); m > n {
+		t.Errorf("%d goroutines after 20 runs, expected %d", m, n)
+	}
+	// The next Run executes the lines read by the reading goroutine
+	done := make(chan error)
+	go func() { done <- r.Run(context.Background()) }()
+	in.data <- "echo again"
+	in.data <- "stop"
+	select {
+	case e := <-done:
+		if e != nil {
+			t.Error(tserr.Op(&tserr.OpArgs{Op: "Run", Fn: "r", Err: e}))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal(tserr.NotAvailable(&tserr.NotAvailableArgs{S: "Run", Err: context.DeadlineExceeded}))
+	}
+	if got := out.String(); got != "again\n" {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "output", Actual: got, Want: "again\n"}))
+	}
+}
+
+// TestRunPipe cancels Run reading from a pipe and runs it again on the same pipe. The test fails if the first
+// Run does not return or the second Run misses the line written to the pipe.
+func TestRunPipe(t *testing.T) {
+	// Create the pipe
+	pr, pw, e := os.Pipe()
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Pipe", Fn: "os", Err: e}))
+	}
+	defer pr.Close()
+	defer pw.Close()
+	// out holds the output
+	var out strings.Builder
+	r, _ := New(pr, &out)
+	r.Add(&Command{Key: "echo", Function: func(ctx context.Context, args []string) error {
+		fmt.Fprintln(Output(ctx), strings.Join(args, " "))
+		return nil
+	}})
+	r.Add(&Command{Key: "stop", Function: func(ctx context.Context, args []string) error { return nil }})
+	r.SetExit("stop")
+	// Cancel the first Run while it is blocked in a read
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if e = r.Run(ctx); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Run", Fn: "pipe", Err: e}))
+	}
+	// The second Run reads the line written to the pipe
+	pw.WriteString("echo again\nstop\n")
+	done := make(chan error)
+	go func() { done <- r.Run(context.Background()) }()
+	select {
+	case e = <-done:
+		if e != nil {
+			t.Error(tserr.Op(&tserr.OpArgs{Op: "Run", Fn: "pipe", Err: e}))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal(tserr.NotAvailable(&tserr.NotAvailableArgs{S: "Run", Err: context.DeadlineExceeded}))
+	}
+	if got := out.String(); got != "again\n" {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "output", Actual: got, Want: "again\n"}))
+	}
+}
+
+// TestRunSignals sends an interrupt signal to the running process. The test fails if Run does not
+// return or the exit command is not called.
+func TestRunSignals(t *testing.T) {
+	// Skip the test on platforms not supporting to send an interrupt
+	p, e := os.FindProcess(os.Getpid())
+	if e != nil || runtime.GOOS == "windows" {
+		t.Skip("sending os.Interrupt not supported")
+	}
+	// Catch os.Interrupt in the test to not terminate the process before RunSignals handles it
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+	// out holds the output
+	var out strings.Builder
+	// Create the Runner reading from a blocking input
+	r := testRunner(t, "", &out)
+	r.in = newBlockingReader()
+	// Run the Runner with signal handling
+	done := make(chan error)
+	go func() { done <- r.RunSignals(context.Background(), os.Interrupt) }()
+	// Send os.Interrupt until Run returns
+	for {
+		p.Signal(os.Interrupt)
+		select {
+		case e := <-done:
+			// The test fails if Run returns an error or the exit command was not called
+			if e != nil {
+				t.Error(tserr.Op(&tserr.OpArgs{Op: "RunSignals", Fn: "r", Err: e}))
+			}
+			if !strings.Contains(out.String(), "bye\n") {
+				t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "output", Actual: out.String(), Want: "bye"}))
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// TestRunScriptCancel runs a script with a cancelled context. The test fails if RunScript returns nil
+// or the exit command is not called.
+func TestRunScriptCancel(t *testing.T) {
+	// out holds the output
+	var out strings.Builder
+	// Create the Runner
+	r := testRunner(t, "", &out)
+	// Cancel the context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// The test fails if RunScript returns nil
+	if e := r.RunScript(ctx, strings.NewReader("echo 1\n"), "test", false); e == nil {
+		t.Error(tserr.NilFailed("RunScript"))
+	}
+	// The test fails if the exit command was not called
+	if got := out.String(); got != "bye\n" {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "output", Actual: got, Want: "bye\n"}))
+	}
+}
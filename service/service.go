@@ -0,0 +1,623 @@
+// Package service provides an embeddable http.Handler to roll dice as a service. All responses are JSON. Errors are
+// returned in the JSON format of package tserr with the HTTP status code contained in the error, e.g.,
+//
+//	{"error":{"id":2,"code":404,"message":"session 42 does not exist"}}
+//
+// The Handler serves the following endpoints:
+//
+//   - GET /roll?expr=2d6+3 or GET /roll?sides=20 rolls a dice expression or a die. With session=id, the dice are
+//     rolled with the random number generators of the session and the total is added to its history.
+//   - POST /sessions?seed=42 creates a new session, which is seeded, if seed is provided.
+//   - GET /sessions/id returns the session, GET /sessions/id/history returns its history and DELETE /sessions/id removes it.
+//   - GET /simulate?expr=2d6&n=1000 rolls a dice expression n times and returns the number of rolls of each total.
+//     With seed=42, the simulation is seeded.
+//   - GET /probability?expr=2d6 returns the probability distribution of a dice expression. With value=7, it returns
+//     the probability of a single outcome.
+//   - GET /tables/name?player=alice joins the shared table name over WebSocket. Rolls at the table are performed
+//     server-side and broadcast to all players at the table as described for Event.
+//
+// The number of dice per roll and the number of iterations per simulation are capped with SetCaps.
+//
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package service
+
+// Import standard library packages as well as lpdice, limit and tserr
+import (
+	"crypto/rand"   // rand
+	"encoding/hex"  // hex
+	"encoding/json" // json
+	"errors"        // errors
+	"fmt"           // fmt
+	"net/http"      // http
+	"sort"          // sort
+	"strconv"       // strconv
+	"strings"       // strings
+	"sync"          // sync
+
+	"github.com/thorstenrie/lpdice"       // lpdice
+	"github.com/thorstenrie/lpdice/limit" // limit
+	"github.com/thorstenrie/tserr"        // tserr
+)
+
+// maxSessions defines the maximum number of concurrent sessions, maxHistory defines
+// the maximum number of results kept in the history of a session, maxIterations
+// defines the maximum number of iterations of a simulation, defaultDice defines the
+// default number of dice per request and maxDice its maximum.
+const (
+	maxSessions   int = 10000
+	maxHistory    int = 10000
+	maxIterations int = 100000
+	defaultDice   int = 100
+	maxDice       int = 1000
+)
+
+// A session holds a die with its own random number generators, the seed of the die, if any,
+// and the history of results rolled in the session.
+type session struct {
+	mu      sync.Mutex  // mutex to enable concurrency
+	id      string      // id of the session
+	seed    *int64      // seed of the die, if any
+	die     *lpdice.Die // die providing the random number generators
+	history []int       // history of results
+}
+
+// A Handler serves dice rolls, sessions, probabilities and shared tables over HTTP. It is safe for
+// concurrent use by multiple goroutines.
+type Handler struct {
+	mu         sync.Mutex          // mutex to protect sessions, tables, caps and the rate limit
+	sessions   map[string]*session // sessions by id
+	tables     map[string]*table   // tables by name
+	dice       int                 // maximum number of dice per request
+	iterations int                 // maximum number of iterations per simulation
+	limiter    *limit.Limiter      // rate limit of messages at tables, if any
+	key        limit.KeyFunc       // client key of the rate limit
+}
+
+// NewHandler returns a pointer to a new Handler without sessions and tables.
+func NewHandler() *Handler {
+	return &Handler{sessions: make(map[string]*session), tables: make(map[string]*table), dice: defaultDice, iterations: maxIterations}
+}
+
+// SetCaps sets the per-request caps of Handler h. Requests rolling or computing the probabilities of
+// more than dice dice or simulating more than iterations rolls are rejected with status 403 Forbidden.
+// A dice cap of zero resets it to the default of 100 with a maximum of 1000. An iterations cap of zero
+// resets it to the default of 100000, which is also its maximum. It returns an error, if h is nil or a
+// cap is out of range.
+func (h *Handler) SetCaps(dice, iterations int) error {
+	// Return an error if h is nil
+	if h == nil {
+		return tserr.NilPtr()
+	}
+	// Return an error if a cap is negative
+	if dice < 0 {
+		return tserr.Higher(&tserr.HigherArgs{Var: "dice cap", Actual: int64(dice), LowerBound: 0})
+	}
+	if iterations < 0 {
+		return tserr.Higher(&tserr.HigherArgs{Var: "iterations cap", Actual: int64(iterations), LowerBound: 0})
+	}
+	// Return an error if a cap exceeds its maximum
+	if dice > maxDice {
+		return tserr.Lower(&tserr.LowerArgs{Var: "dice cap", Actual: int64(dice), HigherBound: int64(maxDice + 1)})
+	}
+	if iterations > maxIterations {
+		return tserr.Lower(&tserr.LowerArgs{Var: "iterations cap", Actual: int64(iterations), HigherBound: int64(maxIterations + 1)})
+	}
+	// Use the defaults, if a cap is zero
+	if dice == 0 {
+		dice = defaultDice
+	}
+	if iterations == 0 {
+		iterations = maxIterations
+	}
+	// Set the caps
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dice, h.iterations = dice, iterations
+	return nil
+}
+
+// SetLimiter charges each message of a player at a table with one token of Limiter l, in addition to the request
+// joining the table, which is charged by the Middleware of l, if h is served with it. The client is identified with
+// key when joining the table, so that messages are charged with the same client key as requests. If key is nil,
+// clients are identified by their IP address. Messages exceeding the rate limit are not executed and answered with
+// an error event holding the Rejection of package limit with the retry hint. If l is nil, messages are not limited.
+// It returns an error, if h is nil.
+func (h *Handler) SetLimiter(l *limit.Limiter, key limit.KeyFunc) error {
+	// Return an error if h is nil
+	if h == nil {
+		return tserr.NilPtr()
+	}
+	// Identify clients by IP address, if key is nil
+	if key == nil {
+		key = limit.ByIP
+	}
+	// Set the rate limit
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.limiter, h.key = l, key
+	return nil
+}
+
+// capDice returns an error, if dice expression x rolls more dice across all its terms than the dice cap of h.
+func (h *Handler) capDice(x *lpdice.Expr) error {
+	h.mu.Lock()
+	c := h.dice
+	h.mu.Unlock()
+	return checkDice(x, c)
+}
+
+// checkDice returns an error, if dice expression x rolls more than c dice across all its terms.
+func checkDice(x *lpdice.Expr, c int) error {
+	if n := x.Dice(); n > c {
+		return tserr.Forbidden(fmt.Sprintf("%d dice exceeding the cap of %d dice per roll", n, c))
+	}
+	return nil
+}
+
+// sessionView is the JSON representation of a session
+type sessionView struct {
+	Id      string `json:"id"`             // id of the session
+	Seed    *int64 `json:"seed,omitempty"` // seed of the session, if any
+	History []int  `json:"history"`        // history of results
+}
+
+// outcome is the JSON representation of the probability of an outcome
+type outcome struct {
+	Value       int     `json:"value"`       // outcome
+	Probability float64 `json:"probability"` // probability of the outcome
+}
+
+// distribution is the JSON representation of a probability distribution
+type distribution struct {
+	Expr     string    `json:"expr"`     // normalized dice expression
+	Outcomes []outcome `json:"outcomes"` // probability of each outcome
+}
+
+// ServeHTTP routes the request r to the endpoint and writes the JSON response to w.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Return an error if h is nil
+	if h == nil {
+		WriteError(w, tserr.NilPtr())
+		return
+	}
+	// Split the path into its segments
+	p := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	// Route the request
+	switch {
+	case len(p) == 1 && p[0] == "roll":
+		h.method(w, r, http.MethodGet, h.roll)
+	case len(p) == 1 && p[0] == "simulate":
+		h.method(w, r, http.MethodGet, h.simulate)
+	case len(p) == 1 && p[0] == "probability":
+		h.method(w, r, http.MethodGet, h.probability)
+	case len(p) == 1 && p[0] == "sessions":
+		h.method(w, r, http.MethodPost, h.create)
+	case len(p) == 2 && p[0] == "sessions" && r.Method == http.MethodDelete:
+		h.delete(w, r, p[1])
+	case len(p) == 2 && p[0] == "sessions":
+		h.method(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) { h.session(w, p[1], false) })
+	case len(p) == 3 && p[0] == "sessions" && p[2] == "history":
+		h.method(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) { h.session(w, p[1], true) })
+	case len(p) == 2 && p[0] == "tables":
+		h.join(w, r, p[1])
+	default:
+		WriteError(w, tserr.NotExistent("path "+r.URL.Path))
+	}
+}
+
+// method calls f, if the method of request r equals m. Otherwise, it writes an error.
+func (h *Handler) method(w http.ResponseWriter, r *http.Request, m string, f http.HandlerFunc) {
+	// Write an error if the method does not match
+	if r.Method != m {
+		w.Header().Set("Allow", m)
+		WriteError(w, tserr.TypeNotMatching(&tserr.TypeNotMatchingArgs{Act: "method " + r.Method, Want: m}))
+		return
+	}
+	// Call f
+	f(w, r)
+}
+
+// expr returns the dice expression of request r given by query parameter expr or sides.
+// It returns nil and an error, if none or both are provided or the expression is invalid.
+func expr(r *http.Request) (*lpdice.Expr, error) {
+	// Retrieve the query parameters
+	q := r.URL.Query()
+	x, s := q.Get("expr"), q.Get("sides")
+	// Return an error if none or both are provided
+	if (x == "") == (s == "") {
+		return nil, tserr.NotSet("either query parameter expr or sides")
+	}
+	// Use a single die with s sides
+	if s != "" {
+		if _, e := strconv.Atoi(s); e != nil {
+			return nil, invalid(tserr.TypeNotMatching(&tserr.TypeNotMatchingArgs{Act: "sides " + s, Want: "integer"}))
+		}
+		x = "d" + s
+	}
+	// Parse the expression
+	v, e := lpdice.ParseExpr(x)
+	if e != nil {
+		return nil, invalid(e)
+	}
+	return v, nil
+}
+
+// roll rolls the dice expression of request r and writes the result.
+func (h *Handler) roll(w http.ResponseWriter, r *http.Request) {
+	// Retrieve the dice expression
+	x, e := expr(r)
+	if e == nil {
+		e = h.capDice(x)
+	}
+	if e != nil {
+		WriteError(w, e)
+		return
+	}
+	// Roll the expression with its own random number generators, if no session is provided
+	id := r.URL.Query().Get("session")
+	if id == "" {
+		p, e := x.RollPool()
+		if e != nil {
+			WriteError(w, e)
+			return
+		}
+		WriteJSON(w, http.StatusOK, p)
+		return
+	}
+	// Retrieve the session
+	s, e := h.find(id)
+	if e != nil {
+		WriteError(w, e)
+		return
+	}
+	// Roll the expression with the random number generators of the session and add the total to its history
+	s.mu.Lock()
+	p, e := x.RollWith(s.die)
+	if e == nil {
+		s.history = append(s.history, p.Total)
+		if len(s.history) > maxHistory {
+			s.history = s.history[len(s.history)-maxHistory:]
+		}
+	}
+	s.mu.Unlock()
+	if e != nil {
+		WriteError(w, e)
+		return
+	}
+	WriteJSON(w, http.StatusOK, p)
+}
+
+// simulation is the JSON representation of a simulation
+type simulation struct {
+	Expr       string  `json:"expr"`       // normalized dice expression
+	Iterations int     `json:"iterations"` // number of rolls
+	Mean       float64 `json:"mean"`       // arithmetic mean of the totals
+	Outcomes   []count `json:"outcomes"`   // number of rolls of each total
+}
+
+// count is the JSON representation of the number of rolls of an outcome
+type count struct {
+	Value int `json:"value"` // outcome
+	Count int `json:"count"` // number of rolls of the outcome
+}
+
+// simulate rolls the dice expression of request r as often as given by query parameter n, by default 1000 times,
+// and writes the number of rolls of each total. The simulation is seeded with query parameter seed, if provided.
+func (h *Handler) simulate(w http.ResponseWriter, r *http.Request) {
+	// Retrieve the dice expression
+	x, e := expr(r)
+	if e == nil {
+		e = h.capDice(x)
+	}
+	if e != nil {
+		WriteError(w, e)
+		return
+	}
+	// Retrieve the number of iterations
+	q, n := r.URL.Query(), 1000
+	if v := q.Get("n"); v != "" {
+		if n, e = strconv.Atoi(v); e != nil {
+			WriteError(w, invalid(tserr.TypeNotMatching(&tserr.TypeNotMatchingArgs{Act: "n " + v, Want: "integer"})))
+			return
+		}
+	}
+	h.mu.Lock()
+	c := h.iterations
+	h.mu.Unlock()
+	if n < 1 {
+		WriteError(w, tserr.Higher(&tserr.HigherArgs{Var: "n", Actual: int64(n), LowerBound: 1}))
+		return
+	}
+	if n > c {
+		WriteError(w, tserr.Forbidden(fmt.Sprintf("%d iterations exceeding the cap of %d iterations per simulation", n, c)))
+		return
+	}
+	// Seed the expression, if requested
+	if v := q.Get("seed"); v != "" {
+		s, e := strconv.ParseInt(v, 10, 64)
+		if e != nil {
+			WriteError(w, invalid(tserr.TypeNotMatching(&tserr.TypeNotMatchingArgs{Act: "seed " + v, Want: "integer"})))
+			return
+		}
+		x.Seed(s)
+	}
+	// Roll the expression n times and count the totals
+	m, sum := make(map[int]int), 0
+	for i := 0; i < n; i++ {
+		v, e := x.Roll()
+		if e != nil {
+			WriteError(w, e)
+			return
+		}
+		m[v]++
+		sum += v
+	}
+	// Write the simulation with outcomes in ascending order
+	o := simulation{Expr: x.String(), Iterations: n, Mean: float64(sum) / float64(n)}
+	for v, k := range m {
+		o.Outcomes = append(o.Outcomes, count{v, k})
+	}
+	sort.Slice(o.Outcomes, func(i, j int) bool { return o.Outcomes[i].Value < o.Outcomes[j].Value })
+	WriteJSON(w, http.StatusOK, o)
+}
+
+// probability writes the probability distribution of the dice expression of request r or the probability
+// of a single outcome given by query parameter value.
+func (h *Handler) probability(w http.ResponseWriter, r *http.Request) {
+	// Retrieve the dice expression
+	x, e := expr(r)
+	if e == nil {
+		e = h.capDice(x)
+	}
+	if e != nil {
+		WriteError(w, e)
+		return
+	}
+	// Retrieve the probability distribution
+	d, e := x.Distribution()
+	if e != nil {
+		WriteError(w, e)
+		return
+	}
+	// Write the probability of a single outcome, if requested
+	if v := r.URL.Query().Get("value"); v != "" {
+		i, e := strconv.Atoi(v)
+		if e != nil {
+			WriteError(w, invalid(tserr.TypeNotMatching(&tserr.TypeNotMatchingArgs{Act: "value " + v, Want: "integer"})))
+			return
+		}
+		WriteJSON(w, http.StatusOK, struct {
+			Expr string `json:"expr"`
+			outcome
+		}{x.String(), outcome{i, d.Probability(i)}})
+		return
+	}
+	// Write the probability distribution
+	o := distribution{Expr: x.String()}
+	for _, v := range d.Values() {
+		o.Outcomes = append(o.Outcomes, outcome{v, d.Probability(v)})
+	}
+	WriteJSON(w, http.StatusOK, o)
+}
+
+// create creates a new session, which is seeded with query parameter seed, if provided.
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	// s holds the new session
+	s := &session{}
+	// Create the die of the session
+	var e error
+	if s.die, e = lpdice.NewD6(); e != nil {
+		WriteError(w, e)
+		return
+	}
+	// Seed the die, if a seed is provided
+	if v := r.URL.Query().Get("seed"); v != "" {
+		i, e := strconv.ParseInt(v, 10, 64)
+		if e != nil {
+			WriteError(w, invalid(tserr.TypeNotMatching(&tserr.TypeNotMatchingArgs{Act: "seed " + v, Want: "integer"})))
+			return
+		}
+		if e = s.die.Seed(i); e != nil {
+			WriteError(w, e)
+			return
+		}
+		s.seed = &i
+	}
+	// Create the id of the session
+	b := make([]byte, 16)
+	if _, e := rand.Read(b); e != nil {
+		WriteError(w, tserr.NotAvailable(&tserr.NotAvailableArgs{S: "crypto/rand", Err: e}))
+		return
+	}
+	s.id = hex.EncodeToString(b)
+	// Register the session
+	h.mu.Lock()
+	if len(h.sessions) >= maxSessions {
+		h.mu.Unlock()
+		WriteError(w, tserr.NotAvailable(&tserr.NotAvailableArgs{S: "new session", Err: fmt.Errorf("maximum of %d sessions reached", maxSessions)}))
+		return
+	}
+	h.sessions[s.id] = s
+	h.mu.Unlock()
+	// Write the new session
+	w.Header().Set("Location", "/sessions/"+s.id)
+	WriteJSON(w, http.StatusCreated, sessionView{Id: s.id, Seed: s.seed, History: []int{}})
+}
+
+// find returns the session with id. It returns nil and an error, if the session does not exist.
+func (h *Handler) find(id string) (*session, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Return the session, if it exists
+	if s, ok := h.sessions[id]; ok {
+		return s, nil
+	}
+	// Return an error otherwise
+	return nil, tserr.NotExistent("session " + id)
+}
+
+// session writes the session with id or only its history, if history is true.
+func (h *Handler) session(w http.ResponseWriter, id string, history bool) {
+	// Retrieve the session
+	s, e := h.find(id)
+	if e != nil {
+		WriteError(w, e)
+		return
+	}
+	// Copy the history
+	s.mu.Lock()
+	v := sessionView{Id: s.id, Seed: s.seed, History: append([]int{}, s.history...)}
+	s.mu.Unlock()
+	// Write the history only, if requested
+	if history {
+		WriteJSON(w, http.StatusOK, struct {
+			History []int `json:"history"`
+		}{v.History})
+		return
+	}
+	// Write the session
+	WriteJSON(w, http.StatusOK, v)
+}
+
+// delete removes the session with id.
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, id string) {
+	h.mu.Lock()
+	_, ok := h.sessions[id]
+	delete(h.sessions, id)
+	h.mu.Unlock()
+	// Write an error if the session does not exist
+	if !ok {
+		WriteError(w, tserr.NotExistent("session "+id))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// WriteJSON writes v as JSON with HTTP status code c to w.
+func WriteJSON(w http.ResponseWriter, c int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(c)
+	json.NewEncoder(w).Encode(v)
+}
+
+// An ErrorBody holds the id, HTTP status code and message of an error of package tserr. An ErrorBody of a
+// Rejection of package limit holds no id, but the retry hint in seconds.
+type ErrorBody struct {
+	Id         int    `json:"id,omitempty"`          // id of the error
+	Code       int    `json:"code"`                  // HTTP status code
+	Message    string `json:"message"`               // error message
+	RetryAfter int64  `json:"retry_after,omitempty"` // retry hint in seconds of a Rejection
+}
+
+// An apiError is the JSON representation of an error of package tserr
+type apiError struct {
+	Error ErrorBody `json:"error"` // error
+}
+
+// A badRequest is an error of a request, which cannot be parsed, e.g., an invalid dice expression or a query
+// parameter, which is not an integer. It is written with status 400 Bad Request.
+type badRequest struct {
+	err error // error of package tserr
+}
+
+// invalid returns error e of a request, which cannot be parsed, as badRequest.
+func invalid(e error) error {
+	return &badRequest{err: e}
+}
+
+// Error returns the error message of badRequest b.
+func (b *badRequest) Error() string {
+	return b.err.Error()
+}
+
+// Unwrap returns the wrapped error of badRequest b.
+func (b *badRequest) Unwrap() error {
+	return b.err
+}
+
+// WriteError writes error e to w in the JSON format of package tserr with the HTTP status code contained in e,
+// or 400 Bad Request, if the request cannot be parsed. Errors of package tserr wrapping other errors of package
+// tserr are not valid JSON. Therefore, WriteError rebuilds the JSON representation from the id, code and message
+// of e and replaces wrapped errors by their messages. If e is not an error of package tserr, it is wrapped in a
+// tserr error.
+func WriteError(w http.ResponseWriter, e error) {
+	// Return an error if e is nil
+	if e == nil {
+		e = tserr.NilPtr()
+	}
+	// Write the error
+	a := apiError{Error: *body(e)}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	WriteJSON(w, a.Error.Code, a)
+}
+
+// StatusCode returns the HTTP status code contained in error e of package tserr, or 400, if e is an error of
+// a request, which cannot be parsed. It returns 500, if e is nil or not an error of package tserr.
+func StatusCode(e error) int {
+	// Return 500 if e is not a tserr error
+	a, ok := parse(e)
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	// Return the status code
+	return a.Error.Code
+}
+
+// parse returns the id, code and message of error e of package tserr and true. The code of errors of requests,
+// which cannot be parsed, is 400. It returns false, if e is nil, cannot be decoded as described for decode or does
+// not contain a valid HTTP error status code.
+func parse(e error) (*apiError, bool) {
+	// Return false if e is nil
+	if e == nil {
+		return nil, false
+	}
+	// Decode the error
+	a, ok := decode(e.Error())
+	if !ok {
+		return nil, false
+	}
+	// Use 400 for errors of requests, which cannot be parsed
+	var b *badRequest
+	if errors.As(e, &b) {
+		a.Error.Code = http.StatusBadRequest
+	}
+	// Return the decoded error
+	return a, true
+}
+
+// decode decodes the JSON representation s of an error of package tserr with json.Unmarshal. Package tserr does not
+// escape quotes in messages, e.g., of wrapped errors of package tserr, so that s may not be valid JSON. In this case,
+// a wrapped error in the message is replaced by its message, the message is escaped and s is decoded again. It
+// returns false, if s cannot be decoded or does not contain a valid HTTP error status code.
+func decode(s string) (*apiError, bool) {
+	// a holds the decoded error
+	a := &apiError{}
+	if json.Unmarshal([]byte(s), a) != nil {
+		// Return false if s is not an error of package tserr
+		const key, suffix = `,"message":"`, `"}}`
+		h, m, ok := strings.Cut(s, key)
+		if !ok || !strings.HasSuffix(m, suffix) {
+			return nil, false
+		}
+		m = strings.TrimSuffix(m, suffix)
+		// Replace a wrapped error by its message, which ends with the message of the error
+		if i := strings.Index(m, `{"error":{"id":`); i >= 0 {
+			if w, ok := decode(m[i:]); ok {
+				m = m[:i] + w.Error.Message
+			}
+		}
+		// Decode the error with the escaped message
+		q, _ := json.Marshal(m)
+		if a = (&apiError{}); json.Unmarshal([]byte(h+`,"message":`+string(q)+`}}`), a) != nil {
+			return nil, false
+		}
+	}
+	// Return false if the code is not an HTTP error status code
+	if a.Error.Code < 400 || a.Error.Code > 599 {
+		return nil, false
+	}
+	// Return the decoded error
+	return a, true
+}
@@ -0,0 +1,334 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package service
+
+// Import standard library packages as well as lpdice, limit, tserr and tsfio
+import (
+	"encoding/json" // json
+	"errors"        // errors
+	"net/http"      // http
+	"strconv"       // strconv
+	"sync"          // sync
+	"time"          // time
+
+	"github.com/thorstenrie/lpdice"       // lpdice
+	"github.com/thorstenrie/lpdice/limit" // limit
+	"github.com/thorstenrie/tserr"        // tserr
+	"github.com/thorstenrie/tsfio"        // tsfio
+)
+
+// maxTables defines the maximum number of concurrent tables, maxEvents the number of recent rolls sent to
+// players joining a table, maxName the maximum length of table and player names and maxQueue the number
+// of events queued for a player. Players not keeping up with the events of a table are disconnected.
+const (
+	maxTables int = 1000
+	maxEvents int = 50
+	maxName   int = 64
+	maxQueue  int = 64
+)
+
+// Types of events
+const (
+	EventJoin    string = "join"    // a player joined the table
+	EventLeave   string = "leave"   // a player left the table
+	EventRoll    string = "roll"    // a player rolled at the table
+	EventHistory string = "history" // recent rolls sent to a joining player
+	EventError   string = "error"   // a request of the player failed
+)
+
+// An Event is sent as JSON text message to the players at a table. A roll is broadcast to all players at the
+// table with the name of the rolling player and the result. A player joining the table receives the recent
+// rolls as Events of an event of type history. Errors are only sent to the player causing the error.
+// Players request a roll by sending a text message {"type":"roll","expr":"2d6+3"}.
+type Event struct {
+	Type   string             `json:"type"`             // type of the event
+	Table  string             `json:"table"`            // name of the table
+	Player string             `json:"player,omitempty"` // name of the player
+	Result *lpdice.PoolResult `json:"result,omitempty"` // result of a roll
+	Events []Event            `json:"events,omitempty"` // recent rolls
+	Error  *ErrorBody         `json:"error,omitempty"`  // error
+	Time   time.Time          `json:"time"`             // time of the event
+}
+
+// A rollRequest is a message of a player requesting a roll
+type rollRequest struct {
+	Type string `json:"type"` // type of the request
+	Expr string `json:"expr"` // dice expression to be rolled
+}
+
+// A member is a player at a table. Events are queued in send.
+type member struct {
+	player string      // name of the player
+	send   chan []byte // queued events
+}
+
+// A table holds a die performing all rolls at the table, the players at the table and the recent rolls.
+// The players and the recent rolls are protected by the mutex of the Handler, the die by the mutex of the table.
+type table struct {
+	mu      sync.Mutex       // mutex to serialize rolls at the table
+	name    string           // name of the table
+	die     *lpdice.Die      // die providing the random number generators
+	members map[*member]bool // players at the table
+	history []Event          // recent rolls
+}
+
+// name returns an error, if name n of v is empty, contains non-printable runes or is too long.
+func name(v, n string) error {
+	// Return an error if n is empty
+	if n == "" {
+		return tserr.Empty(v)
+	}
+	// Return an error if n contains non-printable runes
+	if n != tsfio.Printable(n) {
+		return tserr.NonPrintable(v)
+	}
+	// Return an error if n is too long
+	if len(n) > maxName {
+		return tserr.Lower(&tserr.LowerArgs{Var: "length of " + v, Actual: int64(len(n)), HigherBound: int64(maxName + 1)})
+	}
+	// Return nil
+	return nil
+}
+
+// join lets the player given by query parameter player join table n over WebSocket. If the table does not
+// exist, it is created with a die seeded with query parameter seed, if provided. A seed for an existing table is
+// rejected with an error event. If h has a rate limit, each
+// message is charged with the client key of request r.
+func (h *Handler) join(w http.ResponseWriter, r *http.Request, n string) {
+	// Validate the names of the table and the player
+	p := r.URL.Query().Get("player")
+	for _, e := range []error{name("table name", n), name("player name", p)} {
+		if e != nil {
+			WriteError(w, e)
+			return
+		}
+	}
+	// Parse the seed, if provided
+	var seed *int64
+	if v := r.URL.Query().Get("seed"); v != "" {
+		i, e := strconv.ParseInt(v, 10, 64)
+		if e != nil {
+			WriteError(w, invalid(tserr.TypeNotMatching(&tserr.TypeNotMatchingArgs{Act: "seed " + v, Want: "integer"})))
+			return
+		}
+		seed = &i
+	}
+	// Retrieve the rate limit and the client key, if any
+	h.mu.Lock()
+	l, k := h.limiter, ""
+	if l != nil {
+		k = h.key(r)
+	}
+	h.mu.Unlock()
+	// Upgrade to WebSocket
+	c, e := upgrade(w, r)
+	if e != nil {
+		return
+	}
+	// Send queued events to the player until the queue is closed
+	m := &member{player: p, send: make(chan []byte, maxQueue)}
+	go func() {
+		for b := range m.send {
+			if c.write(opText, b) != nil {
+				break
+			}
+		}
+		c.close()
+	}()
+	// Join the table
+	t, e := h.enter(n, seed, m)
+	if e != nil {
+		m.send <- event(Event{Type: EventError, Table: n, Player: p, Error: body(e), Time: time.Now()})
+		close(m.send)
+		return
+	}
+	// Roll requested expressions until the player leaves
+	for {
+		b, e := c.readMessage()
+		if e != nil {
+			break
+		}
+		// Reject the message with the retry hint, if the client exceeds its rate limit
+		if l != nil {
+			if ok, d := l.Allow(k); !ok {
+				h.fail(t, m, limit.Reject(d))
+				continue
+			}
+		}
+		h.rollAt(t, m, b)
+	}
+	// Leave the table
+	h.leave(t, m)
+}
+
+// enter adds member m to table n. If the table does not exist, it is created and seeded, if seed is not nil.
+// The member receives the recent rolls and all players at the table are notified. It returns an error, if seed
+// is not nil and the table exists.
+func (h *Handler) enter(n string, seed *int64, m *member) (*table, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Return an error if a seed is given for an existing table, whose die is already seeded or rolling
+	t, ok := h.tables[n]
+	if ok && seed != nil {
+		return nil, tserr.Forbidden("seed of existing table " + n)
+	}
+	// Create the table, if it does not exist
+	if !ok {
+		// Return an error if the maximum number of tables is reached
+		if len(h.tables) >= maxTables {
+			return nil, tserr.Lower(&tserr.LowerArgs{Var: "number of tables", Actual: int64(len(h.tables) + 1), HigherBound: int64(maxTables + 1)})
+		}
+		d, e := lpdice.NewD6()
+		if e != nil {
+			return nil, e
+		}
+		if seed != nil {
+			if e = d.Seed(*seed); e != nil {
+				return nil, e
+			}
+		}
+		t = &table{name: n, die: d, members: make(map[*member]bool)}
+		h.tables[n] = t
+	}
+	// Send the recent rolls to the member and notify all players
+	m.send <- event(Event{Type: EventHistory, Table: n, Player: m.player, Events: append([]Event{}, t.history...), Time: time.Now()})
+	t.members[m] = true
+	t.broadcast(Event{Type: EventJoin, Table: n, Player: m.player, Time: time.Now()})
+	// Return the table
+	return t, nil
+}
+
+// leave removes member m from table t and notifies the remaining players. Empty tables are removed.
+func (h *Handler) leave(t *table, m *member) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Remove m and notify the remaining players, if m has not been removed yet
+	if t.members[m] {
+		delete(t.members, m)
+		close(m.send)
+		t.broadcast(Event{Type: EventLeave, Table: t.name, Player: m.player, Time: time.Now()})
+	}
+	// Remove the table if it is empty
+	if len(t.members) == 0 && h.tables[t.name] == t {
+		delete(h.tables, t.name)
+	}
+}
+
+// rollAt rolls the dice expression requested by member m with message b at table t and broadcasts
+// the result. If the request fails, the error is only sent to m. The roll is performed under the lock
+// of t only, so that rolls at other tables and requests to h are not blocked.
+func (h *Handler) rollAt(t *table, m *member, b []byte) {
+	// Read the dice cap
+	h.mu.Lock()
+	c := h.dice
+	h.mu.Unlock()
+	// Parse the request and roll the dice expression
+	var (
+		q rollRequest
+		p *lpdice.PoolResult
+	)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e := json.Unmarshal(b, &q)
+	if e != nil {
+		e = invalid(tserr.Op(&tserr.OpArgs{Op: "decode", Fn: "request", Err: e}))
+	} else if q.Type != EventRoll {
+		e = tserr.NotExistent("request type " + q.Type)
+	} else if x, err := lpdice.ParseExpr(q.Expr); err != nil {
+		e = invalid(err)
+	} else if err = checkDice(x, c); err != nil {
+		e = err
+	} else {
+		p, e = x.RollWith(t.die)
+	}
+	// Keep the lock of t until the roll is broadcast, so that rolls are broadcast in the order they are rolled
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Return if m already has been removed
+	if !t.members[m] {
+		return
+	}
+	// Send the error to m, if any
+	if e != nil {
+		t.send(m, event(Event{Type: EventError, Table: t.name, Player: m.player, Error: body(e), Time: time.Now()}))
+		return
+	}
+	// Add the roll to the recent rolls and broadcast it
+	ev := Event{Type: EventRoll, Table: t.name, Player: m.player, Result: p, Time: time.Now()}
+	if t.history = append(t.history, ev); len(t.history) > maxEvents {
+		t.history = t.history[len(t.history)-maxEvents:]
+	}
+	t.broadcast(ev)
+}
+
+// fail sends an error event of error e to member m at table t, if m has not been removed yet.
+func (h *Handler) fail(t *table, m *member, e error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t.members[m] {
+		t.send(m, event(Event{Type: EventError, Table: t.name, Player: m.player, Error: body(e), Time: time.Now()}))
+	}
+}
+
+// broadcast sends event ev to all players at table t.
+func (t *table) broadcast(ev Event) {
+	b := event(ev)
+	for m := range t.members {
+		t.send(m, b)
+	}
+}
+
+// send queues event b for member m. If the queue of m is full, m is removed from table t.
+func (t *table) send(m *member, b []byte) {
+	select {
+	case m.send <- b:
+	default:
+		delete(t.members, m)
+		close(m.send)
+	}
+}
+
+// Close disconnects all players from all tables of Handler h. Close is meant to be registered
+// with http.Server.RegisterOnShutdown, because shutting down the server does not close WebSockets.
+func (h *Handler) Close() {
+	// Return if h is nil
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Remove all players and tables
+	for n, t := range h.tables {
+		for m := range t.members {
+			delete(t.members, m)
+			close(m.send)
+		}
+		delete(h.tables, n)
+	}
+}
+
+// event returns the JSON encoding of event ev.
+func event(ev Event) []byte {
+	b, _ := json.Marshal(ev)
+	return b
+}
+
+// body returns the ErrorBody of error e.
+func body(e error) *ErrorBody {
+	// Return the code, message and retry hint of a Rejection of package limit
+	var r *limit.Rejection
+	if errors.As(e, &r) {
+		return &ErrorBody{Code: r.Code, Message: r.Message, RetryAfter: r.RetryAfter}
+	}
+	// Wrap errors not provided by tserr
+	a, ok := parse(e)
+	if !ok {
+		a, ok = parse(tserr.Op(&tserr.OpArgs{Op: "request", Fn: "service", Err: e}))
+	}
+	// Fall back to 500, if the error cannot be decoded
+	if !ok {
+		return &ErrorBody{Code: http.StatusInternalServerError, Message: e.Error()}
+	}
+	return &a.Error
+}
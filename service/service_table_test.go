@@ -0,0 +1,245 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package service
+
+// Import standard library packages as well as lpdice, limit and tserr
+import (
+	"bufio"             // bufio
+	"bytes"             // bytes
+	"encoding/json"     // json
+	"net"               // net
+	"net/http"          // http
+	"net/http/httptest" // httptest
+	"reflect"           // reflect
+	"strings"           // strings
+	"testing"           // testing
+	"time"              // time
+
+	"github.com/thorstenrie/lpdice"       // lpdice
+	"github.com/thorstenrie/lpdice/limit" // limit
+	"github.com/thorstenrie/tserr"        // tserr
+)
+
+// A client is a WebSocket client used for testing
+type client struct {
+	t *testing.T    // test
+	c net.Conn      // connection
+	r *bufio.Reader // buffered reader of the connection
+}
+
+// dial connects a WebSocket client to path p of server s. The test fails if the handshake fails.
+func dial(t *testing.T, s *httptest.Server, p string) *client {
+	t.Helper()
+	// Connect to the server
+	c, e := net.Dial("tcp", strings.TrimPrefix(s.URL, "http://"))
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Dial", Fn: s.URL, Err: e}))
+	}
+	t.Cleanup(func() { c.Close() })
+	// Send the opening handshake
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	if _, e = c.Write([]byte("GET " + p + " HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\n\r\n")); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Write", Fn: "handshake", Err: e}))
+	}
+	// Validate the response
+	r := bufio.NewReader(c)
+	res, e := http.ReadResponse(r, nil)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "ReadResponse", Fn: "handshake", Err: e}))
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols || res.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("invalid handshake response %v %v", res.Status, res.Header)
+	}
+	// Return the client
+	return &client{t: t, c: c, r: r}
+}
+
+// roll requests a roll of dice expression x.
+func (c *client) roll(x string) {
+	c.t.Helper()
+	b, _ := json.Marshal(rollRequest{Type: EventRoll, Expr: x})
+	if e := writeFrame(c.c, opText, b, true); e != nil {
+		c.t.Fatal(tserr.Op(&tserr.OpArgs{Op: "writeFrame", Fn: "roll", Err: e}))
+	}
+}
+
+// next returns the next event. The test fails if no event is received within a second
+// or the event does not match type typ.
+func (c *client) next(typ string) Event {
+	c.t.Helper()
+	c.c.SetReadDeadline(time.Now().Add(time.Second))
+	// Read the next frame
+	_, op, p, e := readFrame(c.r, false)
+	if e != nil {
+		c.t.Fatal(tserr.Op(&tserr.OpArgs{Op: "readFrame", Fn: typ, Err: e}))
+	}
+	// Decode the event
+	var ev Event
+	if e = json.Unmarshal(p, &ev); e != nil || op != opText {
+		c.t.Fatalf("invalid frame %v %s", op, p)
+	}
+	// The test fails if the type does not match
+	if ev.Type != typ {
+		c.t.Fatal(tserr.EqualStr(&tserr.EqualStrArgs{Var: "event type", Actual: ev.Type, Want: typ}))
+	}
+	// Return the event
+	return ev
+}
+
+// TestTable lets two players roll at a seeded table. The test fails if the players do not receive the same
+// rolls, the results do not match a die with the same seed or a late joiner does not receive recent rolls.
+func TestTable(t *testing.T) {
+	// Start the server
+	h := NewHandler()
+	s := httptest.NewServer(h)
+	defer s.Close()
+	// d rolls the expected results
+	d, _ := lpdice.NewD6()
+	d.Seed(7)
+	x, _ := lpdice.ParseExpr("3d6+1")
+	// Alice joins the table and rolls twice
+	a := dial(t, s, "/tables/tavern?player=alice&seed=7")
+	if ev := a.next(EventHistory); len(ev.Events) != 0 || ev.Table != "tavern" {
+		t.Errorf("invalid history %+v", ev)
+	}
+	a.next(EventJoin)
+	var rolls []Event
+	for i := 0; i < 2; i++ {
+		a.roll("3d6+1")
+		ev := a.next(EventRoll)
+		want, _ := x.RollWith(d)
+		if ev.Player != "alice" || !reflect.DeepEqual(ev.Result, want) {
+			t.Errorf("roll %+v does not match expected %+v", ev.Result, want)
+		}
+		rolls = append(rolls, ev)
+	}
+	// Mallory cannot reseed the existing table
+	c := dial(t, s, "/tables/tavern?player=mallory&seed=1")
+	if ev := c.next(EventError); ev.Error == nil || ev.Error.Code != http.StatusForbidden {
+		t.Errorf("invalid error %+v", ev)
+	}
+	// Bob joins the table and receives the recent rolls
+	b := dial(t, s, "/tables/tavern?player=bob")
+	if ev := b.next(EventHistory); len(ev.Events) != 2 || !reflect.DeepEqual(ev.Events[1].Result, rolls[1].Result) {
+		t.Errorf("invalid history %+v", ev)
+	}
+	b.next(EventJoin)
+	if ev := a.next(EventJoin); ev.Player != "bob" {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "player", Actual: ev.Player, Want: "bob"}))
+	}
+	// Bob rolls and both players receive the same roll
+	b.roll("1d20")
+	ea, eb := a.next(EventRoll), b.next(EventRoll)
+	if ea.Player != "bob" || !reflect.DeepEqual(ea, eb) {
+		t.Errorf("players received different rolls %+v, %+v", ea, eb)
+	}
+	// Only Bob receives the error of an invalid request
+	b.roll("2x6")
+	if ev := b.next(EventError); ev.Error == nil || ev.Error.Code != http.StatusBadRequest || strings.Contains(ev.Error.Message, `{"error"`) {
+		t.Errorf("invalid error %+v", ev)
+	}
+	// Bob leaves and Alice is notified
+	writeFrame(b.c, opClose, nil, true)
+	if ev := a.next(EventLeave); ev.Player != "bob" {
+		t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "player", Actual: ev.Player, Want: "bob"}))
+	}
+	// Close disconnects Alice
+	h.Close()
+	a.c.SetReadDeadline(time.Now().Add(time.Second))
+	if _, op, _, e := readFrame(a.r, false); e != nil || op != opClose {
+		t.Errorf("expected close frame, got opcode %v and error %v", op, e)
+	}
+}
+
+// TestTableLimit rolls at a table of a Handler with a rate limit of two tokens. The test fails if the join or the
+// first message is not charged or the rejected message is not answered with an error event with retry hint.
+func TestTableLimit(t *testing.T) {
+	// Start the server with a rate limit of two tokens, which are refilled every hundred seconds
+	h := NewHandler()
+	l, _ := limit.NewLimiter(0.01, 2)
+	if e := h.SetLimiter(l, nil); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "SetLimiter", Fn: "handler", Err: e}))
+	}
+	s := httptest.NewServer(l.Middleware(nil, h))
+	defer s.Close()
+	// Joining takes the first token and the first roll the second token
+	a := dial(t, s, "/tables/tavern?player=alice")
+	a.next(EventHistory)
+	a.next(EventJoin)
+	a.roll("1d6")
+	a.next(EventRoll)
+	// The second roll is rejected with the retry hint
+	a.roll("1d6")
+	ev := a.next(EventError)
+	if ev.Error == nil || ev.Error.Code != http.StatusTooManyRequests || ev.Error.Id != 0 || ev.Error.RetryAfter < 1 {
+		t.Errorf("invalid error %+v", ev.Error)
+	}
+}
+
+// TestTableErrors requests tables with invalid requests. The test fails if the status code does not match.
+func TestTableErrors(t *testing.T) {
+	// Start the server
+	s := httptest.NewServer(NewHandler())
+	defer s.Close()
+	// tc holds the invalid requests and the expected status codes
+	tc := []struct {
+		p string
+		c int
+	}{
+		{"/tables/tavern?player=alice", http.StatusNotFound},
+		{"/tables/tavern", http.StatusBadRequest},
+		{"/tables/tavern?player=" + strings.Repeat("a", maxName+1), http.StatusInternalServerError},
+		{"/tables/tavern?player=alice&seed=x", http.StatusBadRequest},
+	}
+	// Iterate all requests
+	for _, c := range tc {
+		var a apiError
+		request(t, s, http.MethodGet, c.p, c.c, &a)
+	}
+}
+
+// TestFrame writes and reads frames of different sizes. The test fails if the payload differs.
+func TestFrame(t *testing.T) {
+	// Iterate payload sizes covering all length encodings
+	for _, n := range []int{0, 125, 126, 1000, maxMessage} {
+		for _, mask := range []bool{false, true} {
+			// Write the frame
+			var b bytes.Buffer
+			p := bytes.Repeat([]byte{'x'}, n)
+			if e := writeFrame(&b, opText, p, mask); e != nil {
+				t.Fatal(tserr.Op(&tserr.OpArgs{Op: "writeFrame", Fn: "buffer", Err: e}))
+			}
+			// Read the frame
+			fin, op, q, e := readFrame(&b, mask)
+			if e != nil {
+				t.Fatal(tserr.Op(&tserr.OpArgs{Op: "readFrame", Fn: "buffer", Err: e}))
+			}
+			// The test fails if the frame differs
+			if !fin || op != opText || !bytes.Equal(p, q) {
+				t.Errorf("frame of size %d differs", n)
+			}
+		}
+	}
+	// Reading an unmasked frame from a client fails
+	var b bytes.Buffer
+	writeFrame(&b, opText, []byte("x"), false)
+	if _, _, _, e := readFrame(&b, true); e == nil {
+		t.Error(tserr.NilFailed("readFrame"))
+	}
+	// Reading a control frame with a payload of more than 125 bytes fails
+	b.Reset()
+	writeFrame(&b, opPing, bytes.Repeat([]byte{'x'}, maxControl+1), true)
+	if _, _, _, e := readFrame(&b, true); e == nil {
+		t.Error(tserr.NilFailed("readFrame"))
+	}
+	// Reading a fragmented control frame fails
+	b.Reset()
+	writeFrame(&b, opPing, []byte("x"), true)
+	f := b.Bytes()
+	f[0] &^= 0x80
+	if _, _, _, e := readFrame(&b, true); e == nil {
+		t.Error(tserr.NilFailed("readFrame"))
+	}
+}
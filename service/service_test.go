@@ -0,0 +1,276 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package service
+
+// Import standard library packages as well as lpdice and tserr
+import (
+	"encoding/json"     // json
+	"errors"            // errors
+	"net/http"          // http
+	"net/http/httptest" // httptest
+	"slices"            // slices
+	"strings"           // strings
+	"testing"           // testing
+
+	"github.com/thorstenrie/lpdice" // lpdice
+	"github.com/thorstenrie/tserr"  // tserr
+)
+
+// request sends a request with method m to path p of server s and decodes the JSON response into v, if v
+// is not nil. The test fails if the request fails or the status code does not equal c.
+func request(t *testing.T, s *httptest.Server, m, p string, c int, v any) {
+	t.Helper()
+	// Create the request
+	r, e := http.NewRequest(m, s.URL+p, nil)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "NewRequest", Fn: p, Err: e}))
+	}
+	// Send the request
+	res, e := s.Client().Do(r)
+	if e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: m, Fn: p, Err: e}))
+	}
+	defer res.Body.Close()
+	// The test fails if the status code does not match
+	if res.StatusCode != c {
+		t.Errorf("%v %v: %v", m, p, tserr.Equal(&tserr.EqualArgs{Var: "status code", Actual: int64(res.StatusCode), Want: int64(c)}))
+	}
+	// The test fails if the response is not JSON
+	if res.StatusCode != http.StatusNoContent && res.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("%v %v: %v", m, p, tserr.EqualStr(&tserr.EqualStrArgs{Var: "Content-Type", Actual: res.Header.Get("Content-Type"), Want: "application/json"}))
+	}
+	// Decode the response
+	if v != nil {
+		if e = json.NewDecoder(res.Body).Decode(v); e != nil {
+			t.Fatal(tserr.Op(&tserr.OpArgs{Op: "Decode", Fn: p, Err: e}))
+		}
+	}
+}
+
+// TestRoll rolls a die and a dice expression. The test fails if the status code or the result is invalid.
+func TestRoll(t *testing.T) {
+	// Start the server
+	s := httptest.NewServer(NewHandler())
+	defer s.Close()
+	// Roll a d20
+	var p lpdice.PoolResult
+	request(t, s, http.MethodGet, "/roll?sides=20", http.StatusOK, &p)
+	if p.Expr != "1d20" || len(p.Rolls) != 1 || p.Total < 1 || p.Total > 20 {
+		t.Errorf("invalid result of d20: %+v", p)
+	}
+	// Roll an expression
+	p = lpdice.PoolResult{}
+	request(t, s, http.MethodGet, "/roll?expr=2d6%2B3", http.StatusOK, &p)
+	if p.Expr != "2d6+3" || len(p.Rolls) != 2 || p.Modifier != 3 || p.Total != p.Rolls[0].Value+p.Rolls[1].Value+3 {
+		t.Errorf("invalid result of 2d6+3: %+v", p)
+	}
+}
+
+// TestSessions creates two sessions with the same seed and rolls with both sessions. The test fails
+// if the histories of both sessions differ or the session cannot be retrieved or deleted.
+func TestSessions(t *testing.T) {
+	// Start the server
+	s := httptest.NewServer(NewHandler())
+	defer s.Close()
+	// Create two sessions with the same seed
+	var a, b sessionView
+	request(t, s, http.MethodPost, "/sessions?seed=42", http.StatusCreated, &a)
+	request(t, s, http.MethodPost, "/sessions?seed=42", http.StatusCreated, &b)
+	if a.Id == b.Id || a.Seed == nil || *a.Seed != 42 {
+		t.Fatalf("invalid sessions: %+v, %+v", a, b)
+	}
+	// Roll with both sessions
+	for i := 0; i < 10; i++ {
+		request(t, s, http.MethodGet, "/roll?expr=3d6&session="+a.Id, http.StatusOK, nil)
+		request(t, s, http.MethodGet, "/roll?expr=3d6&session="+b.Id, http.StatusOK, nil)
+	}
+	// Retrieve the history of both sessions
+	var ha, hb struct {
+		History []int `json:"history"`
+	}
+	request(t, s, http.MethodGet, "/sessions/"+a.Id+"/history", http.StatusOK, &ha)
+	request(t, s, http.MethodGet, "/sessions/"+b.Id+"/history", http.StatusOK, &hb)
+	// The test fails if the histories differ
+	if len(ha.History) != 10 || !slices.Equal(ha.History, hb.History) {
+		t.Errorf("histories of sessions with same seed differ: %v, %v", ha.History, hb.History)
+	}
+	// Retrieve the session
+	var v sessionView
+	request(t, s, http.MethodGet, "/sessions/"+a.Id, http.StatusOK, &v)
+	if v.Id != a.Id || !slices.Equal(v.History, ha.History) {
+		t.Errorf("invalid session: %+v", v)
+	}
+	// Delete the session
+	request(t, s, http.MethodDelete, "/sessions/"+a.Id, http.StatusNoContent, nil)
+	request(t, s, http.MethodGet, "/sessions/"+a.Id, http.StatusNotFound, nil)
+}
+
+// TestProbability retrieves the distribution of 2d6 and the probability of 7. The test
+// fails if the probabilities are invalid.
+func TestProbability(t *testing.T) {
+	// Start the server
+	s := httptest.NewServer(NewHandler())
+	defer s.Close()
+	// Retrieve the distribution
+	var d distribution
+	request(t, s, http.MethodGet, "/probability?expr=2d6", http.StatusOK, &d)
+	if d.Expr != "2d6" || len(d.Outcomes) != 11 || d.Outcomes[0].Value != 2 || d.Outcomes[10].Value != 12 {
+		t.Fatalf("invalid distribution: %+v", d)
+	}
+	// Retrieve the probability of 7
+	var o outcome
+	request(t, s, http.MethodGet, "/probability?expr=2d6&value=7", http.StatusOK, &o)
+	if o.Value != 7 || o.Probability != 1.0/6.0 || o.Probability != d.Outcomes[5].Probability {
+		t.Errorf("invalid probability of 7: %+v", o)
+	}
+}
+
+// TestSimulate simulates 2d6 twice with the same seed. The test fails if the simulations differ
+// or the number of rolls or the mean is invalid.
+func TestSimulate(t *testing.T) {
+	// Start the server
+	s := httptest.NewServer(NewHandler())
+	defer s.Close()
+	// Simulate 2d6 twice with the same seed
+	var a, b simulation
+	request(t, s, http.MethodGet, "/simulate?expr=2d6&n=500&seed=7", http.StatusOK, &a)
+	request(t, s, http.MethodGet, "/simulate?expr=2d6&n=500&seed=7", http.StatusOK, &b)
+	// The test fails if the number of rolls does not equal n
+	n := 0
+	for _, o := range a.Outcomes {
+		if o.Value < 2 || o.Value > 12 {
+			t.Errorf("invalid outcome: %+v", o)
+		}
+		n += o.Count
+	}
+	if a.Expr != "2d6" || a.Iterations != 500 || n != 500 || a.Mean < 2 || a.Mean > 12 {
+		t.Errorf("invalid simulation: %+v", a)
+	}
+	// The test fails if the seeded simulations differ
+	if !slices.Equal(a.Outcomes, b.Outcomes) {
+		t.Errorf("seeded simulations differ: %+v, %+v", a.Outcomes, b.Outcomes)
+	}
+}
+
+// TestCaps sets the per-request caps and sends requests within and exceeding them. The test fails
+// if the status codes do not match or invalid caps do not return an error.
+func TestCaps(t *testing.T) {
+	// Set the caps
+	h := NewHandler()
+	if e := h.SetCaps(3, 100); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "SetCaps", Fn: "Handler", Err: e}))
+	}
+	// Start the server
+	s := httptest.NewServer(h)
+	defer s.Close()
+	// tc holds the requests and the expected status codes
+	tc := []struct {
+		p string
+		c int
+	}{
+		{"/roll?expr=2d6%2B1d4%2B5", http.StatusOK},
+		{"/roll?expr=2d6%2B2d4", http.StatusForbidden},
+		{"/simulate?expr=3d6&n=100", http.StatusOK},
+		{"/simulate?expr=4d6&n=10", http.StatusForbidden},
+		{"/simulate?expr=d6&n=101", http.StatusForbidden},
+		{"/simulate?expr=d6&n=0", StatusCode(tserr.Higher(&tserr.HigherArgs{}))},
+		{"/probability?expr=1d6%2B2d8", http.StatusOK},
+		{"/probability?expr=2d6%2B1d8%2B1d4", http.StatusForbidden},
+	}
+	// Iterate all requests
+	for _, c := range tc {
+		request(t, s, http.MethodGet, c.p, c.c, nil)
+	}
+	// Rolls at a table are capped
+	p := dial(t, s, "/tables/capped?player=alice")
+	p.next(EventHistory)
+	p.next(EventJoin)
+	p.roll("4d6")
+	if ev := p.next(EventError); ev.Error == nil || ev.Error.Code != http.StatusForbidden {
+		t.Errorf("invalid error event: %+v", ev)
+	}
+	// The test fails if invalid caps do not return an error
+	for _, c := range [][2]int{{-1, 0}, {0, -1}, {maxDice + 1, 0}, {0, maxIterations + 1}} {
+		if e := h.SetCaps(c[0], c[1]); e == nil {
+			t.Error(tserr.NilFailed("SetCaps"))
+		}
+	}
+	if e := (*Handler)(nil).SetCaps(0, 0); e == nil {
+		t.Error(tserr.NilFailed("SetCaps"))
+	}
+	// A dice cap of zero resets it to the default
+	if e := h.SetCaps(0, 0); e != nil {
+		t.Fatal(tserr.Op(&tserr.OpArgs{Op: "SetCaps", Fn: "Handler", Err: e}))
+	}
+	request(t, s, http.MethodGet, "/probability?expr=50d6%2B50d4", http.StatusOK, nil)
+	request(t, s, http.MethodGet, "/probability?expr=50d6%2B51d4", http.StatusForbidden, nil)
+}
+
+// TestErrors sends invalid requests. The test fails if the status code does not match
+// the tserr error, the error is not valid JSON or the message does not match.
+func TestErrors(t *testing.T) {
+	// Start the server
+	s := httptest.NewServer(NewHandler())
+	defer s.Close()
+	// tc holds the invalid requests and the expected status codes
+	tc := []struct {
+		m, p string
+		c    int
+		msg  string
+	}{
+		{http.MethodGet, "/unknown", http.StatusNotFound, ""},
+		{http.MethodPost, "/roll?sides=6", http.StatusMethodNotAllowed, ""},
+		{http.MethodGet, "/roll", http.StatusNotFound, ""},
+		{http.MethodGet, "/roll?sides=six", http.StatusBadRequest, "sides six does not match type integer"},
+		{http.MethodGet, "/roll?expr=2x6", http.StatusBadRequest, `check 2x6 failed: Atoi 2x6 failed: strconv.Atoi: parsing "2x6": invalid syntax`},
+		{http.MethodGet, "/roll?expr=2000d6", http.StatusBadRequest, "check 2000d6 failed: value of number of dice is 2000, but expected to be lower than 1001"},
+		{http.MethodGet, "/roll?sides=6&session=none", http.StatusNotFound, ""},
+		{http.MethodPost, "/sessions?seed=abc", http.StatusBadRequest, "seed abc does not match type integer"},
+		{http.MethodDelete, "/sessions/none", http.StatusNotFound, ""},
+	}
+	// Iterate all requests
+	for _, c := range tc {
+		// a holds the error
+		var a apiError
+		request(t, s, c.m, c.p, c.c, &a)
+		// The test fails if the error does not contain the status code or a wrapped error
+		if a.Error.Code != c.c || a.Error.Message == "" || strings.Contains(a.Error.Message, `{"error"`) {
+			t.Errorf("%v %v: invalid error %+v", c.m, c.p, a)
+		}
+		// The test fails if the message does not match
+		if c.msg != "" && a.Error.Message != c.msg {
+			t.Error(tserr.EqualStr(&tserr.EqualStrArgs{Var: "message of " + c.p, Actual: a.Error.Message, Want: c.msg}))
+		}
+	}
+}
+
+// TestStatusCode retrieves the status code of tserr errors. The test fails if the status code is invalid.
+func TestStatusCode(t *testing.T) {
+	// tc holds the errors and the expected status codes
+	tc := []struct {
+		e error
+		c int
+	}{
+		{nil, http.StatusInternalServerError},
+		{tserr.NotExistent("x"), http.StatusNotFound},
+		{tserr.Check(&tserr.CheckArgs{F: "x", Err: tserr.Empty("y")}), http.StatusPreconditionFailed},
+		{json.Unmarshal([]byte("{"), &struct{}{}), http.StatusInternalServerError},
+		{invalid(tserr.NotExistent("x")), http.StatusBadRequest},
+		{errors.New(`{"error":{"id":2,"code":404,"message":"\"x\" does not exist"}}`), http.StatusNotFound},
+		{errors.New(`{"error":{"id":"2","code":404,"message":"x"}}`), http.StatusInternalServerError},
+		{errors.New(`{"error":{"id":2,"code":200,"message":"x"}}`), http.StatusInternalServerError},
+		{errors.New(`{"error":{"id":2,"code":404,"message":"x"}} trailing`), http.StatusInternalServerError},
+	}
+	// Iterate all errors
+	for _, c := range tc {
+		if s := StatusCode(c.e); s != c.c {
+			t.Error(tserr.Equal(&tserr.EqualArgs{Var: "status code", Actual: int64(s), Want: int64(c.c)}))
+		}
+	}
+	// Wrapped errors with unescaped quotes are decoded and replaced by their messages
+	_, e := lpdice.ParseExpr("2x6")
+	if b := body(e); b.Code != http.StatusPreconditionFailed || !strings.HasSuffix(b.Message, `parsing "2x6": invalid syntax`) || strings.Contains(b.Message, `{"error"`) {
+		t.Errorf("invalid error body %+v", b)
+	}
+}
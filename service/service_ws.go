@@ -0,0 +1,264 @@
+// Copyright (c) 2023 thorstenrie
+// All rights reserved. Use is governed with GNU Affero General Public License v3.0
+// that can be found in the LICENSE file.
+package service
+
+// Import standard library packages as well as tserr
+import (
+	"bufio"           // bufio
+	"crypto/rand"     // rand
+	"crypto/sha1"     // sha1
+	"encoding/base64" // base64
+	"encoding/binary" // binary
+	"io"              // io
+	"net"             // net
+	"net/http"        // http
+	"strings"         // strings
+	"sync"            // sync
+	"time"            // time
+
+	"github.com/thorstenrie/tserr" // tserr
+)
+
+// WebSocket opcodes as defined in RFC 6455
+const (
+	opContinuation byte = 0x0 // continuation frame
+	opText         byte = 0x1 // text frame
+	opBinary       byte = 0x2 // binary frame
+	opClose        byte = 0x8 // connection close
+	opPing         byte = 0x9 // ping
+	opPong         byte = 0xA // pong
+)
+
+// wsGUID is appended to the key of the client to compute the accept key as defined in RFC 6455,
+// maxMessage defines the maximum size of a message received from a client and maxControl the maximum
+// payload size of a control frame as defined in RFC 6455. A client, which sends no frame within
+// readTimeout, is disconnected, as well as a client, which does not accept a frame within writeTimeout.
+const (
+	wsGUID       string        = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	maxMessage   int           = 1 << 16
+	maxControl   int           = 125
+	readTimeout  time.Duration = time.Minute
+	writeTimeout time.Duration = 10 * time.Second
+)
+
+// A wsConn is a server-side WebSocket connection. It supports text messages, ping and close. Writes
+// are safe for concurrent use, reads must be performed by a single goroutine.
+type wsConn struct {
+	c  net.Conn      // underlying connection
+	r  *bufio.Reader // buffered reader of the connection
+	mu sync.Mutex    // mutex to serialize writes
+}
+
+// accept returns the accept key for the key of the client as defined in RFC 6455.
+func accept(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// header returns true, if the comma-separated values of header h in request r contain v ignoring case.
+func header(r *http.Request, h, v string) bool {
+	// Iterate all values of the header
+	for _, s := range r.Header.Values(h) {
+		for _, t := range strings.Split(s, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), v) {
+				return true
+			}
+		}
+	}
+	// Return false if v is not contained
+	return false
+}
+
+// upgrade performs the WebSocket opening handshake for request r and returns the connection. If the
+// request is not a valid WebSocket request, it writes an error to w and returns nil and the error.
+func upgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	// e holds the error, if any
+	var e error
+	// Validate the request
+	key := r.Header.Get("Sec-WebSocket-Key")
+	switch {
+	case r.Method != http.MethodGet:
+		e = tserr.TypeNotMatching(&tserr.TypeNotMatchingArgs{Act: "method " + r.Method, Want: http.MethodGet})
+	case !header(r, "Connection", "upgrade") || !header(r, "Upgrade", "websocket"):
+		e = tserr.NotSet("WebSocket upgrade")
+	case r.Header.Get("Sec-WebSocket-Version") != "13":
+		e = tserr.TypeNotMatching(&tserr.TypeNotMatchingArgs{Act: "WebSocket version " + r.Header.Get("Sec-WebSocket-Version"), Want: "13"})
+	case key == "":
+		e = tserr.Empty("Sec-WebSocket-Key")
+	}
+	// Write the error, if the request is invalid
+	if e != nil {
+		WriteError(w, e)
+		return nil, e
+	}
+	// Take over the connection
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		e = tserr.NotAvailable(&tserr.NotAvailableArgs{S: "WebSocket", Err: http.ErrNotSupported})
+		WriteError(w, e)
+		return nil, e
+	}
+	c, rw, e := hj.Hijack()
+	if e != nil {
+		return nil, tserr.Op(&tserr.OpArgs{Op: "hijack", Fn: "connection", Err: e})
+	}
+	// Complete the handshake
+	if _, e = io.WriteString(c, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: "+accept(key)+"\r\n\r\n"); e != nil {
+		c.Close()
+		return nil, tserr.Op(&tserr.OpArgs{Op: "handshake", Fn: "WebSocket", Err: e})
+	}
+	// Return the connection
+	return &wsConn{c: c, r: rw.Reader}, nil
+}
+
+// writeFrame writes a single final frame with opcode op and payload p to w. Frames of clients must be masked.
+func writeFrame(w io.Writer, op byte, p []byte, mask bool) error {
+	// b holds the frame
+	b := []byte{0x80 | op, 0}
+	// Encode the payload length
+	switch n := len(p); {
+	case n < 126:
+		b[1] = byte(n)
+	case n <= 0xFFFF:
+		b[1] = 126
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b[1] = 127
+		b = binary.BigEndian.AppendUint64(b, uint64(n))
+	}
+	// Append the payload, masked with a random key if requested
+	if mask {
+		b[1] |= 0x80
+		k := make([]byte, 4)
+		if _, e := rand.Read(k); e != nil {
+			return e
+		}
+		b = append(b, k...)
+		for i, c := range p {
+			b = append(b, c^k[i%4])
+		}
+	} else {
+		b = append(b, p...)
+	}
+	// Write the frame
+	_, e := w.Write(b)
+	return e
+}
+
+// readFrame reads a single frame from r and returns whether it is final, its opcode and its unmasked payload.
+// If masked is true, the frame must be masked. It returns an error, if any.
+func readFrame(r io.Reader, masked bool) (bool, byte, []byte, error) {
+	// Read the header
+	h := make([]byte, 2)
+	if _, e := io.ReadFull(r, h); e != nil {
+		return false, 0, nil, e
+	}
+	fin, op, m, n := h[0]&0x80 != 0, h[0]&0x0F, h[1]&0x80 != 0, uint64(h[1]&0x7F)
+	// Return an error if the mask does not match
+	if m != masked {
+		return false, 0, nil, tserr.Forbidden("WebSocket frame with invalid mask")
+	}
+	// Read the extended payload length
+	switch n {
+	case 126:
+		if _, e := io.ReadFull(r, h); e != nil {
+			return false, 0, nil, e
+		}
+		n = uint64(binary.BigEndian.Uint16(h))
+	case 127:
+		l := make([]byte, 8)
+		if _, e := io.ReadFull(r, l); e != nil {
+			return false, 0, nil, e
+		}
+		n = binary.BigEndian.Uint64(l)
+	}
+	// Return an error if the payload is too large
+	if n > uint64(maxMessage) {
+		return false, 0, nil, tserr.Lower(&tserr.LowerArgs{Var: "WebSocket frame size", Actual: int64(n), HigherBound: int64(maxMessage + 1)})
+	}
+	// Return an error if a control frame is fragmented or its payload is too large, as required by RFC 6455
+	if op&0x8 != 0 {
+		if !fin {
+			return false, 0, nil, tserr.Forbidden("fragmented WebSocket control frame")
+		}
+		if n > uint64(maxControl) {
+			return false, 0, nil, tserr.Lower(&tserr.LowerArgs{Var: "WebSocket control frame size", Actual: int64(n), HigherBound: int64(maxControl + 1)})
+		}
+	}
+	// Read the masking key and the payload
+	k := make([]byte, 4)
+	if m {
+		if _, e := io.ReadFull(r, k); e != nil {
+			return false, 0, nil, e
+		}
+	}
+	p := make([]byte, n)
+	if _, e := io.ReadFull(r, p); e != nil {
+		return false, 0, nil, e
+	}
+	// Unmask the payload
+	if m {
+		for i := range p {
+			p[i] ^= k[i%4]
+		}
+	}
+	// Return the frame
+	return fin, op, p, nil
+}
+
+// readMessage reads the next text or binary message from c. It answers pings and returns io.EOF,
+// if the client closes the connection. The read deadline is refreshed with each frame, so that idle
+// clients sending pings stay connected. It returns an error, if any, e.g., if no frame is received
+// within readTimeout.
+func (c *wsConn) readMessage() ([]byte, error) {
+	// m holds the message
+	var m []byte
+	// Read frames until the message is complete
+	for {
+		if e := c.c.SetReadDeadline(time.Now().Add(readTimeout)); e != nil {
+			return nil, e
+		}
+		fin, op, p, e := readFrame(c.r, true)
+		if e != nil {
+			return nil, e
+		}
+		switch op {
+		case opPing:
+			if e = c.write(opPong, p); e != nil {
+				return nil, e
+			}
+		case opPong:
+		case opClose:
+			c.write(opClose, p)
+			return nil, io.EOF
+		case opText, opBinary, opContinuation:
+			// Append the frame and return an error if the message is too large
+			if m = append(m, p...); len(m) > maxMessage {
+				return nil, tserr.Lower(&tserr.LowerArgs{Var: "WebSocket message size", Actual: int64(len(m)), HigherBound: int64(maxMessage + 1)})
+			}
+			if fin {
+				return m, nil
+			}
+		default:
+			return nil, tserr.NotExistent("WebSocket opcode")
+		}
+	}
+}
+
+// write writes a frame with opcode op and payload p to c. It returns an error, if the frame is not written
+// within writeTimeout.
+func (c *wsConn) write(op byte, p []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e := c.c.SetWriteDeadline(time.Now().Add(writeTimeout)); e != nil {
+		return e
+	}
+	return writeFrame(c.c, op, p, false)
+}
+
+// close sends a close frame and closes the connection.
+func (c *wsConn) close() error {
+	c.write(opClose, nil)
+	return c.c.Close()
+}